@@ -19,27 +19,55 @@ var (
 	// Commands
 	run      = app.Command("run", "Run kelon in production mode.")
 	validate = app.Command("validate", "Run kelon in validate mode: validate policies by printing resulting datastore queries")
+	verify   = app.Command("verify-audit-log", "Verify the hash chain of the audit log to detect tampering.")
 
 	// Config paths
 	configurationPath = app.Flag("config", "Path to the configuration yaml.").Short('k').Default("./kelon.yml").Envar("KELON_CONF").ExistingFile()
 	configWatcherPath = app.Flag("config-watcher-path", "Path where the config watcher should listen for changes.").Envar("CONFIG_WATCHER_PATH").ExistingDir()
 	regoDir           = app.Flag("rego-dir", "Dir containing .rego files which will be loaded into OPA.").Short('r').Envar("REGO_DIR").ExistingDir()
+	bundlePath        = app.Flag("bundle", "Bundle file (i.e. built by 'opa build') or dir containing a .manifest, which will be loaded into OPA instead of --rego-dir.").Short('b').Envar("BUNDLE").ExistingFileOrDir()
 	operandDir        = app.Flag("call-operand-dir", "Dir containing .yaml files which contain the call operand configuration for the datastores").Short('c').Envar("CALL_OPERANDS_DIR").ExistingDir()
 
+	// Bundle verification
+	bundleVerificationKeyID = app.Flag("bundle-verification-key-id", "ID of the key (configured in the keys of OPA's configuration) which has to sign the bundle. If keys are configured, bundles always have to be signed by one of them.").Envar("BUNDLE_VERIFICATION_KEY_ID").String()
+	bundleVerificationScope = app.Flag("bundle-verification-scope", "Scope which has to be contained in the bundle's signature.").Envar("BUNDLE_VERIFICATION_SCOPE").String()
+
+	// Config watcher
+	configWatcherMode         = app.Flag("config-watcher-mode", "Mode of the config watcher. Must be one of [FSNOTIFY, POLL]. POLL also detects ConfigMap symlink swaps in Kubernetes.").Default("FSNOTIFY").Envar("CONFIG_WATCHER_MODE").Enum("FSNOTIFY", "POLL", "fsnotify", "poll")
+	configWatcherPollInterval = app.Flag("config-watcher-poll-interval", "Interval in which the config watcher compares the watched files in POLL mode.").Default("5s").Envar("CONFIG_WATCHER_POLL_INTERVAL").Duration()
+	configWatcherDebounce     = app.Flag("config-watcher-debounce", "Window in which the config watcher collects file changes in FSNOTIFY mode before they are applied at once.").Default("500ms").Envar("CONFIG_WATCHER_DEBOUNCE").Duration()
+
 	// Additional config
-	pathPrefix     = app.Flag("path-prefix", "Prefix which is used to proxy OPA's Data-API.").Default("/v1").Envar("PATH_PREFIX").String()
-	port           = app.Flag("port", "Port on which the proxy endpoint is served.").Short('p').Default("8181").Envar("PORT").Uint32()
-	astSkipUnknown = app.Flag("ast-skip-unknown", "Skip unknown parts in the AST and only log as warning.").Default("false").Envar("AST_SKIP_UNKNOWN").Bool()
+	pathPrefix         = app.Flag("path-prefix", "Prefix which is used to proxy OPA's Data-API.").Default("/v1").Envar("PATH_PREFIX").String()
+	port               = app.Flag("port", "Port on which the proxy endpoint is served.").Short('p').Default("8181").Envar("PORT").Uint32()
+	astSkipUnknown     = app.Flag("ast-skip-unknown", "Skip unknown parts in the AST and only log as warning.").Default("false").Envar("AST_SKIP_UNKNOWN").Bool()
+	statementCacheSize = app.Flag("statement-cache-size", "Max. amount of translated statements which are cached by the shape of their partially evaluated query. 0 disables the cache.").Default("1000").Envar("STATEMENT_CACHE_SIZE").Int()
+
+	// Data-API
+	opaCompatibleResponses = app.Flag("opa-compatible-responses", "Answer decisions of the Data-API with status 200 and OPA's response body {\"result\": {\"allow\": ..., \"verify\": ...}} instead of status codes only, so that OPA's SDKs can be used. Single requests can ask for it with the header 'Accept: application/vnd.kelon.opa+json'.").Default("false").Envar("OPA_COMPATIBLE_RESPONSES").Bool()
+	batchWorkers           = app.Flag("batch-workers", "Amount of inputs of a batch decision, which are evaluated concurrently.").Default("8").Envar("BATCH_WORKERS").Int()
+	batchMaxSize           = app.Flag("batch-max-size", "Maximum amount of inputs of a batch decision. 0 disables the limit.").Default("100").Envar("BATCH_MAX_SIZE").Int()
 
 	// Logging
 	logLevel               = app.Flag("log-level", "Log-Level for Kelon. Must be one of [DEBUG, INFO, WARN, ERROR]").Default("INFO").Envar("LOG_LEVEL").Enum("DEBUG", "INFO", "WARN", "ERROR", "debug", "info", "warn", "error")
 	logFormat              = app.Flag("log-format", "Log-Format for Kelon. Must be one of [TEXT, JSON]").Default("TEXT").Envar("LOG_FORMAT").Enum("TEXT", "JSON")
 	accessDecisionLogLevel = app.Flag("access-decision-log-level", "Access decision Log-Level for Kelon. Must be one of [ALL, ALLOW, DENY, NONE]").Default("ALL").Envar("ACCESS_DECISION_LOG_LEVEL").Enum("ALL", "ALLOW", "DENY", "NONE", "all", "allow", "deny", "none")
+	decisionLogQueryParams = app.Flag("decision-log-query-parameters", "Write the parameters of datastore queries to OPA's decision logs. They might contain credentials or personal data and are redacted by default.").Default("false").Envar("DECISION_LOG_QUERY_PARAMETERS").Bool()
+
+	// Audit log
+	auditLogFile       = app.Flag("audit-log-file", "File to which all access decisions are appended as JSON lines, independent of the log level.").Envar("AUDIT_LOG_FILE").String()
+	auditLogMaxSizeMB  = app.Flag("audit-log-max-size-mb", "Size in megabytes after which the audit log is rotated. 0 disables size based rotation.").Default("100").Envar("AUDIT_LOG_MAX_SIZE_MB").Int64()
+	auditLogMaxAge     = app.Flag("audit-log-max-age", "Age of the audit log (measured from its first record) after which it is rotated. 0 disables time based rotation.").Default("24h").Envar("AUDIT_LOG_MAX_AGE").Duration()
+	auditLogMaxBackups = app.Flag("audit-log-max-backups", "Amount of rotated audit logs which are kept. 0 keeps all of them.").Default("0").Envar("AUDIT_LOG_MAX_BACKUPS").Int()
+	auditLogRedact     = app.Flag("audit-log-redact", "Field of the input which is redacted in the audit log, i.e. 'input.password'. Can be repeated.").Envar("AUDIT_LOG_REDACT").Strings()
+	auditLogHashChain  = app.Flag("audit-log-hash-chain", "Chain the audit records by their hashes, so that tampering can be detected with 'verify-audit-log'.").Default("false").Envar("AUDIT_LOG_HASH_CHAIN").Bool()
 
 	// Configs for envoy external auth
-	envoyPort       = app.Flag("envoy-port", "Also start Envoy GRPC-Proxy on specified port so integrate kelon with Istio.").Envar("ENVOY_PORT").Uint32()
-	envoyDryRun     = app.Flag("envoy-dry-run", "Enable/Disable the dry run feature of the envoy-proxy.").Default("false").Envar("ENVOY_DRY_RUN").Bool()
-	envoyReflection = app.Flag("envoy-reflection", "Enable/Disable the reflection feature of the envoy-proxy.").Default("true").Envar("ENVOY_REFLECTION").Bool()
+	envoyPort           = app.Flag("envoy-port", "Also start Envoy GRPC-Proxy on specified port so integrate kelon with Istio. Kelon's native gRPC API (kelon.v1.DecisionService) is served on the same port.").Envar("ENVOY_PORT").Uint32()
+	envoyDryRun         = app.Flag("envoy-dry-run", "Enable/Disable the dry run feature of the envoy-proxy.").Default("false").Envar("ENVOY_DRY_RUN").Bool()
+	envoyReflection     = app.Flag("envoy-reflection", "Enable/Disable the reflection feature of the envoy-proxy.").Default("true").Envar("ENVOY_REFLECTION").Bool()
+	envoyHTTPPort       = app.Flag("envoy-http-port", "Also start Envoy's HTTP ext_authz service on specified port for clusters which are unable to run gRPC filters.").Envar("ENVOY_HTTP_PORT").Uint32()
+	envoyHTTPPathPrefix = app.Flag("envoy-http-path-prefix", "The path_prefix configured for Envoy's HTTP ext_authz service, which is removed from the checked path.").Default("").Envar("ENVOY_HTTP_PATH_PREFIX").String()
 
 	// Configs for telemetry
 	metricProvider           = app.Flag("metric-provider", "Provider that is used for metrics [Prometheus|OTLP]").Envar("METRIC_PROVIDER").Enum("Prometheus", "prometheus", "OTLP", "otlp")
@@ -53,6 +81,9 @@ var (
 	// Configs for validate mode
 	inputBody           = app.Flag("input-body", "Input Body to use in dry run mode").Envar("DRY_INPUT_BODY").String()
 	queryOutputFilename = app.Flag("query-output", "File to write the Query to (JSON). If not set, write to stdout using logging format").Envar("QUERY_OUTPUT_FILE").String()
+
+	// Configs for verify-audit-log mode
+	auditFiles = verify.Arg("files", "Audit files to verify, oldest first. Defaults to --audit-log-file and its rotated files.").ExistingFiles()
 )
 
 func main() {
@@ -73,27 +104,46 @@ func main() {
 	setLogLevel()
 
 	config := core.KelonConfiguration{
-		ConfigPath:               configurationPath,
-		ConfigWatcherPath:        configWatcherPath,
-		RegoDir:                  regoDir,
-		OperandDir:               operandDir,
-		PathPrefix:               pathPrefix,
-		Port:                     port,
-		AstSkipUnknown:           astSkipUnknown,
-		AccessDecisionLogLevel:   accessDecisionLogLevel,
-		EnvoyPort:                envoyPort,
-		EnvoyDryRun:              envoyDryRun,
-		EnvoyReflection:          envoyReflection,
-		MetricProvider:           metricProvider,
-		TraceProvider:            traceProvider,
-		OtlpMetricExportProtocol: otlpMetricExportProtocol,
-		OtlpMetricExportEndpoint: otlpMetricExportEndpoint,
-		OtlpServiceName:          otlpServiceName,
-		OtlpTraceExportProtocol:  otlpTraceExportProtocol,
-		OtlpTraceExportEndpoint:  otlpTraceExportEndpoint,
-		Validate:                 false,
-		InputBody:                inputBody,
-		QueryOutputFilename:      queryOutputFilename,
+		ConfigPath:                configurationPath,
+		ConfigWatcherPath:         configWatcherPath,
+		RegoDir:                   regoDir,
+		BundlePath:                bundlePath,
+		BundleVerificationKeyID:   bundleVerificationKeyID,
+		BundleVerificationScope:   bundleVerificationScope,
+		OperandDir:                operandDir,
+		ConfigWatcherMode:         configWatcherMode,
+		ConfigWatcherPollInterval: configWatcherPollInterval,
+		ConfigWatcherDebounce:     configWatcherDebounce,
+		PathPrefix:                pathPrefix,
+		Port:                      port,
+		AstSkipUnknown:            astSkipUnknown,
+		StatementCacheSize:        statementCacheSize,
+		OPACompatibleResponses:    opaCompatibleResponses,
+		BatchWorkers:              batchWorkers,
+		BatchMaxSize:              batchMaxSize,
+		AccessDecisionLogLevel:    accessDecisionLogLevel,
+		DecisionLogQueryParams:    decisionLogQueryParams,
+		AuditLogFile:              auditLogFile,
+		AuditLogMaxSizeMB:         auditLogMaxSizeMB,
+		AuditLogMaxAge:            auditLogMaxAge,
+		AuditLogMaxBackups:        auditLogMaxBackups,
+		AuditLogRedact:            auditLogRedact,
+		AuditLogHashChain:         auditLogHashChain,
+		EnvoyPort:                 envoyPort,
+		EnvoyDryRun:               envoyDryRun,
+		EnvoyReflection:           envoyReflection,
+		EnvoyHTTPPort:             envoyHTTPPort,
+		EnvoyHTTPPathPrefix:       envoyHTTPPathPrefix,
+		MetricProvider:            metricProvider,
+		TraceProvider:             traceProvider,
+		OtlpMetricExportProtocol:  otlpMetricExportProtocol,
+		OtlpMetricExportEndpoint:  otlpMetricExportEndpoint,
+		OtlpServiceName:           otlpServiceName,
+		OtlpTraceExportProtocol:   otlpTraceExportProtocol,
+		OtlpTraceExportEndpoint:   otlpTraceExportEndpoint,
+		Validate:                  false,
+		InputBody:                 inputBody,
+		QueryOutputFilename:       queryOutputFilename,
 	}
 
 	kelon := core.Kelon{}
@@ -108,6 +158,10 @@ func main() {
 		kelon.Configure(&config)
 		kelon.StartValidate()
 
+	case verify.FullCommand():
+		kelon.Configure(&config)
+		kelon.StartVerifyAuditLog(*auditFiles)
+
 	default:
 		logging.LogForComponent("main").Fatal("Started Kelon with a unknown command!")
 	}
@@ -2,6 +2,7 @@ package configs
 
 import (
 	"fmt"
+	"regexp"
 	"strings"
 
 	"github.com/pkg/errors"
@@ -10,6 +11,12 @@ import (
 //nolint:gochecknoglobals,gocritic
 var boolTrue = true
 
+// ruleName matches the names of rules, which can be configured for an APIMapping
+var ruleName = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// packageName matches the names of packages, which can be configured as candidate of an APIMapping
+var packageName = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*(\.[a-zA-Z_][a-zA-Z0-9_]*)*$`)
+
 // DatastoreAPIMapping holds the API-mappings for one of the datastores defined in configs.DatastoreConfig.
 //
 // Each mapping has a type of 'mapping global' Prefix which should be appended to each Path of its Mappings.
@@ -30,6 +37,18 @@ type APIMapping struct {
 	Package string
 	Methods []string
 	Queries []string
+	// VerifyRule is the rule used for authentication (default 'verify')
+	VerifyRule string `yaml:"verify-rule,omitempty"`
+	// AllowRule is the rule used for authorization (default 'allow', unless DenyRules are configured)
+	AllowRule string `yaml:"allow-rule,omitempty"`
+	// DenyRules are evaluated in order after the AllowRule, any of them being true rejects the request.
+	// If they are configured without an AllowRule, requests are allowed unless they are denied.
+	DenyRules []string `yaml:"deny-rules,omitempty"`
+	// Shadow evaluates and logs the decision, but always allows the request (dry-run).
+	Shadow bool `yaml:"shadow,omitempty"`
+	// CandidatePackage is evaluated side by side with the Package and disagreeing decisions are reported.
+	// The decision of the Package is returned.
+	CandidatePackage string `yaml:"candidate-package,omitempty"`
 }
 
 // Validate checks if the provided DatastoreAPIMapping config does not contain invalid options
@@ -67,6 +86,22 @@ func (m *DatastoreAPIMapping) Validate(schema DatastoreSchemas) error {
 		}
 	}
 
+	for _, mapping := range m.Mappings {
+		rules := append([]string{mapping.VerifyRule, mapping.AllowRule}, mapping.DenyRules...)
+		for i, rule := range rules {
+			// Verify and allow rule are optional
+			if i < 2 && rule == "" {
+				continue
+			}
+			if !ruleName.MatchString(rule) {
+				return errors.Errorf("mapping %q of path-prefix %q has an invalid rule name %q", mapping.Path, m.Prefix, rule)
+			}
+		}
+		if mapping.CandidatePackage != "" && !packageName.MatchString(mapping.CandidatePackage) {
+			return errors.Errorf("mapping %q of path-prefix %q has an invalid candidate package %q", mapping.Path, m.Prefix, mapping.CandidatePackage)
+		}
+	}
+
 	return nil
 }
 
@@ -5,6 +5,7 @@ import (
 	"os"
 
 	"github.com/pkg/errors"
+	"github.com/unbasical/kelon/pkg/audit"
 	"github.com/unbasical/kelon/pkg/telemetry"
 	"gopkg.in/yaml.v3"
 )
@@ -15,6 +16,7 @@ type AppConfig struct {
 	CallOperands    map[string]map[string]func(args ...string) (string, error)
 	MetricsProvider telemetry.MetricsProvider
 	TraceProvider   telemetry.TraceProvider
+	AuditSink       audit.Sink
 }
 
 // ExternalConfig holds all externally configurable properties
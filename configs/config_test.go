@@ -2,6 +2,7 @@ package configs_test
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/unbasical/kelon/configs"
@@ -22,6 +23,11 @@ var wantedExternalConfig = configs.ExternalConfig{
 				},
 			},
 		},
+		DecisionCache: &configs.DecisionCache{
+			TTL:       30 * time.Second,
+			MaxSize:   1000,
+			KeyFields: []string{"token"},
+		},
 	},
 	APIMappings: []*configs.DatastoreAPIMapping{
 		{
@@ -147,3 +153,27 @@ func TestLoadAmbiguousEntitiesCausedByAPIMapping(t *testing.T) {
 
 	assert.EqualError(t, err, "loaded invalid configuration: The entity \"pg.appstore.user_followers\" collides with entity \"mysql.appstore.followers\"!")
 }
+
+func TestLoadInvalidDecisionCache(t *testing.T) {
+	_, err := (&configs.ByteConfigLoader{
+		FileBytes: []byte("global:\n  decision-cache:\n    ttl: 0s\n    max-size: 10\n"),
+	}).Load()
+
+	assert.EqualError(t, err, "loaded invalid configuration: decision-cache: ttl must be greater than 0")
+}
+
+func TestLoadInvalidRuleName(t *testing.T) {
+	_, err := (&configs.ByteConfigLoader{
+		FileBytes: []byte("apis:\n  - path-prefix: /api\n    mappings:\n      - path: /apps\n        package: apps\n        deny-rules:\n          - deny.all\n"),
+	}).Load()
+
+	assert.EqualError(t, err, "loaded invalid configuration: mapping \"/apps\" of path-prefix \"/api\" has an invalid rule name \"deny.all\"")
+}
+
+func TestLoadInvalidCandidatePackage(t *testing.T) {
+	_, err := (&configs.ByteConfigLoader{
+		FileBytes: []byte("apis:\n  - path-prefix: /api\n    mappings:\n      - path: /apps\n        package: apps\n        shadow: true\n        candidate-package: apps/next\n"),
+	}).Load()
+
+	assert.EqualError(t, err, "loaded invalid configuration: mapping \"/apps\" of path-prefix \"/api\" has an invalid candidate package \"apps/next\"")
+}
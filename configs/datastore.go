@@ -7,14 +7,31 @@ import (
 // DatastoreSchemas maps the datastore alias to a datastore schema to a slice of Entities which are contained in this schema.
 type DatastoreSchemas = map[string]map[string]*EntitySchema
 
+// Datastore host roles
+const (
+	HostRolePrimary = "primary"
+	HostRoleReplica = "replica"
+)
+
 // Datastore has a fixed Type (enum type) and variable connection-/metadata-properties
 // Which should be validated and parsed by each data.Datastore separately.
+//
+// If Hosts are configured, they replace host and port of the Connection.
 type Datastore struct {
 	Type       string
 	Connection map[string]string
+	Hosts      []*DatastoreHost
 	Metadata   map[string]string
 }
 
+// DatastoreHost is a single host of a datastore with its role (primary or replica).
+// If no role is configured, the host is treated as primary.
+type DatastoreHost struct {
+	Host string
+	Port string
+	Role string
+}
+
 // EntitySchema contains a List of entities of a schema
 type EntitySchema struct {
 	Entities []*Entity
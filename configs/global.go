@@ -1,10 +1,24 @@
 package configs
 
-import "github.com/pkg/errors"
+import (
+	"time"
+
+	"github.com/pkg/errors"
+)
 
 // Global holds the global configuration for the application.
 type Global struct {
-	Input Input `yaml:"input"`
+	Input         Input          `yaml:"input"`
+	DecisionCache *DecisionCache `yaml:"decision-cache,omitempty"`
+}
+
+// DecisionCache holds the configuration of the optional in-process decision cache.
+// Decisions are cached by the request's method, path and the configured KeyFields of the input.
+// If no KeyFields are configured, the entire input is used as key.
+type DecisionCache struct {
+	TTL       time.Duration `yaml:"ttl"`
+	MaxSize   int           `yaml:"max-size"`
+	KeyFields []string      `yaml:"key-fields"`
 }
 
 // Input holds input related configuration, such as global header to input mappings.
@@ -21,9 +35,30 @@ type HeaderMapping struct {
 
 // Validate checks if the provided Global config does not contain invalid options
 func (g *Global) Validate() error {
+	if g.DecisionCache != nil {
+		if err := g.DecisionCache.Validate(); err != nil {
+			return err
+		}
+	}
 	return g.Input.Validate()
 }
 
+// Validate checks if the provided DecisionCache config does not contain invalid options
+func (c *DecisionCache) Validate() error {
+	if c.TTL <= 0 {
+		return errors.Errorf("decision-cache: ttl must be greater than 0")
+	}
+	if c.MaxSize <= 0 {
+		return errors.Errorf("decision-cache: max-size must be greater than 0")
+	}
+	for _, field := range c.KeyFields {
+		if field == "" {
+			return errors.Errorf("decision-cache: Empty key-field")
+		}
+	}
+	return nil
+}
+
 // Validate checks if the provided Input config does not contain invalid options
 func (i *Input) Validate() error {
 	// Validate include header mappings
@@ -0,0 +1,180 @@
+package envoy
+
+import (
+	"context"
+	"maps"
+	"sync/atomic"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/unbasical/kelon/internal/pkg/api/health"
+	"github.com/unbasical/kelon/internal/pkg/util"
+	"github.com/unbasical/kelon/pkg/api"
+	kelonv1 "github.com/unbasical/kelon/pkg/api/proto/kelon/v1"
+	"github.com/unbasical/kelon/pkg/constants"
+	"github.com/unbasical/kelon/pkg/constants/logging"
+	"github.com/unbasical/kelon/pkg/data"
+	internalErrors "github.com/unbasical/kelon/pkg/errors"
+	"github.com/unbasical/kelon/pkg/opa"
+	"github.com/unbasical/kelon/pkg/request"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// decisionServer implements Kelon's native gRPC API, so that callers don't have to fake the HTTP attributes of
+// Envoy's CheckRequest. It is served by the same grpc-server as the envoyExtAuthzGrpcServer.
+type decisionServer struct {
+	kelonv1.UnimplementedDecisionServiceServer
+	config atomic.Pointer[api.ClientProxyConfig]
+}
+
+// Decide - see kelonv1.DecisionServiceServer
+func (s *decisionServer) Decide(ctx context.Context, req *kelonv1.DecideRequest) (*kelonv1.DecideResponse, error) {
+	decision, err := s.decide(ctx, req.GetInput().AsMap())
+	if err != nil {
+		return nil, err
+	}
+	return &kelonv1.DecideResponse{Decision: decision}, nil
+}
+
+// BatchDecide - see kelonv1.DecisionServiceServer
+func (s *decisionServer) BatchDecide(ctx context.Context, req *kelonv1.BatchDecideRequest) (*kelonv1.BatchDecideResponse, error) {
+	if err := s.checkBatchSize(len(req.GetInputs())); err != nil {
+		return nil, err
+	}
+
+	ctx = data.WithSharedQueries(ctx, &data.SharedQueries{})
+	results := make([]*kelonv1.BatchDecideResult, len(req.GetInputs()))
+	util.RunWorkers(s.config.Load().BatchWorkers, len(results), func(item int) {
+		decision, err := s.decide(ctx, req.GetInputs()[item].AsMap())
+		if err != nil {
+			results[item] = &kelonv1.BatchDecideResult{Result: &kelonv1.BatchDecideResult_Error{Error: status.Convert(err).Proto()}}
+			return
+		}
+		results[item] = &kelonv1.BatchDecideResult{Result: &kelonv1.BatchDecideResult_Decision{Decision: decision}}
+	})
+	return &kelonv1.BatchDecideResponse{Results: results}, nil
+}
+
+// Filter - see kelonv1.DecisionServiceServer
+func (s *decisionServer) Filter(ctx context.Context, req *kelonv1.FilterRequest) (*kelonv1.FilterResponse, error) {
+	if err := s.checkBatchSize(len(req.GetItems())); err != nil {
+		return nil, err
+	}
+
+	ctx = data.WithSharedQueries(ctx, &data.SharedQueries{})
+	base := req.GetInput().AsMap()
+	allowed := make([]bool, len(req.GetItems()))
+	util.RunWorkers(s.config.Load().BatchWorkers, len(allowed), func(item int) {
+		input := maps.Clone(base)
+		if input == nil {
+			input = make(map[string]any)
+		}
+		maps.Copy(input, req.GetItems()[item].AsMap())
+
+		// Items, which can't be decided, are filtered as well
+		decision, err := s.decide(ctx, input)
+		allowed[item] = err == nil && decision.GetAllow()
+	})
+
+	resp := &kelonv1.FilterResponse{}
+	for item, allow := range allowed {
+		if allow {
+			resp.Allowed = append(resp.Allowed, uint32(item))
+		}
+	}
+	return resp, nil
+}
+
+// Health - see kelonv1.DecisionServiceServer
+// The status is aggregated like the one of the REST-Proxy's health endpoint (see health.Check).
+func (s *decisionServer) Health(ctx context.Context, _ *kelonv1.HealthRequest) (*kelonv1.HealthResponse, error) {
+	report := health.Check(ctx, s.config.Load())
+	resp := &kelonv1.HealthResponse{
+		Status:     report.Status,
+		Datastores: make(map[string]string, len(report.Datastores)),
+	}
+	for alias, ds := range report.Datastores {
+		resp.Datastores[alias] = ds.Status
+	}
+	return resp, nil
+}
+
+// decide makes the decision for the input. Denials because of the request translation are returned as denied
+// decisions, all other errors as gRPC status.
+func (s *decisionServer) decide(ctx context.Context, input map[string]any) (*kelonv1.Decision, error) {
+	decisionID := uuid.New().String()
+	decision, err := (*s.config.Load().Compiler).Execute(opa.WithDecisionID(ctx, decisionID), map[string]any{constants.Input: input})
+	if err != nil {
+		var invalidRequestTranslation internalErrors.InvalidRequestTranslation
+		if errors.As(errors.Cause(err), &invalidRequestTranslation) {
+			logging.LogForComponent("decisionServer").WithField(logging.LabelDecisionID, decisionID).Infof("Denied request: %s", err)
+			return &kelonv1.Decision{DecisionId: decisionID}, nil
+		}
+
+		logging.LogForComponent("decisionServer").WithField(logging.LabelDecisionID, decisionID).Errorf("Unable to decide: %s", err)
+		return nil, status.Error(errorCode(err), errors.Cause(err).Error())
+	}
+
+	if log.IsLevelEnabled(log.DebugLevel) {
+		logDecision := "DENY"
+		if decision.Allow {
+			logDecision = "ALLOW"
+		}
+		logging.LogForComponent("decisionServer").
+			WithFields(log.Fields{
+				logging.LabelDecision:   logDecision,
+				logging.LabelDecisionID: decisionID,
+				logging.LabelRevision:   decision.Revision,
+			}).
+			Debug("Returning policy decision.")
+	}
+	return toProtoDecision(decision), nil
+}
+
+// checkBatchSize returns an error if the amount of inputs exceeds the configured maximum size of batches
+func (s *decisionServer) checkBatchSize(size int) error {
+	if maxSize := s.config.Load().BatchMaxSize; maxSize > 0 && size > maxSize {
+		return status.Errorf(codes.InvalidArgument, "batch contains %d inputs, but at most %d are allowed", size, maxSize)
+	}
+	return nil
+}
+
+// errorCode maps the error of a decision to the gRPC status code
+func errorCode(err error) codes.Code {
+	var pathAmbiguousError request.PathAmbiguousError
+	var pathNotFoundError request.PathNotFoundError
+	var invalidInput internalErrors.InvalidInput
+	var datastoreUnavailable internalErrors.DatastoreUnavailable
+	switch err := errors.Cause(err); {
+	case errors.As(err, &pathAmbiguousError), errors.As(err, &pathNotFoundError):
+		return codes.NotFound
+	case errors.As(err, &invalidInput):
+		return codes.InvalidArgument
+	case errors.As(err, &datastoreUnavailable):
+		return codes.Unavailable
+	default:
+		return codes.Internal
+	}
+}
+
+// toProtoDecision converts the decision including the results of the policy
+func toProtoDecision(decision *opa.Decision) *kelonv1.Decision {
+	result := &kelonv1.Decision{
+		DecisionId: decision.ID,
+		Allow:      decision.Allow,
+		Verify:     decision.Verify,
+		Headers:    decision.Headers,
+		Reason:     decision.Reason,
+	}
+	if decision.Obligations != nil {
+		obligations, err := structpb.NewValue(decision.Obligations)
+		if err != nil {
+			logging.LogForComponent("decisionServer").Warnf("Unable to convert obligations of decision %s: %s", decision.ID, err)
+		}
+		result.Obligations = obligations
+	}
+	return result
+}
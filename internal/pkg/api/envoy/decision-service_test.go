@@ -0,0 +1,187 @@
+package envoy
+
+import (
+	"context"
+	"testing"
+
+	"github.com/open-policy-agent/opa/v1/plugins"
+	"github.com/open-policy-agent/opa/v1/storage/inmem"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/unbasical/kelon/configs"
+	"github.com/unbasical/kelon/internal/pkg/api/health"
+	"github.com/unbasical/kelon/pkg/api"
+	kelonv1 "github.com/unbasical/kelon/pkg/api/proto/kelon/v1"
+	"github.com/unbasical/kelon/pkg/constants"
+	"github.com/unbasical/kelon/pkg/data"
+	internalErrors "github.com/unbasical/kelon/pkg/errors"
+	"github.com/unbasical/kelon/pkg/opa"
+	"github.com/unbasical/kelon/pkg/telemetry"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// pathCompiler allows all requests for the path /allowed and fails for requests without path
+type pathCompiler struct{}
+
+func (c pathCompiler) GetEngine() *plugins.Manager {
+	panic("implement me")
+}
+
+func (c pathCompiler) Configure(_ *configs.AppConfig, _ *opa.PolicyCompilerConfig) error {
+	return nil
+}
+
+func (c pathCompiler) Execute(ctx context.Context, requestBody map[string]any) (*opa.Decision, error) {
+	input := requestBody[constants.Input].(map[string]any)
+	switch input["path"] {
+	case nil:
+		return nil, internalErrors.InvalidInput{Msg: "missing path"}
+	case "/translation":
+		return nil, internalErrors.InvalidRequestTranslation{}
+	}
+	return &opa.Decision{
+		ID:          opa.DecisionIDFromContext(ctx),
+		Allow:       input["path"] == "/allowed" && input["method"] == "GET",
+		Verify:      true,
+		Obligations: []any{"log-access"},
+	}, nil
+}
+
+func newTestDecisionServer(batchMaxSize int) *decisionServer {
+	var compiler opa.PolicyCompiler = pathCompiler{}
+	server := &decisionServer{}
+	server.config.Store(&api.ClientProxyConfig{Compiler: &compiler, BatchWorkers: 2, BatchMaxSize: batchMaxSize})
+	return server
+}
+
+func newStruct(t *testing.T, fields map[string]any) *structpb.Struct {
+	s, err := structpb.NewStruct(fields)
+	assert.NoError(t, err)
+	return s
+}
+
+func TestDecide(t *testing.T) {
+	server := newTestDecisionServer(0)
+
+	resp, err := server.Decide(context.Background(), &kelonv1.DecideRequest{Input: newStruct(t, map[string]any{"method": "GET", "path": "/allowed"})})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, resp.GetDecision().GetDecisionId())
+	assert.True(t, resp.GetDecision().GetAllow())
+	assert.True(t, resp.GetDecision().GetVerify())
+	assert.Equal(t, []any{"log-access"}, resp.GetDecision().GetObligations().AsInterface())
+
+	resp, err = server.Decide(context.Background(), &kelonv1.DecideRequest{Input: newStruct(t, map[string]any{"path": "/translation"})})
+	assert.NoError(t, err)
+	assert.False(t, resp.GetDecision().GetAllow())
+	assert.False(t, resp.GetDecision().GetVerify())
+
+	_, err = server.Decide(context.Background(), &kelonv1.DecideRequest{})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestBatchDecide(t *testing.T) {
+	server := newTestDecisionServer(3)
+
+	resp, err := server.BatchDecide(context.Background(), &kelonv1.BatchDecideRequest{Inputs: []*structpb.Struct{
+		newStruct(t, map[string]any{"method": "GET", "path": "/allowed"}),
+		newStruct(t, map[string]any{"method": "GET", "path": "/denied"}),
+		newStruct(t, map[string]any{}),
+	}})
+	assert.NoError(t, err)
+	assert.Len(t, resp.GetResults(), 3)
+	assert.True(t, resp.GetResults()[0].GetDecision().GetAllow())
+	assert.False(t, resp.GetResults()[1].GetDecision().GetAllow())
+	assert.Equal(t, int32(codes.InvalidArgument), resp.GetResults()[2].GetError().GetCode())
+
+	_, err = server.BatchDecide(context.Background(), &kelonv1.BatchDecideRequest{Inputs: make([]*structpb.Struct, 4)})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestFilter(t *testing.T) {
+	server := newTestDecisionServer(0)
+
+	resp, err := server.Filter(context.Background(), &kelonv1.FilterRequest{
+		Input: newStruct(t, map[string]any{"method": "GET"}),
+		Items: []*structpb.Struct{
+			newStruct(t, map[string]any{"path": "/denied"}),
+			newStruct(t, map[string]any{"path": "/allowed"}),
+			newStruct(t, map[string]any{}),
+			newStruct(t, map[string]any{"path": "/allowed", "method": "POST"}),
+			newStruct(t, map[string]any{"path": "/allowed"}),
+		},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []uint32{1, 4}, resp.GetAllowed())
+}
+
+// engineCompiler is a pathCompiler with an engine, whose plugins are reported by the health check
+type engineCompiler struct {
+	pathCompiler
+	engine *plugins.Manager
+}
+
+func (c engineCompiler) GetEngine() *plugins.Manager {
+	return c.engine
+}
+
+// pingDatastore answers pings with the configured error, i.e. after it was closed
+type pingDatastore struct {
+	err error
+}
+
+func (ds *pingDatastore) Configure(_ *configs.AppConfig, _ string) error {
+	return nil
+}
+
+func (ds *pingDatastore) Execute(_ context.Context, _ data.Node) (bool, error) {
+	return true, nil
+}
+
+func (ds *pingDatastore) Ping(_ context.Context) error {
+	return ds.err
+}
+
+func healthProxyConfig(compiler *opa.PolicyCompiler, datastores map[string]data.Datastore) *api.ClientProxyConfig {
+	config := &api.ClientProxyConfig{Compiler: compiler}
+	config.Datastores = make(map[string]*data.Datastore, len(datastores))
+	for alias, ds := range datastores {
+		config.Datastores[alias] = &ds
+	}
+	return config
+}
+
+func TestHealth(t *testing.T) {
+	engine, err := plugins.New(nil, "test", inmem.New())
+	assert.NoError(t, err)
+	var compiler opa.PolicyCompiler = engineCompiler{engine: engine}
+	appConf := &configs.AppConfig{MetricsProvider: telemetry.NewNoopMetricProvider()}
+
+	initial := &pingDatastore{}
+	proxy := NewEnvoyProxy(Config{Port: 9191}).(*envoyProxy)
+	assert.NoError(t, proxy.Configure(context.Background(), appConf, healthProxyConfig(&compiler, map[string]data.Datastore{"mysql": initial})))
+
+	resp, err := proxy.decisions.Health(context.Background(), &kelonv1.HealthRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, health.StatusHealthy, resp.GetStatus())
+	assert.Equal(t, map[string]string{"mysql": health.StatusHealthy}, resp.GetDatastores())
+
+	// Unreachable datastores only degrade kelon, like in the REST-Proxy's health endpoint
+	initial.err = errors.New("connection refused")
+	resp, _ = proxy.decisions.Health(context.Background(), &kelonv1.HealthRequest{})
+	assert.Equal(t, health.StatusDegraded, resp.GetStatus())
+	assert.Equal(t, health.StatusUnhealthy, resp.GetDatastores()["mysql"])
+
+	// A reload retires (and closes) the previous datastores, so the reloaded ones are checked
+	reloaded := healthProxyConfig(&compiler, map[string]data.Datastore{"mysql": &pingDatastore{}, "pg": &pingDatastore{}})
+	assert.NoError(t, proxy.Reload(appConf, reloaded))
+	resp, _ = proxy.decisions.Health(context.Background(), &kelonv1.HealthRequest{})
+	assert.Equal(t, health.StatusHealthy, resp.GetStatus())
+	assert.Equal(t, map[string]string{"mysql": health.StatusHealthy, "pg": health.StatusHealthy}, resp.GetDatastores())
+}
+
+func TestReloadNotConfigured(t *testing.T) {
+	proxy := NewEnvoyProxy(Config{Port: 9191}).(*envoyProxy)
+	assert.Error(t, proxy.Reload(&configs.AppConfig{}, &api.ClientProxyConfig{}))
+}
@@ -2,16 +2,28 @@ package envoy
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"mime"
 	"net"
+	"net/http"
+	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	core "github.com/envoyproxy/go-control-plane/envoy/api/v2/core"
 	extauthz "github.com/envoyproxy/go-control-plane/envoy/service/auth/v2"
+	envoytype "github.com/envoyproxy/go-control-plane/envoy/type"
+	"github.com/google/uuid"
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 	"github.com/unbasical/kelon/configs"
+	apiInt "github.com/unbasical/kelon/internal/pkg/api"
 	"github.com/unbasical/kelon/pkg/api"
+	kelonv1 "github.com/unbasical/kelon/pkg/api/proto/kelon/v1"
+	"github.com/unbasical/kelon/pkg/constants"
 	"github.com/unbasical/kelon/pkg/constants/logging"
 	"github.com/unbasical/kelon/pkg/opa"
 	"google.golang.org/genproto/googleapis/rpc/code"
@@ -25,12 +37,14 @@ type Config struct {
 	Port                   uint32 `json:"port"`
 	DryRun                 bool   `json:"dry-run"`
 	EnableReflection       bool   `json:"enable-reflection"`
+	HTTPPort               uint32 `json:"http-port"`
+	HTTPPathPrefix         string `json:"http-path-prefix"`
 	AccessDecisionLogLevel string
 }
 
 type envoyExtAuthzGrpcServer struct {
 	cfg                 Config
-	appConf             *configs.AppConfig
+	appConf             atomic.Pointer[configs.AppConfig]
 	server              *grpc.Server
 	compiler            *opa.PolicyCompiler
 	preparedQueryDoOnce *sync.Once
@@ -38,31 +52,35 @@ type envoyExtAuthzGrpcServer struct {
 
 type envoyProxy struct {
 	configured bool
-	appConf    *configs.AppConfig
-	config     *api.ClientProxyConfig
 	envoy      *envoyExtAuthzGrpcServer
+	http       *envoyExtAuthzHTTPServer
+	decisions  *decisionServer
 }
 
 // NewEnvoyProxy instantiates an api.ClientProxy implementation which provides OPA's Data-REST-API.
 func NewEnvoyProxy(config Config) api.ClientProxy {
-	if config.Port == 0 {
+	if config.Port == 0 && config.HTTPPort == 0 {
 		logging.LogForComponent("Config").Warnln("EnvoyProxy was initialized with default properties! You may have missed some arguments when creating it!")
 		config.Port = 9191
 		config.DryRun = false
 		config.EnableReflection = true
 	}
 
+	grpcServer := &envoyExtAuthzGrpcServer{
+		cfg:                 config,
+		server:              nil,
+		compiler:            nil,
+		preparedQueryDoOnce: nil,
+	}
 	return &envoyProxy{
 		configured: false,
-		appConf:    nil,
-		config:     nil,
-		envoy: &envoyExtAuthzGrpcServer{
-			cfg:                 config,
-			appConf:             nil,
-			server:              nil,
-			compiler:            nil,
-			preparedQueryDoOnce: nil,
+		envoy:      grpcServer,
+		http: &envoyExtAuthzHTTPServer{
+			cfg:     config,
+			checker: grpcServer,
+			server:  nil,
 		},
+		decisions: &decisionServer{},
 	}
 }
 
@@ -84,25 +102,49 @@ func (proxy *envoyProxy) Configure(_ context.Context, appConf *configs.AppConfig
 
 	// Assign variables
 	proxy.envoy.compiler = &compiler
-	proxy.appConf = appConf
-	proxy.config = serverConf
+	proxy.envoy.appConf.Store(appConf)
+	proxy.decisions.config.Store(serverConf)
 	proxy.configured = true
-	proxy.envoy.appConf = appConf
 	logging.LogForComponent("envoyProxy").Infoln("Configured")
 	return nil
 }
 
+// Reload - see api.ReloadableClientProxy
+func (proxy *envoyProxy) Reload(appConf *configs.AppConfig, serverConf *api.ClientProxyConfig) error {
+	if !proxy.configured {
+		return errors.Errorf("EnvoyProxy was not configured! Please call Configure(). ")
+	}
+
+	proxy.envoy.appConf.Store(appConf)
+	proxy.decisions.config.Store(serverConf)
+	logging.LogForComponent("envoyProxy").Infoln("Reloaded EnvoyProxy")
+	return nil
+}
+
 // Start - see api.ClientProxy
 func (proxy *envoyProxy) Start() error {
 	if !proxy.configured {
 		return errors.Errorf("EnvoyProxy was not configured! Please call Configure(). ")
 	}
 
+	// The HTTP service can be used by clusters, which are unable to run gRPC filters
+	if proxy.http.cfg.HTTPPort != 0 {
+		logging.LogForComponent("envoyProxy").Infof("Starting envoy http-server at: http://0.0.0.0:%d", proxy.http.cfg.HTTPPort)
+		if err := proxy.http.Start(context.Background()); err != nil {
+			return err
+		}
+	}
+	if proxy.envoy.cfg.Port == 0 {
+		return nil
+	}
+
 	// Init grpc server
 	options := proxy.makeServerInterceptor()
 	proxy.envoy.server = grpc.NewServer(options...)
 	// Register Authorization Server
 	extauthz.RegisterAuthorizationServer(proxy.envoy.server, proxy.envoy)
+	// Register Kelon's native API
+	kelonv1.RegisterDecisionServiceServer(proxy.envoy.server, proxy.decisions)
 
 	// Register reflection service on gRPC server
 	if proxy.envoy.cfg.EnableReflection {
@@ -115,15 +157,21 @@ func (proxy *envoyProxy) Start() error {
 
 // Stop - see api.ClientProxy
 func (proxy *envoyProxy) Stop(deadline time.Duration) error {
-	if proxy.envoy.server == nil {
+	if proxy.envoy.server == nil && proxy.http.server == nil {
 		return errors.Errorf("EnvoyProxy has not bin started yet")
 	}
 
-	logging.LogForComponent("envoyProxy").Infof("Stopping envoy grpc-server at: http://0.0.0.0:%d", proxy.envoy.cfg.Port)
 	ctx, cancel := context.WithTimeout(context.Background(), deadline)
 	defer cancel()
 
-	proxy.envoy.Stop(ctx)
+	if proxy.http.server != nil {
+		logging.LogForComponent("envoyProxy").Infof("Stopping envoy http-server at: http://0.0.0.0:%d", proxy.http.cfg.HTTPPort)
+		proxy.http.Stop(ctx)
+	}
+	if proxy.envoy.server != nil {
+		logging.LogForComponent("envoyProxy").Infof("Stopping envoy grpc-server at: http://0.0.0.0:%d", proxy.envoy.cfg.Port)
+		proxy.envoy.Stop(ctx)
+	}
 	return nil
 }
 
@@ -163,93 +211,200 @@ func (p *envoyExtAuthzGrpcServer) listen() {
 	logging.LogForComponent("envoyExtAuthzGrpcServer").Info("Listener exited.")
 }
 
+// checkAttributes are the attributes of the http request checked by Envoy, independent of the transport (gRPC or HTTP)
+type checkAttributes struct {
+	method               string
+	path                 string
+	body                 string
+	headers              map[string]string
+	requestID            string
+	sourcePrincipal      string
+	destinationPrincipal string
+}
+
 // Check a new incoming request
 func (p *envoyExtAuthzGrpcServer) Check(ctx context.Context, req *extauthz.CheckRequest) (*extauthz.CheckResponse, error) {
-	// Rebuild http request
-	r := req.GetAttributes().GetRequest().GetHttp()
-	path := r.GetPath()
-	if r.Query != "" {
-		path = fmt.Sprintf("%s?%s", path, r.GetQuery())
-	}
-	body := r.GetBody()
-	if body == "" {
-		body = "{}"
+	decisionID, decision, err := p.check(ctx, grpcCheckAttributes(req))
+	if err != nil {
+		return nil, errors.Wrapf(err, "EnvoyProxy: Error during request compilation of decision %s", decisionID)
 	}
 
-	token := ""
-	if tokenHeader, ok := r.GetHeaders()["authorization"]; ok {
-		token = tokenHeader
+	// The decision ID is returned in the headers of the CheckResponse, so that it can be traced end to end
+	headers := responseHeaders(decisionID, decision.Headers)
+
+	// If dry-run mode, override the status code to unconditionally allow the request
+	// DecisionLogging should reflect what "would" have happened
+	if decision.Allow || p.cfg.DryRun {
+		return &extauthz.CheckResponse{
+			Status: &rpcstatus.Status{Code: int32(code.Code_OK)},
+			HttpResponse: &extauthz.CheckResponse_OkResponse{
+				OkResponse: &extauthz.OkHttpResponse{Headers: headers},
+			},
+		}, nil
 	}
 
-	inputBody := make(map[string]any)
-	inputBody["method"] = r.GetMethod()
-	inputBody["path"] = path
-	inputBody["token"] = token
-	inputBody["payload"] = body
+	status := &rpcstatus.Status{Code: int32(code.Code_PERMISSION_DENIED)}
+	httpStatus := envoytype.StatusCode_Forbidden
+	if !decision.Verify {
+		status = &rpcstatus.Status{Code: int32(code.Code_UNAUTHENTICATED)}
+		httpStatus = envoytype.StatusCode_Unauthorized
+	}
+	return &extauthz.CheckResponse{
+		Status: status,
+		HttpResponse: &extauthz.CheckResponse_DeniedResponse{
+			DeniedResponse: &extauthz.DeniedHttpResponse{
+				Status:  &envoytype.HttpStatus{Code: httpStatus},
+				Headers: headers,
+				Body:    deniedBody(decision),
+			},
+		},
+	}, nil
+}
 
-	decision, err := (*p.compiler).Execute(ctx, inputBody)
+// check makes the decision about the request checked by Envoy. It is shared by the gRPC and the HTTP service.
+func (p *envoyExtAuthzGrpcServer) check(ctx context.Context, attributes checkAttributes) (string, *opa.Decision, error) {
+	decisionID := uuid.New().String()
+	decision, err := (*p.compiler).Execute(opa.WithDecisionID(ctx, decisionID), map[string]any{constants.Input: p.checkInput(attributes)})
 	if err != nil {
-		proxyErr := errors.Wrap(err, "EnvoyProxy: Error during request compilation")
-		return nil, proxyErr
-	}
-
-	resp := &extauthz.CheckResponse{}
-
-	var reason string
-	var logDecision string
-	if decision.Allow {
-		logDecision = "ALLOW"
-		resp.Status = &rpcstatus.Status{Code: int32(code.Code_OK)}
-	} else {
-		logDecision = "DENY"
-		if !decision.Verify {
-			reason = "Unauthenticated"
-			resp.Status = &rpcstatus.Status{Code: int32(code.Code_UNAUTHENTICATED)}
-		} else {
-			reason = "Unauthorized"
-			resp.Status = &rpcstatus.Status{Code: int32(code.Code_PERMISSION_DENIED)}
-		}
+		return decisionID, nil, err
 	}
 
 	if log.IsLevelEnabled(log.DebugLevel) {
 		logFields := log.Fields{
-			"dry-run":             p.cfg.DryRun,
-			logging.LabelDecision: logDecision,
+			"dry-run":               p.cfg.DryRun,
+			logging.LabelDecision:   "ALLOW",
+			logging.LabelDecisionID: decisionID,
 		}
 
 		if !decision.Allow {
-			logFields[logging.LabelReason] = reason
+			logFields[logging.LabelDecision] = "DENY"
+			logFields[logging.LabelReason] = "Unauthorized"
+			if !decision.Verify {
+				logFields[logging.LabelReason] = "Unauthenticated"
+			}
+		}
+		if decision.Revision != "" {
+			logFields[logging.LabelRevision] = decision.Revision
 		}
 
 		logging.LogForComponent("envoyExtAuthzGrpcServer").
 			WithFields(logFields).
-			WithError(err).
 			Debug("Returning policy decision.")
 	}
+	return decisionID, decision, nil
+}
 
-	// If dry-run mode, override the status code to unconditionally allow the request
-	// DecisionLogging should reflect what "would" have happened
-	if p.cfg.DryRun {
-		if resp.Status.Code != int32(code.Code_OK) {
-			resp.Status = &rpcstatus.Status{Code: int32(code.Code_OK)}
-			resp.HttpResponse = &extauthz.CheckResponse_OkResponse{
-				OkResponse: &extauthz.OkHttpResponse{},
-			}
-		}
+// grpcCheckAttributes extracts the attributes of the http request from the CheckRequest
+func grpcCheckAttributes(req *extauthz.CheckRequest) checkAttributes {
+	r := req.GetAttributes().GetRequest().GetHttp()
+	path := r.GetPath()
+	if r.Query != "" {
+		path = fmt.Sprintf("%s?%s", path, r.GetQuery())
+	}
+
+	// Envoy passes all headers in lower case
+	attributes := checkAttributes{
+		method:               r.GetMethod(),
+		path:                 path,
+		body:                 r.GetBody(),
+		headers:              r.GetHeaders(),
+		requestID:            r.GetHeaders()["x-request-id"],
+		sourcePrincipal:      req.GetAttributes().GetSource().GetPrincipal(),
+		destinationPrincipal: req.GetAttributes().GetDestination().GetPrincipal(),
 	}
+	if attributes.requestID == "" {
+		attributes.requestID = r.GetId()
+	}
+	return attributes
+}
+
+// checkInput rebuilds the input of the http request, which is checked by Envoy, in the same shape as the input of
+// REST callers. JSON bodies are decoded, all other bodies are passed as string. The configured header mappings are
+// applied and the peer principals (i.e. Istio's SPIFFE IDs) as well as the request ID are added if present.
+func (p *envoyExtAuthzGrpcServer) checkInput(attributes checkAttributes) map[string]any {
+	input := map[string]any{
+		"method":  attributes.method,
+		"path":    attributes.path,
+		"token":   attributes.headers["authorization"],
+		"payload": checkPayload(attributes.body, attributes.headers["content-type"]),
+	}
+	header := make(http.Header, len(attributes.headers))
+	for name, value := range attributes.headers {
+		header.Set(name, value)
+	}
+	apiInt.ApplyHeaderMappings(p.appConf.Load().Global.Input.HeaderMapping, input, header)
 
-	return resp, nil
+	if attributes.sourcePrincipal != "" {
+		input["source_principal"] = attributes.sourcePrincipal
+	}
+	if attributes.destinationPrincipal != "" {
+		input["destination_principal"] = attributes.destinationPrincipal
+	}
+	if attributes.requestID != "" {
+		input["request_id"] = attributes.requestID
+	}
+	return input
+}
+
+// checkPayload decodes JSON bodies. All other bodies and invalid JSON are returned as string.
+func checkPayload(body, contentType string) any {
+	if body == "" {
+		body = "{}"
+	}
+	if mediaType, _, err := mime.ParseMediaType(contentType); err != nil || (mediaType != "application/json" && !strings.HasSuffix(mediaType, "+json")) {
+		return body
+	}
+
+	var payload any
+	if err := json.Unmarshal([]byte(body), &payload); err != nil {
+		logging.LogForComponent("envoyExtAuthzGrpcServer").Debugf("Passing invalid JSON body as string: %s", err)
+		return body
+	}
+	return payload
+}
+
+// responseHeaders returns the decision ID and the headers of the policy, which are passed to the upstream (if allowed)
+// or the downstream client (if denied). The headers are sorted to keep the response stable.
+func responseHeaders(decisionID string, policyHeaders map[string]string) []*core.HeaderValueOption {
+	names := make([]string, 0, len(policyHeaders))
+	for name := range policyHeaders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	headers := make([]*core.HeaderValueOption, 0, len(names)+1)
+	headers = append(headers, &core.HeaderValueOption{Header: &core.HeaderValue{Key: constants.HeaderDecisionID, Value: decisionID}})
+	for _, name := range names {
+		headers = append(headers, &core.HeaderValueOption{Header: &core.HeaderValue{Key: name, Value: policyHeaders[name]}})
+	}
+	return headers
+}
+
+// deniedBody returns the additional results of the policy (i.e. the reason) as JSON, which is sent to the downstream client
+func deniedBody(decision *opa.Decision) string {
+	results := decision.Results()
+	delete(results, "headers")
+	if len(results) == 0 {
+		return ""
+	}
+	body, err := json.Marshal(results)
+	if err != nil {
+		logging.LogForComponent("envoyExtAuthzGrpcServer").Warnf("Unable to marshal results of decision %s: %s", decision.ID, err)
+		return ""
+	}
+	return string(body)
 }
 
 func (proxy *envoyProxy) makeServerInterceptor() []grpc.ServerOption {
 	var options []grpc.ServerOption
 
-	if proxy.appConf.MetricsProvider != nil {
-		options = append(options, grpc.StatsHandler(proxy.appConf.MetricsProvider.GetGrpcInstrumentationHandler()))
+	appConf := proxy.envoy.appConf.Load()
+	if appConf.MetricsProvider != nil {
+		options = append(options, grpc.StatsHandler(appConf.MetricsProvider.GetGrpcInstrumentationHandler()))
 	}
 
-	if proxy.appConf.TraceProvider != nil {
-		options = append(options, grpc.StatsHandler(proxy.appConf.TraceProvider.GetGrpcInstrumentationHandler()))
+	if appConf.TraceProvider != nil {
+		options = append(options, grpc.StatsHandler(appConf.TraceProvider.GetGrpcInstrumentationHandler()))
 	}
 
 	return options
@@ -8,8 +8,10 @@ import (
 	"github.com/open-policy-agent/opa/v1/plugins"
 	"github.com/open-policy-agent/opa/v1/util"
 	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
 	"github.com/unbasical/kelon/configs"
 	"github.com/unbasical/kelon/pkg/api"
+	"github.com/unbasical/kelon/pkg/constants"
 	"github.com/unbasical/kelon/pkg/constants/logging"
 	"github.com/unbasical/kelon/pkg/opa"
 	"github.com/unbasical/kelon/pkg/telemetry"
@@ -105,4 +107,61 @@ func TestCheckAllow(t *testing.T) {
 	if output.Status.Code != int32(code.Code_OK) {
 		t.Fatal("Expected request to be allowed but got:", output)
 	}
+	headers := output.GetOkResponse().GetHeaders()
+	if len(headers) != 1 || headers[0].GetHeader().GetKey() != constants.HeaderDecisionID || headers[0].GetHeader().GetValue() == "" {
+		t.Fatal("Expected decision ID in the response headers but got:", headers)
+	}
+}
+
+func TestCheckInput(t *testing.T) {
+	var req extauthz.CheckRequest
+	if err := util.Unmarshal([]byte(exampleAllowedRequest), &req); err != nil {
+		t.Fatal(err)
+	}
+	req.GetAttributes().GetRequest().GetHttp().GetHeaders()["content-type"] = "application/json; charset=utf-8"
+	req.GetAttributes().Source = &extauthz.AttributeContext_Peer{Principal: "spiffe://cluster.local/ns/default/sa/frontend"}
+
+	appConf := &configs.AppConfig{}
+	appConf.Global.Input.HeaderMapping = []*configs.HeaderMapping{{Name: "User-Agent", Alias: "agent"}}
+	server := &envoyExtAuthzGrpcServer{}
+	server.appConf.Store(appConf)
+
+	assert.Equal(t, map[string]any{
+		"method":           "POST",
+		"path":             "/api/v1/products",
+		"token":            "Basic Ym9iOnBhc3N3b3Jk",
+		"payload":          map[string]any{"firstname": "foo", "lastname": "bar"},
+		"agent":            "curl/7.54.0",
+		"source_principal": "spiffe://cluster.local/ns/default/sa/frontend",
+		"request_id":       "92a6c0f7-0250-944b-9cfc-ae10cbcedd8e",
+	}, server.checkInput(grpcCheckAttributes(&req)))
+}
+
+func TestCheckInput_ReloadedHeaderMapping(t *testing.T) {
+	var req extauthz.CheckRequest
+	if err := util.Unmarshal([]byte(exampleAllowedRequest), &req); err != nil {
+		t.Fatal(err)
+	}
+
+	var compiler opa.PolicyCompiler = mockCompiler{decision: true}
+	appConf := &configs.AppConfig{}
+	appConf.Global.Input.HeaderMapping = []*configs.HeaderMapping{{Name: "User-Agent", Alias: "agent"}}
+	proxy := NewEnvoyProxy(Config{Port: 9191}).(*envoyProxy)
+	assert.NoError(t, proxy.Configure(context.Background(), appConf, &api.ClientProxyConfig{Compiler: &compiler}))
+	assert.Equal(t, "curl/7.54.0", proxy.envoy.checkInput(grpcCheckAttributes(&req))["agent"])
+
+	// The header mapping of the reloaded configuration is applied
+	reloaded := &configs.AppConfig{}
+	reloaded.Global.Input.HeaderMapping = []*configs.HeaderMapping{{Name: "X-B3-TraceId", Alias: "trace"}}
+	assert.NoError(t, proxy.Reload(reloaded, &api.ClientProxyConfig{Compiler: &compiler}))
+	input := proxy.envoy.checkInput(grpcCheckAttributes(&req))
+	assert.NotContains(t, input, "agent")
+	assert.Equal(t, "537f473f27475073", input["trace"])
+}
+
+func TestCheckPayload(t *testing.T) {
+	assert.Equal(t, "a=b", checkPayload("a=b", "application/x-www-form-urlencoded"))
+	assert.Equal(t, "{invalid", checkPayload("{invalid", "application/json"))
+	assert.Equal(t, map[string]any{}, checkPayload("", "application/json"))
+	assert.Equal(t, []any{"foo"}, checkPayload(`["foo"]`, "application/problem+json"))
 }
@@ -0,0 +1,134 @@
+package envoy
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/unbasical/kelon/pkg/constants"
+	"github.com/unbasical/kelon/pkg/constants/logging"
+)
+
+// Limits of the http-server, which protect it against slow or oversized requests
+const (
+	httpReadHeaderTimeout = 5 * time.Second
+	httpMaxBodySize       = 1 << 20
+)
+
+// envoyExtAuthzHTTPServer implements Envoy's HTTP ext_authz service. Envoy forwards the method, the path (prepended
+// with the configured path_prefix), the allowed headers and optionally the body of the checked request.
+// The decision is shared with the envoyExtAuthzGrpcServer.
+type envoyExtAuthzHTTPServer struct {
+	cfg     Config
+	checker *envoyExtAuthzGrpcServer
+	server  *http.Server
+}
+
+// Start the underlying http-server. The port is bound before Start returns, so that errors (i.e. the port is
+// already in use) are returned instead of being logged in the background.
+func (p *envoyExtAuthzHTTPServer) Start(_ context.Context) error {
+	server := &http.Server{
+		Handler:           p,
+		Addr:              fmt.Sprintf(":%d", p.cfg.HTTPPort),
+		ReadTimeout:       10 * time.Second,
+		WriteTimeout:      10 * time.Second,
+		ReadHeaderTimeout: httpReadHeaderTimeout,
+	}
+	listener, err := net.Listen("tcp", server.Addr)
+	if err != nil {
+		return errors.Wrapf(err, "EnvoyProxy: Unable to start http-server at port %d", p.cfg.HTTPPort)
+	}
+
+	p.server = server
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logging.LogForComponent("envoyExtAuthzHTTPServer").Warn(err)
+		}
+	}()
+	return nil
+}
+
+// Stop the underlying http-server
+func (p *envoyExtAuthzHTTPServer) Stop(ctx context.Context) {
+	if err := p.server.Shutdown(ctx); err != nil {
+		logging.LogForComponent("envoyExtAuthzHTTPServer").Warn(err)
+	}
+}
+
+// ServeHTTP answers the check with 200 to allow the request. Denied requests are answered with 401 or 403 and the
+// reason and obligations of the policy as body. The decision ID and the headers of the policy are returned in both
+// cases, Envoy passes them on according to allowed_upstream_headers and allowed_client_headers.
+func (p *envoyExtAuthzHTTPServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	attributes, err := p.httpCheckAttributes(w, r)
+	if err != nil {
+		logging.LogForComponent("envoyExtAuthzHTTPServer").Errorf("Unable to read checked request: %s", err)
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			return
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	decisionID, decision, err := p.checker.check(r.Context(), attributes)
+	w.Header().Set(constants.HeaderDecisionID, decisionID)
+	if err != nil {
+		logging.LogForComponent("envoyExtAuthzHTTPServer").WithField(logging.LabelDecisionID, decisionID).Errorf("EnvoyProxy: Error during request compilation: %s", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	for name, value := range decision.Headers {
+		w.Header().Set(name, value)
+	}
+
+	// If dry-run mode, override the status code to unconditionally allow the request
+	if decision.Allow || p.cfg.DryRun {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	status := http.StatusForbidden
+	if !decision.Verify {
+		status = http.StatusUnauthorized
+	}
+	body := deniedBody(decision)
+	if body != "" {
+		w.Header().Set("Content-Type", "application/json")
+	}
+	w.WriteHeader(status)
+	_, _ = io.WriteString(w, body)
+}
+
+// httpCheckAttributes extracts the attributes of the checked request from the request forwarded by Envoy.
+// The configured path prefix is removed from the path and bodies larger than httpMaxBodySize are rejected.
+func (p *envoyExtAuthzHTTPServer) httpCheckAttributes(w http.ResponseWriter, r *http.Request) (checkAttributes, error) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, httpMaxBodySize))
+	if err != nil {
+		return checkAttributes{}, err
+	}
+
+	headers := make(map[string]string, len(r.Header))
+	for name, values := range r.Header {
+		headers[strings.ToLower(name)] = strings.Join(values, ",")
+	}
+
+	path := strings.TrimPrefix(r.URL.RequestURI(), p.cfg.HTTPPathPrefix)
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+
+	return checkAttributes{
+		method:    r.Method,
+		path:      path,
+		body:      string(body),
+		headers:   headers,
+		requestID: headers["x-request-id"],
+	}, nil
+}
@@ -0,0 +1,87 @@
+package envoy
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/unbasical/kelon/configs"
+	"github.com/unbasical/kelon/pkg/constants"
+	"github.com/unbasical/kelon/pkg/opa"
+)
+
+func newTestHTTPServer(dryRun bool) *envoyExtAuthzHTTPServer {
+	var compiler opa.PolicyCompiler = pathCompiler{}
+	cfg := Config{HTTPPort: 9292, HTTPPathPrefix: "/authz", DryRun: dryRun}
+	checker := &envoyExtAuthzGrpcServer{cfg: cfg, compiler: &compiler}
+	checker.appConf.Store(&configs.AppConfig{})
+	return &envoyExtAuthzHTTPServer{cfg: cfg, checker: checker}
+}
+
+func TestServeHTTPAllow(t *testing.T) {
+	w := httptest.NewRecorder()
+	newTestHTTPServer(false).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/authz/allowed", http.NoBody))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotEmpty(t, w.Header().Get(constants.HeaderDecisionID))
+}
+
+func TestServeHTTPDeny(t *testing.T) {
+	w := httptest.NewRecorder()
+	newTestHTTPServer(false).ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/authz/allowed", strings.NewReader(`{"name": "foo"}`)))
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.JSONEq(t, `{"obligations": ["log-access"]}`, w.Body.String())
+	assert.NotEmpty(t, w.Header().Get(constants.HeaderDecisionID))
+}
+
+func TestServeHTTPDryRun(t *testing.T) {
+	w := httptest.NewRecorder()
+	newTestHTTPServer(true).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/authz/denied", http.NoBody))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Body.String())
+}
+
+func TestHTTPCheckAttributes(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPut, "/authz/api/apps/1?force=true", strings.NewReader(`{"name": "foo"}`))
+	r.Header.Set("Authorization", "Bearer abc")
+	r.Header.Set("X-Request-Id", "92a6c0f7")
+
+	attributes, err := newTestHTTPServer(false).httpCheckAttributes(httptest.NewRecorder(), r)
+	assert.NoError(t, err)
+	assert.Equal(t, "PUT", attributes.method)
+	assert.Equal(t, "/api/apps/1?force=true", attributes.path)
+	assert.Equal(t, `{"name": "foo"}`, attributes.body)
+	assert.Equal(t, "Bearer abc", attributes.headers["authorization"])
+	assert.Equal(t, "92a6c0f7", attributes.requestID)
+}
+
+func TestServeHTTPBodyTooLarge(t *testing.T) {
+	w := httptest.NewRecorder()
+	body := strings.NewReader(strings.Repeat("x", httpMaxBodySize+1))
+	newTestHTTPServer(false).ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/authz/allowed", body))
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+}
+
+func TestHTTPServerStart(t *testing.T) {
+	// The port is already in use
+	listener, err := net.Listen("tcp", ":0")
+	assert.NoError(t, err)
+	defer func() { _ = listener.Close() }()
+
+	server := newTestHTTPServer(false)
+	server.cfg.HTTPPort = uint32(listener.Addr().(*net.TCPAddr).Port)
+	assert.Error(t, server.Start(context.Background()))
+	assert.Nil(t, server.server)
+
+	server.cfg.HTTPPort = 0
+	assert.NoError(t, server.Start(context.Background()))
+	assert.Equal(t, httpReadHeaderTimeout, server.server.ReadHeaderTimeout)
+	server.Stop(context.Background())
+}
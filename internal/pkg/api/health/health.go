@@ -0,0 +1,167 @@
+// Package health contains the health checks, which are shared by the REST- and the Envoy-Proxy.
+package health
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/open-policy-agent/opa/v1/plugins"
+	"github.com/unbasical/kelon/pkg/api"
+	"github.com/unbasical/kelon/pkg/data"
+	"github.com/unbasical/kelon/pkg/opa"
+	"github.com/unbasical/kelon/pkg/watcher"
+)
+
+// Health states, ordered by severity
+const (
+	StatusHealthy   = "healthy"
+	StatusDegraded  = "degraded"
+	StatusUnhealthy = "unhealthy"
+)
+
+// checkTimeout is the time each datastore has to answer the ping of a health check
+const checkTimeout = 2 * time.Second
+
+// Report is the health of kelon together with the health of its dependencies
+type Report struct {
+	Status        string               `json:"status"`
+	Datastores    map[string]Datastore `json:"datastores,omitempty"`
+	Plugins       map[string]Component `json:"plugins,omitempty"`
+	ConfigWatcher *Component           `json:"config-watcher,omitempty"`
+}
+
+// Datastore is the health of a single datastore
+type Datastore struct {
+	Status  string `json:"status"`
+	Circuit string `json:"circuit,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// Component is the health of one of OPA's plugins or of the config watcher
+type Component struct {
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
+}
+
+// Check reports the health of all datastores, OPA's plugins and the config watcher of the configuration.
+// Kelon is only unhealthy if it is unhealthy itself (i.e. its config watcher stopped). Unreachable datastores and
+// failing plugins only degrade it, because restarting kelon wouldn't fix them.
+func Check(ctx context.Context, config *api.ClientProxyConfig) Report {
+	report := Report{
+		Status:     StatusHealthy,
+		Datastores: checkDatastores(ctx, config.Datastores),
+		Plugins:    checkPlugins(*config.Compiler),
+	}
+	for _, ds := range report.Datastores {
+		report.Status = worse(report.Status, dependency(ds.Status))
+	}
+	for _, plugin := range report.Plugins {
+		report.Status = worse(report.Status, dependency(plugin.Status))
+	}
+
+	if report.ConfigWatcher = checkConfigWatcher(config.ConfigWatcher); report.ConfigWatcher != nil {
+		report.Status = worse(report.Status, report.ConfigWatcher.Status)
+	}
+	return report
+}
+
+// checkPlugins maps the status of OPA's plugins (e.g. bundle and discovery) to health states
+func checkPlugins(compiler opa.PolicyCompiler) map[string]Component {
+	statuses := compiler.GetEngine().PluginStatus()
+	result := make(map[string]Component, len(statuses))
+	for name, status := range statuses {
+		plugin := Component{Status: pluginStatus(status)}
+		if status != nil {
+			plugin.Message = status.Message
+		}
+		result[name] = plugin
+	}
+	return result
+}
+
+// checkConfigWatcher checks whether the config watcher still watches for changes.
+// If the watcher does not run in the background (e.g. without rego dir), nil is returned.
+func checkConfigWatcher(configWatcher *watcher.ConfigWatcher) *Component {
+	if configWatcher == nil {
+		return nil
+	}
+	reporter, ok := (*configWatcher).(watcher.LivenessReporter)
+	if !ok {
+		return nil
+	}
+
+	if !reporter.Alive() {
+		return &Component{Status: StatusUnhealthy, Message: "config watcher stopped"}
+	}
+	return &Component{Status: StatusHealthy}
+}
+
+// checkDatastores pings all datastores in parallel. Datastores with an open circuit are degraded and
+// datastores, which don't answer the ping, are unhealthy.
+func checkDatastores(ctx context.Context, datastores map[string]*data.Datastore) map[string]Datastore {
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+
+	var (
+		mutex  sync.Mutex
+		wg     sync.WaitGroup
+		result = make(map[string]Datastore, len(datastores))
+	)
+	for alias, ds := range datastores {
+		wg.Add(1)
+		go func(alias string, ds data.Datastore) {
+			defer wg.Done()
+
+			health := Datastore{Status: StatusHealthy}
+			if breaker, ok := ds.(data.CircuitBreaker); ok {
+				health.Circuit = breaker.CircuitState()
+				if health.Circuit != data.CircuitClosed {
+					health.Status = StatusDegraded
+				}
+			}
+			if pinger, ok := ds.(data.Pinger); ok {
+				if err := pinger.Ping(ctx); err != nil {
+					health.Status = StatusUnhealthy
+					health.Error = err.Error()
+				}
+			}
+
+			mutex.Lock()
+			result[alias] = health
+			mutex.Unlock()
+		}(alias, *ds)
+	}
+	wg.Wait()
+	return result
+}
+
+// pluginStatus maps the status of one of OPA's plugins (e.g. bundle and discovery) to a health state
+func pluginStatus(status *plugins.Status) string {
+	switch {
+	case status == nil || status.State == plugins.StateOK:
+		return StatusHealthy
+	case status.State == plugins.StateErr:
+		return StatusUnhealthy
+	default:
+		return StatusDegraded
+	}
+}
+
+// worse returns the more severe of both health states
+func worse(current, other string) string {
+	severity := map[string]int{StatusHealthy: 0, StatusDegraded: 1, StatusUnhealthy: 2}
+	if severity[other] > severity[current] {
+		return other
+	}
+	return current
+}
+
+// dependency returns the status, which an external dependency contributes to the health of kelon.
+// Unhealthy dependencies only degrade kelon.
+func dependency(status string) string {
+	if status == StatusUnhealthy {
+		return StatusDegraded
+	}
+	return status
+}
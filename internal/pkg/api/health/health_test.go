@@ -0,0 +1,75 @@
+package health
+
+import (
+	"context"
+	"testing"
+
+	"github.com/open-policy-agent/opa/v1/plugins"
+	"github.com/open-policy-agent/opa/v1/storage/inmem"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/unbasical/kelon/configs"
+	"github.com/unbasical/kelon/pkg/api"
+	"github.com/unbasical/kelon/pkg/data"
+	"github.com/unbasical/kelon/pkg/opa"
+	"github.com/unbasical/kelon/pkg/watcher"
+)
+
+type engineCompiler struct {
+	opa.PolicyCompiler
+	engine *plugins.Manager
+}
+
+func (c engineCompiler) GetEngine() *plugins.Manager {
+	return c.engine
+}
+
+type pingDatastore struct {
+	data.Datastore
+	err error
+}
+
+func (ds *pingDatastore) Ping(_ context.Context) error {
+	return ds.err
+}
+
+type livenessWatcher struct {
+	alive bool
+}
+
+func (w *livenessWatcher) Watch(_ func(watcher.ChangeType, []string, *configs.ExternalConfig, error)) {
+}
+
+func (w *livenessWatcher) Alive() bool {
+	return w.alive
+}
+
+func TestCheck(t *testing.T) {
+	engine, err := plugins.New(nil, "test", inmem.New())
+	assert.NoError(t, err)
+	var (
+		compiler      opa.PolicyCompiler    = engineCompiler{engine: engine}
+		ds            data.Datastore        = &pingDatastore{}
+		configWatcher watcher.ConfigWatcher = &livenessWatcher{alive: true}
+	)
+	config := &api.ClientProxyConfig{Compiler: &compiler}
+	config.Datastores = map[string]*data.Datastore{"mysql": &ds}
+	config.ConfigWatcher = &configWatcher
+
+	report := Check(context.Background(), config)
+	assert.Equal(t, StatusHealthy, report.Status)
+	assert.Equal(t, map[string]Datastore{"mysql": {Status: StatusHealthy}}, report.Datastores)
+	assert.Equal(t, &Component{Status: StatusHealthy}, report.ConfigWatcher)
+
+	// Unreachable datastores only degrade kelon
+	ds.(*pingDatastore).err = errors.New("connection refused")
+	report = Check(context.Background(), config)
+	assert.Equal(t, StatusDegraded, report.Status)
+	assert.Equal(t, StatusUnhealthy, report.Datastores["mysql"].Status)
+
+	// A stopped config watcher makes kelon unhealthy
+	configWatcher.(*livenessWatcher).alive = false
+	report = Check(context.Background(), config)
+	assert.Equal(t, StatusUnhealthy, report.Status)
+	assert.Equal(t, &Component{Status: StatusUnhealthy, Message: "config watcher stopped"}, report.ConfigWatcher)
+}
@@ -0,0 +1,106 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/open-policy-agent/opa/v1/server/types"
+	opaUtil "github.com/open-policy-agent/opa/v1/util"
+	"github.com/pkg/errors"
+	"github.com/unbasical/kelon/internal/pkg/util"
+	"github.com/unbasical/kelon/pkg/api"
+	"github.com/unbasical/kelon/pkg/constants"
+	"github.com/unbasical/kelon/pkg/constants/logging"
+	"github.com/unbasical/kelon/pkg/data"
+	internalErrors "github.com/unbasical/kelon/pkg/errors"
+	"github.com/unbasical/kelon/pkg/opa"
+)
+
+type batchRequest struct {
+	Inputs []any `json:"inputs"`
+}
+
+type batchResponse struct {
+	Responses []batchItemResponse `json:"responses"`
+}
+
+// batchItemResponse contains the decision or the error for a single input of the batch. The status is the one,
+// which would have been returned for the input by the Data-API.
+type batchItemResponse struct {
+	DecisionID string         `json:"decision_id,omitempty"`
+	Status     int            `json:"http_status_code"`
+	Result     map[string]any `json:"result,omitempty"`
+	Error      *types.ErrorV1 `json:"error,omitempty"`
+}
+
+// handleV1BatchDataPost makes a decision for each input of the batch. The inputs are evaluated concurrently by a bounded
+// amount of workers and identical datastore queries are executed only once.
+func (proxy *restProxy) handleV1BatchDataPost(w http.ResponseWriter, r *http.Request) {
+	config := proxy.config.Load()
+
+	var batch batchRequest
+	if err := opaUtil.NewJSONDecoder(r.Body).Decode(&batch); err != nil {
+		writeError(w, http.StatusBadRequest, types.CodeInvalidParameter, err)
+		return
+	}
+	if config.BatchMaxSize > 0 && len(batch.Inputs) > config.BatchMaxSize {
+		writeError(w, http.StatusBadRequest, types.CodeInvalidParameter, errors.Errorf("batch contains %d inputs, but at most %d are allowed", len(batch.Inputs), config.BatchMaxSize))
+		return
+	}
+
+	ctx := data.WithSharedQueries(r.Context(), &data.SharedQueries{})
+	responses := make([]batchItemResponse, len(batch.Inputs))
+	util.RunWorkers(config.BatchWorkers, len(batch.Inputs), func(item int) {
+		responses[item] = proxy.decideBatchItem(ctx, config, r, batch.Inputs[item])
+	})
+
+	logging.LogForComponent("restProxy").Debugf("Made %d decisions in batch", len(responses))
+	writeJSON(w, http.StatusOK, batchResponse{Responses: responses})
+}
+
+// decideBatchItem makes the decision for a single input of the batch and records its metrics and access log
+func (proxy *restProxy) decideBatchItem(ctx context.Context, config *api.ClientProxyConfig, r *http.Request, input any) batchItemResponse {
+	startTime := time.Now()
+	decisionID := uuid.New().String()
+	ctx = opa.WithDecisionID(ctx, decisionID)
+
+	decision, err := proxy.executeBatchItem(ctx, config, r, input)
+	if err != nil {
+		loggingInfo := wrapErrorInLoggingContext(err, decisionID)
+		if isDenyError(err) {
+			proxy.logDeny(ctx, loggingInfo)
+			logDenyError(loggingInfo)
+			status, _ := denyStatus(loggingInfo)
+			return batchItemResponse{DecisionID: decisionID, Status: status, Result: decisionResult(loggingInfo)}
+		}
+
+		logging.LogForComponent("PolicyCompiler").WithField(logging.LabelDecisionID, decisionID).Errorf("Handle error response in batch: %s", err)
+		status, code := errorStatus(err)
+		return batchItemResponse{DecisionID: decisionID, Status: status, Error: types.NewErrorV1(code, "%s", errors.Cause(err).Error())}
+	}
+
+	loggingInfo := loggingContextFromDecision(decision, time.Since(startTime))
+	status := http.StatusOK
+	if decision.Allow {
+		proxy.logAllow(ctx, loggingInfo)
+	} else {
+		status, _ = denyStatus(loggingInfo)
+		proxy.logDeny(ctx, loggingInfo)
+	}
+	return batchItemResponse{DecisionID: decisionID, Status: status, Result: decisionResult(loggingInfo)}
+}
+
+// executeBatchItem enriches the input with the configured headers of the batch request and executes it
+func (proxy *restProxy) executeBatchItem(ctx context.Context, config *api.ClientProxyConfig, r *http.Request, input any) (*opa.Decision, error) {
+	if _, ok := input.(map[string]any); !ok {
+		return nil, internalErrors.InvalidInput{Msg: fmt.Sprintf("RestProxy: Input of batch was no nested JSON object! Type was %T", input)}
+	}
+	requestBody, err := proxy.applyHeaderMappingsToInput(map[string]any{constants.Input: input}, r)
+	if err != nil {
+		return nil, err
+	}
+	return (*config.Compiler).Execute(ctx, requestBody)
+}
@@ -0,0 +1,123 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/unbasical/kelon/pkg/constants"
+	internalErrors "github.com/unbasical/kelon/pkg/errors"
+	"github.com/unbasical/kelon/pkg/opa"
+)
+
+// Headers, which are used by reverse proxies to forward the original request to the authorization service
+const (
+	headerForwardedMethod = "X-Forwarded-Method"
+	headerForwardedURI    = "X-Forwarded-Uri"
+	headerOriginalMethod  = "X-Original-Method"
+	headerOriginalURL     = "X-Original-URL"
+	headerOriginalURI     = "X-Original-URI"
+	headerAuthorization   = "Authorization"
+)
+
+// handleForwardAuth decides about the request forwarded by reverse proxies like Traefik (ForwardAuth), nginx (auth_request)
+// or Caddy (forward_auth). The input is rebuilt from the forwarded headers and answered with 200, 401 or 403.
+// The headers of the policy are returned in both cases, so that the proxy can pass them upstream or to the client.
+func (proxy *restProxy) handleForwardAuth(w http.ResponseWriter, r *http.Request) {
+	startTime := time.Now()
+	decisionID := uuid.New().String()
+	ctx := opa.WithDecisionID(r.Context(), decisionID)
+	w.Header().Set(constants.HeaderDecisionID, decisionID)
+
+	decision, err := proxy.executeForwardAuth(ctx, r)
+	if err != nil {
+		loggingInfo := wrapErrorInLoggingContext(err, decisionID)
+		if !isDenyError(err) {
+			proxy.handleError(ctx, w, loggingInfo)
+			return
+		}
+		writeForwardAuthDeny(w, loggingInfo)
+		proxy.logDeny(ctx, loggingInfo)
+		logDenyError(loggingInfo)
+		return
+	}
+
+	loggingInfo := loggingContextFromDecision(decision, time.Since(startTime))
+	for name, value := range decision.Headers {
+		w.Header().Set(name, value)
+	}
+	if decision.Allow {
+		w.WriteHeader(http.StatusOK)
+		proxy.logAllow(ctx, loggingInfo)
+	} else {
+		writeForwardAuthDeny(w, loggingInfo)
+		proxy.logDeny(ctx, loggingInfo)
+	}
+}
+
+// executeForwardAuth rebuilds the input of the forwarded request, enriches it with the configured headers and executes it
+func (proxy *restProxy) executeForwardAuth(ctx context.Context, r *http.Request) (*opa.Decision, error) {
+	input, err := forwardAuthInput(r)
+	if err != nil {
+		return nil, err
+	}
+	requestBody, err := proxy.applyHeaderMappingsToInput(map[string]any{constants.Input: input}, r)
+	if err != nil {
+		return nil, err
+	}
+	return (*proxy.config.Load().Compiler).Execute(ctx, requestBody)
+}
+
+// writeForwardAuthDeny answers with the status of the denied request and the reason and obligations of the policy as body.
+// The headers of the policy are already set as response headers.
+func writeForwardAuthDeny(w http.ResponseWriter, loggingInfo *decisionContext) {
+	status, _ := denyStatus(loggingInfo)
+	results := make(map[string]any, len(loggingInfo.Results))
+	for key, value := range loggingInfo.Results {
+		if key != "headers" {
+			results[key] = value
+		}
+	}
+	if len(results) == 0 {
+		w.WriteHeader(status)
+		return
+	}
+	writeJSON(w, status, results)
+}
+
+// forwardAuthInput rebuilds the input (method, path and token) of the original request from the forwarded headers.
+// Traefik and Caddy send X-Forwarded-Method and X-Forwarded-Uri, while nginx is usually configured to send
+// X-Original-Method and X-Original-URL or X-Original-URI.
+func forwardAuthInput(r *http.Request) (map[string]any, error) {
+	method := firstHeader(r, headerForwardedMethod, headerOriginalMethod)
+	if method == "" {
+		method = r.Method
+	}
+
+	rawPath := firstHeader(r, headerForwardedURI, headerOriginalURL, headerOriginalURI)
+	if rawPath == "" {
+		return nil, internalErrors.InvalidInput{Msg: "RestProxy: Forwarded request contained none of the headers " + headerForwardedURI + ", " + headerOriginalURL + " or " + headerOriginalURI}
+	}
+	path, err := url.Parse(rawPath)
+	if err != nil {
+		return nil, internalErrors.InvalidInput{Cause: err, Msg: "RestProxy: Forwarded request contained an invalid URI"}
+	}
+
+	return map[string]any{
+		"method": method,
+		"path":   path.RequestURI(),
+		"token":  r.Header.Get(headerAuthorization),
+	}, nil
+}
+
+// firstHeader returns the value of the first header, which is set
+func firstHeader(r *http.Request, names ...string) string {
+	for _, name := range names {
+		if value := r.Header.Get(name); value != "" {
+			return value
+		}
+	}
+	return ""
+}
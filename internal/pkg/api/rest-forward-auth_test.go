@@ -0,0 +1,68 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/unbasical/kelon/configs"
+	"github.com/unbasical/kelon/pkg/constants"
+	"github.com/unbasical/kelon/pkg/opa"
+)
+
+func forwardAuth(proxy *restProxy, headers map[string]string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, "/v1/forward-auth", http.NoBody)
+	for name, value := range headers {
+		r.Header.Set(name, value)
+	}
+	w := httptest.NewRecorder()
+	proxy.handleForwardAuth(w, r)
+	return w
+}
+
+func Test_handleForwardAuth_Traefik(t *testing.T) {
+	proxy := newTestProxy(t, opa.Decision{Allow: true, Verify: true, Headers: map[string]string{"X-User": "Torben"}}, false)
+	proxy.appConf.Load().Global.Input.HeaderMapping = []*configs.HeaderMapping{{Name: "X-Tenant", Alias: "tenant"}}
+
+	w := forwardAuth(proxy, map[string]string{
+		headerForwardedMethod: "DELETE",
+		headerForwardedURI:    "/api/apps/1?force=true",
+		headerAuthorization:   "Bearer abc",
+		"X-Tenant":            "unbasical",
+	})
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "Torben", w.Header().Get("X-User"))
+	assert.NotEmpty(t, w.Header().Get(constants.HeaderDecisionID))
+	assert.Equal(t, map[string]any{
+		"method": "DELETE",
+		"path":   "/api/apps/1?force=true",
+		"token":  "Bearer abc",
+		"tenant": "unbasical",
+	}, (*proxy.config.Load().Compiler).(*mockCompiler).input)
+}
+
+func Test_handleForwardAuth_Nginx(t *testing.T) {
+	proxy := newTestProxy(t, opa.Decision{Allow: false, Verify: true, Reason: "Not your app"}, true)
+
+	w := forwardAuth(proxy, map[string]string{
+		headerOriginalMethod: "POST",
+		headerOriginalURL:    "https://example.com/api/apps",
+	})
+
+	// Forward auth always answers with status codes, even if OPA compatible responses are configured
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.JSONEq(t, `{"reason": "Not your app"}`, w.Body.String())
+	assert.Equal(t, map[string]any{
+		"method": "POST",
+		"path":   "/api/apps",
+		"token":  "",
+	}, (*proxy.config.Load().Compiler).(*mockCompiler).input)
+}
+
+func Test_handleForwardAuth_MissingURI(t *testing.T) {
+	w := forwardAuth(newTestProxy(t, opa.Decision{Allow: true, Verify: true}, false), map[string]string{})
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -6,7 +6,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"mime"
 	"net/http"
+	"net/url"
 	"strings"
 	"time"
 
@@ -14,6 +16,7 @@ import (
 	"github.com/gorilla/mux"
 	"github.com/open-policy-agent/opa/v1/ast"
 	"github.com/open-policy-agent/opa/v1/bundle"
+	"github.com/open-policy-agent/opa/v1/metrics"
 	"github.com/open-policy-agent/opa/v1/plugins"
 	"github.com/open-policy-agent/opa/v1/server/types"
 	"github.com/open-policy-agent/opa/v1/server/writer"
@@ -35,6 +38,10 @@ type apiError struct {
 	} `json:"error"`
 }
 
+type revisionResponse struct {
+	Revision string `json:"revision"`
+}
+
 type patchImpl struct {
 	path  storage.Path
 	op    storage.PatchOp
@@ -45,10 +52,15 @@ type decisionContext struct {
 	Path           string
 	Package        string
 	Method         string
+	Revision       string
+	Allow          bool
 	Authentication bool
 	Duration       time.Duration
 	Error          error
-	CorrelationID  uuid.UUID
+	DecisionID     string
+	Metrics        metrics.Metrics
+	Results        map[string]any
+	OPAResponse    bool
 }
 
 /*
@@ -76,8 +88,9 @@ func (proxy *restProxy) handleV1DataGet(w http.ResponseWriter, r *http.Request)
 	_, _ = builder.WriteString(body)
 	_, _ = builder.WriteRune('}')
 
-	if trans, err := http.NewRequest("POST", r.URL.String(), strings.NewReader(builder.String())); err == nil {
-		// Handle request like post
+	if trans, err := http.NewRequestWithContext(r.Context(), "POST", r.URL.String(), strings.NewReader(builder.String())); err == nil {
+		// Handle request like post, the requested response body is kept
+		trans.Header.Set("Accept", r.Header.Get("Accept"))
 		proxy.handleV1DataPost(w, trans)
 	} else {
 		logging.LogForComponent("restProxy").Fatal("Unable to map GET request to POST: ", err.Error())
@@ -88,34 +101,54 @@ func (proxy *restProxy) handleV1DataPost(w http.ResponseWriter, r *http.Request)
 	// Set start time for request duration
 	startTime := time.Now()
 
-	ctx := r.Context()
+	// Every decision gets an ID, which is returned to the caller in order to find the decision in logs, audits and traces
+	decisionID := uuid.New().String()
+	ctx := opa.WithDecisionID(r.Context(), decisionID)
+	w.Header().Set(constants.HeaderDecisionID, decisionID)
+
+	// OPA's response body is returned if it is configured or requested via the Accept header.
+	// Like OPA, the metrics of the decision are only returned if they were requested.
+	config := proxy.config.Load()
+	opaResponse := config.OPACompatibleResponses || acceptsOPAResponse(r.Header)
+	var m metrics.Metrics
+	if opaResponse && getBoolParam(r.URL, "metrics", true) {
+		m = metrics.New()
+		ctx = opa.WithMetrics(ctx, m)
+	}
 
 	// Parses body of request
 	requestBody, bodyErr := proxy.parseRequestBody(r)
 	if bodyErr != nil {
-		proxy.handleError(ctx, w, wrapErrorInLoggingContext(bodyErr))
+		loggingInfo := wrapErrorInLoggingContext(bodyErr, decisionID)
+		loggingInfo.OPAResponse = opaResponse
+		proxy.handleError(ctx, w, loggingInfo)
 		return
 	}
 
-	decision, err := (*proxy.config.Compiler).Execute(ctx, requestBody)
+	decision, err := (*config.Compiler).Execute(ctx, requestBody)
 	duration := time.Since(startTime)
 
 	if err != nil {
-		proxy.handleError(ctx, w, wrapErrorInLoggingContext(err))
+		loggingInfo := wrapErrorInLoggingContext(err, decisionID)
+		loggingInfo.OPAResponse = opaResponse
+		proxy.handleError(ctx, w, loggingInfo)
 		return
 	}
 
+	loggingInfo := loggingContextFromDecision(decision, duration)
+	loggingInfo.Metrics = m
+	loggingInfo.OPAResponse = opaResponse
 	if decision.Allow {
-		proxy.writeAllow(ctx, w, loggingContextFromDecision(decision, duration))
+		proxy.writeAllow(ctx, w, loggingInfo)
 	} else {
-		proxy.writeDeny(ctx, w, loggingContextFromDecision(decision, duration))
+		proxy.writeDeny(ctx, w, loggingInfo)
 	}
 }
 
 // Migration from github.com/open-policy-agent/opa/server/server.go
 func (proxy *restProxy) handleV1DataPut(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
-	engine := (*proxy.config.Compiler).GetEngine()
+	engine := (*proxy.config.Load().Compiler).GetEngine()
 
 	// Parse input
 	var value any
@@ -159,7 +192,7 @@ func (proxy *restProxy) handleV1DataPut(w http.ResponseWriter, r *http.Request)
 // Migration from github.com/open-policy-agent/opa/server/server.go
 func (proxy *restProxy) handleV1DataPatch(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
-	engine := (*proxy.config.Compiler).GetEngine()
+	engine := (*proxy.config.Load().Compiler).GetEngine()
 
 	// Parse Path
 	path, ok := storage.ParsePathEscaped("/" + strings.Trim(r.URL.Path, "/"))
@@ -209,7 +242,7 @@ func (proxy *restProxy) handleV1DataPatch(w http.ResponseWriter, r *http.Request
 // Migration from github.com/open-policy-agent/opa/server/server.go
 func (proxy *restProxy) handleV1DataDelete(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
-	engine := (*proxy.config.Compiler).GetEngine()
+	engine := (*proxy.config.Load().Compiler).GetEngine()
 
 	// Prepare transaction
 	path, txn, err := proxy.preparePathCheckedTransaction(ctx, r.URL.Path, engine, w)
@@ -246,7 +279,7 @@ func (proxy *restProxy) handleV1DataDelete(w http.ResponseWriter, r *http.Reques
 // Migration from github.com/open-policy-agent/opa/server/server.go
 func (proxy *restProxy) handleV1PolicyGet(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
-	engine := (*proxy.config.Compiler).GetEngine()
+	engine := (*proxy.config.Load().Compiler).GetEngine()
 
 	// Parse Path
 	vars := mux.Vars(r)
@@ -286,7 +319,7 @@ func (proxy *restProxy) handleV1PolicyGet(w http.ResponseWriter, r *http.Request
 // Migration from github.com/open-policy-agent/opa/server/server.go
 func (proxy *restProxy) handleV1PolicyGetList(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
-	engine := (*proxy.config.Compiler).GetEngine()
+	engine := (*proxy.config.Load().Compiler).GetEngine()
 
 	txn, err := engine.Store.NewTransaction(ctx)
 	if err != nil {
@@ -322,10 +355,20 @@ func (proxy *restProxy) handleV1PolicyGetList(w http.ResponseWriter, r *http.Req
 	writeJSON(w, http.StatusOK, types.PolicyListResponseV1{Result: policies})
 }
 
+// handleRevision responds with the revision of the regos or bundle, which were loaded from disk
+func (proxy *restProxy) handleRevision(w http.ResponseWriter, _ *http.Request) {
+	reporter, ok := (*proxy.config.Load().Compiler).(opa.RevisionReporter)
+	if !ok {
+		writeError(w, http.StatusNotFound, types.CodeResourceNotFound, errors.Errorf("PolicyCompiler does not report revisions"))
+		return
+	}
+	writeJSON(w, http.StatusOK, revisionResponse{Revision: reporter.PolicyRevision()})
+}
+
 // Migration from github.com/open-policy-agent/opa/server/server.go
 func (proxy *restProxy) handleV1PolicyPut(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
-	engine := (*proxy.config.Compiler).GetEngine()
+	engine := (*proxy.config.Load().Compiler).GetEngine()
 
 	// Read request body
 	buf, err := io.ReadAll(r.Body)
@@ -402,7 +445,7 @@ func (proxy *restProxy) handleV1PolicyPut(w http.ResponseWriter, r *http.Request
 // Migration from github.com/open-policy-agent/opa/server/server.go
 func (proxy *restProxy) handleV1PolicyDelete(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
-	engine := (*proxy.config.Compiler).GetEngine()
+	engine := (*proxy.config.Load().Compiler).GetEngine()
 
 	// Parse Path
 	vars := mux.Vars(r)
@@ -482,6 +525,39 @@ func writeError(w http.ResponseWriter, status int, code string, err error) {
 	writeJSON(w, status, resp)
 }
 
+// Migration from github.com/open-policy-agent/opa/server/server.go
+func getBoolParam(u *url.URL, name string, ifEmpty bool) bool {
+	p, ok := u.Query()[name]
+	if !ok {
+		return false
+	}
+
+	// Query params w/o values are represented as slice (of len 1) with an empty string.
+	if len(p) == 1 && p[0] == "" {
+		return ifEmpty
+	}
+
+	for _, x := range p {
+		if strings.EqualFold(x, "true") {
+			return true
+		}
+	}
+	return false
+}
+
+// acceptsOPAResponse returns true if the Accept header requests OPA's response body (see constants.MediaTypeOPAResponse)
+func acceptsOPAResponse(header http.Header) bool {
+	for _, value := range header.Values("Accept") {
+		for _, accepted := range strings.Split(value, ",") {
+			mediaType, _, err := mime.ParseMediaType(accepted)
+			if err == nil && strings.EqualFold(mediaType, constants.MediaTypeOPAResponse) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func writeJSON(w http.ResponseWriter, status int, x any) {
 	bs, _ := json.Marshal(x)
 	w.Header().Set("Content-Type", "application/json")
@@ -492,7 +568,7 @@ func writeJSON(w http.ResponseWriter, status int, x any) {
 }
 
 func (proxy *restProxy) loadModules(ctx context.Context, txn storage.Transaction) (map[string]*ast.Module, error) {
-	engine := (*proxy.config.Compiler).GetEngine()
+	engine := (*proxy.config.Load().Compiler).GetEngine()
 
 	ids, err := engine.Store.ListPolicies(ctx, txn)
 	if err != nil {
@@ -633,7 +709,7 @@ func parsePatchPathEscaped(str string) (path storage.Path, ok bool) {
 }
 
 func (proxy *restProxy) checkPolicyIDScope(ctx context.Context, txn storage.Transaction, id string) error {
-	engine := (*proxy.config.Compiler).GetEngine()
+	engine := (*proxy.config.Load().Compiler).GetEngine()
 
 	bs, err := engine.Store.GetPolicy(ctx, txn, id)
 	if err != nil {
@@ -663,7 +739,7 @@ func (proxy *restProxy) checkPolicyPackageScope(ctx context.Context, txn storage
 }
 
 func (proxy *restProxy) checkPathScope(ctx context.Context, txn storage.Transaction, path storage.Path) error {
-	engine := (*proxy.config.Compiler).GetEngine()
+	engine := (*proxy.config.Load().Compiler).GetEngine()
 
 	names, err := bundle.ReadBundleNamesFromStore(ctx, engine.Store, txn)
 	if err != nil {
@@ -727,69 +803,105 @@ func (proxy *restProxy) parseRequestBody(req *http.Request) (map[string]any, err
 }
 
 func (proxy *restProxy) handleError(ctx context.Context, w http.ResponseWriter, loggingInfo *decisionContext) {
-	logging.LogForComponent("PolicyCompiler").Errorf("Handle error response: %s", loggingInfo.Error)
+	logging.LogForComponent("PolicyCompiler").WithField(logging.LabelDecisionID, loggingInfo.DecisionID).Errorf("Handle error response: %s", loggingInfo.Error)
 
 	// Write response
+	if isDenyError(loggingInfo.Error) {
+		proxy.writeDenyError(ctx, w, loggingInfo)
+		return
+	}
+	status, code := errorStatus(loggingInfo.Error)
+	writeError(w, status, code, loggingInfo.Error)
+}
+
+// isDenyError returns true if the request is denied because of the error instead of answering with an error
+func isDenyError(err error) bool {
+	var invalidRequestTranslation internalErrors.InvalidRequestTranslation
+	return errors.As(errors.Cause(err), &invalidRequestTranslation)
+}
+
+// errorStatus maps the error of a decision to the status and OPA's error code of the response
+func errorStatus(err error) (int, string) {
 	var pathAmbiguousError request.PathAmbiguousError
 	var pathNotFoundError request.PathNotFoundError
 	var invalidInput internalErrors.InvalidInput
-	var invalidRequestTranslation internalErrors.InvalidRequestTranslation
-	switch err := errors.Cause(loggingInfo.Error); {
+	var datastoreUnavailable internalErrors.DatastoreUnavailable
+	switch err := errors.Cause(err); {
 	case errors.As(err, &pathAmbiguousError):
-		writeError(w, http.StatusNotFound, types.CodeResourceNotFound, loggingInfo.Error)
+		return http.StatusNotFound, types.CodeResourceNotFound
 	case errors.As(err, &pathNotFoundError):
-		writeError(w, http.StatusNotFound, types.CodeResourceNotFound, loggingInfo.Error)
+		return http.StatusNotFound, types.CodeResourceNotFound
 	case errors.As(err, &invalidInput):
-		writeError(w, http.StatusBadRequest, types.CodeInvalidParameter, loggingInfo.Error)
-	case errors.As(err, &invalidRequestTranslation):
-		proxy.writeDenyError(ctx, w, loggingInfo)
+		return http.StatusBadRequest, types.CodeInvalidParameter
+	case errors.As(err, &datastoreUnavailable):
+		return http.StatusServiceUnavailable, types.CodeInternal
 	default:
-		writeError(w, http.StatusInternalServerError, types.CodeInternal, loggingInfo.Error)
+		return http.StatusInternalServerError, types.CodeInternal
 	}
 }
 
 func (proxy *restProxy) writeDenyError(ctx context.Context, w http.ResponseWriter, loggingInfo *decisionContext) {
 	proxy.writeDeny(ctx, w, loggingInfo)
+	logDenyError(loggingInfo)
+}
 
+// logDenyError logs the causes of the error, because of which the request was denied
+func logDenyError(loggingInfo *decisionContext) {
 	var err internalErrors.InvalidRequestTranslation
 	switch {
 	case errors.As(loggingInfo.Error, &err):
 		for _, e := range err.Causes {
-			logging.LogWithCorrelationID(loggingInfo.CorrelationID).Warn(e)
+			logging.LogWithDecisionID(loggingInfo.DecisionID).Warn(e)
 		}
 	default:
-		logging.LogWithCorrelationID(loggingInfo.CorrelationID).Warn(err.Error())
+		logging.LogWithDecisionID(loggingInfo.DecisionID).Warn(err.Error())
 	}
 }
 
 func (proxy *restProxy) writeAllow(ctx context.Context, w http.ResponseWriter, loggingInfo *decisionContext) {
-	w.WriteHeader(http.StatusOK)
+	proxy.writeDecision(w, loggingInfo, http.StatusOK)
+	proxy.logAllow(ctx, loggingInfo)
+}
 
+// logAllow records the metrics and access log of an allowed request
+func (proxy *restProxy) logAllow(ctx context.Context, loggingInfo *decisionContext) {
 	labels := map[string]string{
 		constants.LabelPolicyDecision: "allow",
 		constants.LabelRegoPackage:    loggingInfo.Package,
 	}
 
-	proxy.appConf.MetricsProvider.UpdateHistogramMetric(ctx, constants.InstrumentDecisionDuration, loggingInfo.Duration.Milliseconds(), labels)
+	proxy.appConf.Load().MetricsProvider.UpdateHistogramMetric(ctx, constants.InstrumentDecisionDuration, loggingInfo.Duration.Milliseconds(), labels)
 
 	logFields := log.Fields{
-		logging.LabelPath:     loggingInfo.Path,
-		logging.LabelMethod:   loggingInfo.Method,
-		logging.LabelDuration: loggingInfo.Duration.String(),
+		logging.LabelPath:       loggingInfo.Path,
+		logging.LabelMethod:     loggingInfo.Method,
+		logging.LabelDuration:   loggingInfo.Duration.String(),
+		logging.LabelDecisionID: loggingInfo.DecisionID,
+	}
+	if loggingInfo.Revision != "" {
+		logFields[logging.LabelRevision] = loggingInfo.Revision
 	}
 
-	logging.LogAccessDecision(proxy.config.AccessDecisionLogLevel, "ALLOW", "policyCompiler", logFields)
+	logging.LogAccessDecision(proxy.config.Load().AccessDecisionLogLevel, "ALLOW", "policyCompiler", logFields)
 }
 
 func (proxy *restProxy) writeDeny(ctx context.Context, w http.ResponseWriter, loggingInfo *decisionContext) {
-	var reason string
+	status, _ := denyStatus(loggingInfo)
+	proxy.writeDecision(w, loggingInfo, status)
+	proxy.logDeny(ctx, loggingInfo)
+}
+
+// denyStatus returns the status and the reason of a denied request
+func denyStatus(loggingInfo *decisionContext) (int, string) {
 	if !loggingInfo.Authentication {
-		reason = "Unauthenticated"
-		w.WriteHeader(http.StatusUnauthorized)
-	} else {
-		reason = "Unauthorized"
-		w.WriteHeader(http.StatusForbidden)
+		return http.StatusUnauthorized, "Unauthenticated"
 	}
+	return http.StatusForbidden, "Unauthorized"
+}
+
+// logDeny records the metrics and access log of a denied request
+func (proxy *restProxy) logDeny(ctx context.Context, loggingInfo *decisionContext) {
+	_, reason := denyStatus(loggingInfo)
 
 	metricLabels := map[string]string{
 		constants.LabelPolicyDecision:       "deny",
@@ -797,21 +909,60 @@ func (proxy *restProxy) writeDeny(ctx context.Context, w http.ResponseWriter, lo
 		constants.LabelRegoPackage:          loggingInfo.Package,
 	}
 
-	proxy.appConf.MetricsProvider.UpdateHistogramMetric(ctx, constants.InstrumentDecisionDuration, loggingInfo.Duration.Milliseconds(), metricLabels)
+	proxy.appConf.Load().MetricsProvider.UpdateHistogramMetric(ctx, constants.InstrumentDecisionDuration, loggingInfo.Duration.Milliseconds(), metricLabels)
 
 	logFields := log.Fields{
-		logging.LabelPath:     loggingInfo.Path,
-		logging.LabelMethod:   loggingInfo.Method,
-		logging.LabelDuration: loggingInfo.Duration.String(),
-		logging.LabelReason:   reason,
+		logging.LabelPath:       loggingInfo.Path,
+		logging.LabelMethod:     loggingInfo.Method,
+		logging.LabelDuration:   loggingInfo.Duration.String(),
+		logging.LabelReason:     reason,
+		logging.LabelDecisionID: loggingInfo.DecisionID,
+	}
+	if loggingInfo.Revision != "" {
+		logFields[logging.LabelRevision] = loggingInfo.Revision
 	}
 
 	if loggingInfo.Error != nil {
 		logFields[logging.LabelError] = loggingInfo.Error.Error()
-		logFields[logging.LabelCorrelation] = loggingInfo.CorrelationID.String()
+		logFields[logging.LabelCorrelation] = loggingInfo.DecisionID //nolint:staticcheck // Kept for existing log queries
+	}
+
+	logging.LogAccessDecision(proxy.config.Load().AccessDecisionLogLevel, "DENY", "policyCompiler", logFields)
+}
+
+// writeDecision answers with the status code of the decision and the additional results of the policy as body (if any).
+// If OPA's response body is configured or requested, the decision is written as OPA's response body with status 200
+// instead, because OPA's clients treat any other status as error.
+func (proxy *restProxy) writeDecision(w http.ResponseWriter, loggingInfo *decisionContext, status int) {
+	if !loggingInfo.OPAResponse {
+		if len(loggingInfo.Results) > 0 {
+			writeJSON(w, status, loggingInfo.Results)
+		} else {
+			w.WriteHeader(status)
+		}
+		return
 	}
 
-	logging.LogAccessDecision(proxy.config.AccessDecisionLogLevel, "DENY", "policyCompiler", logFields)
+	result := any(decisionResult(loggingInfo))
+	response := types.DataResponseV1{
+		DecisionID: loggingInfo.DecisionID,
+		Result:     &result,
+	}
+	if loggingInfo.Metrics != nil {
+		response.Metrics = loggingInfo.Metrics.All()
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// decisionResult returns the decision and the additional results of the policy as result of OPA's response body
+func decisionResult(loggingInfo *decisionContext) map[string]any {
+	results := make(map[string]any, len(loggingInfo.Results)+2)
+	for key, value := range loggingInfo.Results {
+		results[key] = value
+	}
+	results["allow"] = loggingInfo.Allow
+	results["verify"] = loggingInfo.Authentication
+	return results
 }
 
 func loggingContextFromDecision(decision *opa.Decision, duration time.Duration) *decisionContext {
@@ -819,14 +970,18 @@ func loggingContextFromDecision(decision *opa.Decision, duration time.Duration)
 		Path:           decision.Path,
 		Package:        decision.Package,
 		Method:         decision.Method,
+		Revision:       decision.Revision,
+		Allow:          decision.Allow,
 		Authentication: decision.Verify,
 		Duration:       duration,
+		DecisionID:     decision.ID,
+		Results:        decision.Results(),
 	}
 }
 
-func wrapErrorInLoggingContext(err error) *decisionContext {
+func wrapErrorInLoggingContext(err error, decisionID string) *decisionContext {
 	return &decisionContext{
-		Error:         err,
-		CorrelationID: uuid.New(),
+		Error:      err,
+		DecisionID: decisionID,
 	}
 }
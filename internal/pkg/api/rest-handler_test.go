@@ -0,0 +1,180 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/open-policy-agent/opa/v1/plugins"
+	"github.com/stretchr/testify/assert"
+	"github.com/unbasical/kelon/configs"
+	"github.com/unbasical/kelon/pkg/api"
+	"github.com/unbasical/kelon/pkg/constants"
+	"github.com/unbasical/kelon/pkg/opa"
+	"github.com/unbasical/kelon/pkg/telemetry"
+)
+
+type mockCompiler struct {
+	decision opa.Decision
+	input    map[string]any
+	engine   *plugins.Manager
+}
+
+func (c *mockCompiler) Configure(_ *configs.AppConfig, _ *opa.PolicyCompilerConfig) error {
+	return nil
+}
+
+func (c *mockCompiler) GetEngine() *plugins.Manager {
+	return c.engine
+}
+
+func (c *mockCompiler) Execute(ctx context.Context, requestBody map[string]any) (*opa.Decision, error) {
+	c.input, _ = requestBody[constants.Input].(map[string]any)
+	if m := opa.MetricsFromContext(ctx); m != nil {
+		m.Timer("kelon_decision").Start()
+		m.Timer("kelon_decision").Stop()
+	}
+	decision := c.decision
+	decision.ID = opa.DecisionIDFromContext(ctx)
+	return &decision, nil
+}
+
+func newTestProxy(t *testing.T, decision opa.Decision, opaCompatible bool) *restProxy {
+	var compiler opa.PolicyCompiler = &mockCompiler{decision: decision}
+	proxy := NewRestProxy("/v1", 8181).(*restProxy)
+	err := proxy.Configure(context.Background(), &configs.AppConfig{MetricsProvider: telemetry.NewNoopMetricProvider()}, &api.ClientProxyConfig{
+		Compiler:               &compiler,
+		OPACompatibleResponses: opaCompatible,
+	})
+	assert.NoError(t, err)
+	return proxy
+}
+
+func postDecision(proxy *restProxy, target string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, target, strings.NewReader(`{"input": {"method": "GET", "path": "/api/apps"}}`))
+	w := httptest.NewRecorder()
+	proxy.handleV1DataPost(w, r)
+	return w
+}
+
+func Test_handleV1DataPost_StatusCodes(t *testing.T) {
+	w := postDecision(newTestProxy(t, opa.Decision{Allow: false, Verify: true}, false), "/v1/data")
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Empty(t, w.Body.String())
+	assert.NotEmpty(t, w.Header().Get(constants.HeaderDecisionID))
+}
+
+func Test_handleV1DataPost_DecisionID(t *testing.T) {
+	proxy := newTestProxy(t, opa.Decision{Allow: true, Verify: true}, true)
+
+	// Each decision gets its own ID, which is passed to the compiler and returned to the caller
+	first := postDecision(proxy, "/v1/data")
+	second := postDecision(proxy, "/v1/data")
+	firstID := first.Header().Get(constants.HeaderDecisionID)
+	_, err := uuid.Parse(firstID)
+	assert.NoError(t, err)
+	assert.NotEqual(t, firstID, second.Header().Get(constants.HeaderDecisionID))
+
+	var response map[string]any
+	assert.NoError(t, json.Unmarshal(first.Body.Bytes(), &response))
+	assert.Equal(t, firstID, response["decision_id"])
+
+	// Requests which fail before a decision is made are identified as well
+	r := httptest.NewRequest(http.MethodPost, "/v1/data", strings.NewReader(`no json`))
+	w := httptest.NewRecorder()
+	proxy.handleV1DataPost(w, r)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.NotEmpty(t, w.Header().Get(constants.HeaderDecisionID))
+}
+
+func Test_handleV1DataPost_OPACompatibleResponses(t *testing.T) {
+	proxy := newTestProxy(t, opa.Decision{Allow: false, Verify: true}, true)
+
+	w := postDecision(proxy, "/v1/data")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response map[string]any
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, map[string]any{"allow": false, "verify": true}, response["result"])
+	assert.Equal(t, w.Header().Get(constants.HeaderDecisionID), response["decision_id"])
+	assert.NotContains(t, response, "metrics")
+
+	// Metrics are only returned if requested
+	w = postDecision(proxy, "/v1/data?metrics")
+	response = map[string]any{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Contains(t, response["metrics"], "timer_kelon_decision_ns")
+}
+
+func Test_handleV1DataPost_AcceptOPAResponse(t *testing.T) {
+	proxy := newTestProxy(t, opa.Decision{Allow: false, Verify: true}, false)
+
+	// OPA's response body can be requested per request, even if it isn't configured
+	r := httptest.NewRequest(http.MethodPost, "/v1/data", strings.NewReader(`{"input": {"method": "GET", "path": "/api/apps"}}`))
+	r.Header.Set("Accept", "application/json, "+constants.MediaTypeOPAResponse+"; q=0.9")
+	w := httptest.NewRecorder()
+	proxy.handleV1DataPost(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"result": {"allow": false, "verify": true}, "decision_id": "`+w.Header().Get(constants.HeaderDecisionID)+`"}`, w.Body.String())
+
+	// The Accept header is kept for GET requests
+	r = httptest.NewRequest(http.MethodGet, "/v1/data?input="+url.QueryEscape(`{"method": "GET", "path": "/api/apps"}`), http.NoBody)
+	r.Header.Set("Accept", constants.MediaTypeOPAResponse)
+	w = httptest.NewRecorder()
+	proxy.handleV1DataGet(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"result"`)
+
+	// Other requests are still answered with status codes only
+	w = postDecision(proxy, "/v1/data")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Empty(t, w.Body.String())
+}
+
+func Test_handleV1DataPost_PolicyResults(t *testing.T) {
+	w := postDecision(newTestProxy(t, opa.Decision{Allow: false, Verify: true, Reason: "Not your app"}, false), "/v1/data")
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.JSONEq(t, `{"reason": "Not your app"}`, w.Body.String())
+}
+
+func Test_handleV1BatchDataPost(t *testing.T) {
+	proxy := newTestProxy(t, opa.Decision{Allow: false, Verify: true, Reason: "Not your app"}, false)
+
+	r := httptest.NewRequest(http.MethodPost, "/v1/batch/data", strings.NewReader(`{"inputs": [{"method": "GET", "path": "/api/apps"}, "no object"]}`))
+	w := httptest.NewRecorder()
+	proxy.handleV1BatchDataPost(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response batchResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Len(t, response.Responses, 2)
+
+	decision := response.Responses[0]
+	assert.NotEmpty(t, decision.DecisionID)
+	assert.Equal(t, http.StatusForbidden, decision.Status)
+	assert.Equal(t, map[string]any{"allow": false, "verify": true, "reason": "Not your app"}, decision.Result)
+	assert.Nil(t, decision.Error)
+
+	invalid := response.Responses[1]
+	assert.NotEqual(t, decision.DecisionID, invalid.DecisionID)
+	assert.Equal(t, http.StatusBadRequest, invalid.Status)
+	assert.Nil(t, invalid.Result)
+	assert.NotNil(t, invalid.Error)
+}
+
+func Test_handleV1BatchDataPost_MaxSize(t *testing.T) {
+	proxy := newTestProxy(t, opa.Decision{Allow: true, Verify: true}, false)
+	proxy.config.Load().BatchMaxSize = 1
+
+	r := httptest.NewRequest(http.MethodPost, "/v1/batch/data", strings.NewReader(`{"inputs": [{}, {}]}`))
+	w := httptest.NewRecorder()
+	proxy.handleV1BatchDataPost(w, r)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
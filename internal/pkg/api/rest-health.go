@@ -0,0 +1,60 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/unbasical/kelon/internal/pkg/api/health"
+	"github.com/unbasical/kelon/pkg/data"
+)
+
+type readyResponse struct {
+	Status     string                    `json:"status"`
+	Datastores map[string]datastoreReady `json:"datastores,omitempty"`
+}
+
+type datastoreReady struct {
+	Ready bool   `json:"ready"`
+	Error string `json:"error,omitempty"`
+}
+
+// handleHealth reports the health of all datastores, OPA's plugins and the config watcher (see health.Check). It is meant
+// to be used as liveness probe, therefore the response status is only 503 if kelon itself is unhealthy.
+// Use /ready to take kelon out of rotation until its datastores are connected.
+func (proxy *restProxy) handleHealth(w http.ResponseWriter, r *http.Request) {
+	resp := health.Check(r.Context(), proxy.config.Load())
+
+	status := http.StatusOK
+	if resp.Status == health.StatusUnhealthy {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, resp)
+}
+
+// handleReady reports whether all datastores are connected. Datastores which are still connecting are reported
+// together with their last connection error and the response status is 503 until all datastores are ready.
+func (proxy *restProxy) handleReady(w http.ResponseWriter, _ *http.Request) {
+	resp := readyResponse{
+		Status:     "ready",
+		Datastores: make(map[string]datastoreReady),
+	}
+
+	status := http.StatusOK
+	for alias, ds := range proxy.config.Load().Datastores {
+		state := datastoreReady{Ready: true}
+		if reporter, ok := (*ds).(data.ReadinessReporter); ok {
+			ready, err := reporter.Ready()
+			state.Ready = ready
+			if !ready && err != nil {
+				state.Error = err.Error()
+			}
+		}
+
+		if !state.Ready {
+			resp.Status = "not ready"
+			status = http.StatusServiceUnavailable
+		}
+		resp.Datastores[alias] = state
+	}
+
+	writeJSON(w, status, resp)
+}
@@ -0,0 +1,84 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/open-policy-agent/opa/v1/plugins"
+	"github.com/open-policy-agent/opa/v1/storage/inmem"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/unbasical/kelon/configs"
+	"github.com/unbasical/kelon/internal/pkg/api/health"
+	"github.com/unbasical/kelon/pkg/api"
+	"github.com/unbasical/kelon/pkg/data"
+	"github.com/unbasical/kelon/pkg/opa"
+	"github.com/unbasical/kelon/pkg/telemetry"
+)
+
+type pingDatastore struct {
+	err error
+}
+
+func (ds *pingDatastore) Configure(_ *configs.AppConfig, _ string) error {
+	return nil
+}
+
+func (ds *pingDatastore) Execute(_ context.Context, _ data.Node) (bool, error) {
+	return true, nil
+}
+
+func (ds *pingDatastore) Ping(_ context.Context) error {
+	return ds.err
+}
+
+func newHealthProxy(t *testing.T, datastores map[string]data.Datastore) *restProxy {
+	engine, err := plugins.New(nil, "test", inmem.New())
+	assert.NoError(t, err)
+
+	var compiler opa.PolicyCompiler = &mockCompiler{engine: engine}
+	proxyConf := &api.ClientProxyConfig{Compiler: &compiler}
+	proxyConf.Datastores = make(map[string]*data.Datastore, len(datastores))
+	for alias, ds := range datastores {
+		proxyConf.Datastores[alias] = &ds
+	}
+
+	proxy := NewRestProxy("/v1", 8181).(*restProxy)
+	assert.NoError(t, proxy.Configure(context.Background(), &configs.AppConfig{MetricsProvider: telemetry.NewNoopMetricProvider()}, proxyConf))
+	return proxy
+}
+
+func Test_handleHealth(t *testing.T) {
+	proxy := newHealthProxy(t, map[string]data.Datastore{"mysql": &pingDatastore{}})
+
+	w := httptest.NewRecorder()
+	proxy.handleHealth(w, httptest.NewRequest(http.MethodGet, "/health", http.NoBody))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var resp health.Report
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, health.StatusHealthy, resp.Status)
+	assert.Equal(t, health.StatusHealthy, resp.Datastores["mysql"].Status)
+}
+
+func Test_handleHealth_UnreachableDatastore(t *testing.T) {
+	proxy := newHealthProxy(t, map[string]data.Datastore{
+		"mysql": &pingDatastore{},
+		"mongo": &pingDatastore{err: errors.New("connection refused")},
+	})
+
+	// An unreachable datastore must not fail the liveness probe
+	w := httptest.NewRecorder()
+	proxy.handleHealth(w, httptest.NewRequest(http.MethodGet, "/health", http.NoBody))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var resp health.Report
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, health.StatusDegraded, resp.Status)
+	assert.Equal(t, health.StatusHealthy, resp.Datastores["mysql"].Status)
+	assert.Equal(t, health.StatusUnhealthy, resp.Datastores["mongo"].Status)
+	assert.Equal(t, "connection refused", resp.Datastores["mongo"].Error)
+}
@@ -10,6 +10,7 @@ import (
 
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
+	"github.com/unbasical/kelon/configs"
 	"github.com/unbasical/kelon/pkg/constants"
 	"github.com/unbasical/kelon/pkg/constants/logging"
 	"github.com/unbasical/kelon/pkg/telemetry"
@@ -50,8 +51,8 @@ func (proxy *restProxy) applyHandlerMiddleware(ctx context.Context, endpoint str
 		wrappedHandler = proxy.inputHeaderMappingMiddleware(wrappedHandler)
 	}
 
-	wrappedHandler = proxy.appConf.MetricsProvider.WrapHTTPHandler(ctx, wrappedHandler)
-	wrappedHandler = proxy.appConf.TraceProvider.WrapHTTPHandler(ctx, wrappedHandler, endpoint)
+	wrappedHandler = proxy.appConf.Load().MetricsProvider.WrapHTTPHandler(ctx, wrappedHandler)
+	wrappedHandler = proxy.appConf.Load().TraceProvider.WrapHTTPHandler(ctx, wrappedHandler, endpoint)
 
 	return wrappedHandler
 }
@@ -184,14 +185,19 @@ func (proxy *restProxy) applyHeaderMappingsToInput(body map[string]any, r *http.
 		return nil, errors.Errorf("Mismatched type for body[%s]. Expected %T but got %T", constants.Input, input, value)
 	}
 
-	for _, mapping := range proxy.appConf.Global.Input.HeaderMapping {
-		headerValue := r.Header.Get(mapping.Name)
+	ApplyHeaderMappings(proxy.appConf.Load().Global.Input.HeaderMapping, input, r.Header)
+	body[constants.Input] = input
+	return body, nil
+}
+
+// ApplyHeaderMappings inserts the values of the mapped headers into the input, headers without value are skipped.
+// It is shared by all proxies, so that the input of a request doesn't depend on the proxy which received it.
+func ApplyHeaderMappings(mappings []*configs.HeaderMapping, input map[string]any, header http.Header) {
+	for _, mapping := range mappings {
+		headerValue := header.Get(mapping.Name)
 		if headerValue == "" {
 			continue
 		}
 		input[mapping.Alias] = headerValue
 	}
-
-	body[constants.Input] = input
-	return body, nil
 }
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/mux"
@@ -18,8 +19,8 @@ type restProxy struct {
 	pathPrefix string
 	port       uint32
 	configured bool
-	appConf    *configs.AppConfig
-	config     *api.ClientProxyConfig
+	appConf    atomic.Pointer[configs.AppConfig]
+	config     atomic.Pointer[api.ClientProxyConfig]
 	router     *mux.Router
 	server     *http.Server
 
@@ -32,8 +33,6 @@ func NewRestProxy(pathPrefix string, port uint32) api.ClientProxy {
 		pathPrefix: pathPrefix,
 		port:       port,
 		configured: false,
-		appConf:    nil,
-		config:     nil,
 		router:     mux.NewRouter(),
 	}
 }
@@ -62,13 +61,25 @@ func (proxy *restProxy) Configure(_ context.Context, appConf *configs.AppConfig,
 	}
 
 	// Assign variables
-	proxy.appConf = appConf
-	proxy.config = serverConf
+	proxy.appConf.Store(appConf)
+	proxy.config.Store(serverConf)
 	proxy.configured = true
 	logging.LogForComponent("restProxy").Infoln("Configured RestProxy")
 	return nil
 }
 
+// See Reload() of api.ReloadableClientProxy
+func (proxy *restProxy) Reload(appConf *configs.AppConfig, serverConf *api.ClientProxyConfig) error {
+	if !proxy.configured {
+		return errors.Errorf("RestProxy was not configured! Please call Configure(). ")
+	}
+
+	proxy.appConf.Store(appConf)
+	proxy.config.Store(serverConf)
+	logging.LogForComponent("restProxy").Infoln("Reloaded RestProxy")
+	return nil
+}
+
 // See Start() of api.ClientProxy
 func (proxy *restProxy) Start() error {
 	if !proxy.configured {
@@ -84,6 +95,8 @@ func (proxy *restProxy) Start() error {
 	// Endpoints to validate queries
 	proxy.router.PathPrefix(proxy.pathPrefix).Path(constants.EndpointData).Handler(proxy.applyHandlerMiddleware(ctx, constants.EndpointData, proxy.handleV1DataGet, withHeaderExtraction(true))).Methods(http.MethodGet)
 	proxy.router.PathPrefix(proxy.pathPrefix).Path(constants.EndpointData).Handler(proxy.applyHandlerMiddleware(ctx, constants.EndpointData, proxy.handleV1DataPost, withHeaderExtraction(true))).Methods(http.MethodPost)
+	proxy.router.PathPrefix(proxy.pathPrefix).Path(constants.EndpointBatchData).Handler(proxy.applyHandlerMiddleware(ctx, constants.EndpointBatchData, proxy.handleV1BatchDataPost)).Methods(http.MethodPost)
+	proxy.router.PathPrefix(proxy.pathPrefix).Path(constants.EndpointForwardAuth).Handler(proxy.applyHandlerMiddleware(ctx, constants.EndpointForwardAuth, proxy.handleForwardAuth))
 
 	// Endpoints to update data
 	proxy.router.PathPrefix(proxy.pathPrefix).Path(endpointDataWithParams).Handler(proxy.applyHandlerMiddleware(ctx, constants.EndpointData, proxy.handleV1DataPut)).Methods(http.MethodPut)
@@ -99,10 +112,9 @@ func (proxy *restProxy) Start() error {
 		logging.LogForComponent("restProxy").Infof("Registered %s endpoint", constants.EndpointMetrics)
 		proxy.router.PathPrefix(constants.EndpointMetrics).Handler(proxy.metricsHandler)
 	}
-	proxy.router.PathPrefix(constants.EndpointHealth).Methods("GET").HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
-		writer.WriteHeader(http.StatusOK)
-		_, _ = writer.Write([]byte("{\"status\": \"healthy\"}"))
-	})
+	proxy.router.PathPrefix(constants.EndpointHealth).Methods("GET").HandlerFunc(proxy.handleHealth)
+	proxy.router.PathPrefix(constants.EndpointReady).Methods("GET").HandlerFunc(proxy.handleReady)
+	proxy.router.PathPrefix(constants.EndpointRevision).Methods("GET").HandlerFunc(proxy.handleRevision)
 
 	proxy.server = &http.Server{
 		Handler:           proxy.router,
@@ -0,0 +1,439 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/unbasical/kelon/pkg/audit"
+	"github.com/unbasical/kelon/pkg/constants/logging"
+)
+
+// redacted replaces the values of all redacted fields
+const redacted = "[REDACTED]"
+
+// backupTimeFormat is appended to the path of rotated files, so that backups are sorted by their age
+const backupTimeFormat = "20060102T150405.000000000"
+
+// unchainedInfix is inserted into the paths of existing unchained files, which are archived when a new hash chain is started.
+// Archived files are neither part of the backups nor of the chain.
+const unchainedInfix = ".unchained"
+
+// errNotChained is returned if the last record of an existing file is not chained, i.e. because the chain was disabled
+var errNotChained = errors.New("last record is not chained")
+
+// hashSuffix matches the hash, which is always the last field of a chained record
+var hashSuffix = regexp.MustCompile(`,"hash":"([0-9a-f]{64})"}$`)
+
+// FileSinkConfig contains the configuration of the audit.Sink which writes JSON lines to a file.
+type FileSinkConfig struct {
+	// Path of the active audit file
+	Path string
+	// MaxSize in bytes after which the file is rotated, 0 disables size based rotation
+	MaxSize int64
+	// MaxAge of the active file after which it is rotated, 0 disables time based rotation
+	MaxAge time.Duration
+	// MaxBackups is the amount of rotated files which are kept, 0 keeps all of them
+	MaxBackups int
+	// Redact contains the paths of fields (i.e. 'input.password') which are replaced before a record is written
+	Redact []string
+	// HashChain links each record to its predecessor by hashing it together with the predecessor's hash
+	HashChain bool
+}
+
+type fileSink struct {
+	config   FileSinkConfig
+	redact   [][]string
+	mutex    sync.Mutex
+	file     *os.File
+	size     int64
+	created  time.Time
+	lastHash string
+	closed   bool
+	now      func() time.Time
+}
+
+// NewFileSink instantiates a new audit.Sink, which appends each record as JSON line to the configured file.
+// If the file already exists, it is continued (including its hash chain). If the hash chain is enabled for existing
+// files without chain, they are archived and a new chain is started.
+func NewFileSink(config FileSinkConfig) (audit.Sink, error) {
+	if config.Path == "" {
+		return nil, errors.Errorf("FileSink: Path must not be empty")
+	}
+	if config.MaxSize < 0 || config.MaxAge < 0 || config.MaxBackups < 0 {
+		return nil, errors.Errorf("FileSink: max-size, max-age and max-backups must not be negative")
+	}
+
+	sink := &fileSink{config: config, now: time.Now}
+	for _, field := range config.Redact {
+		path := strings.Split(field, ".")
+		if len(path) < 2 || path[0] != "input" || slices.Contains(path, "") {
+			return nil, errors.Errorf("FileSink: Field %q to redact must be a path of the input, i.e. 'input.password'", field)
+		}
+		sink.redact = append(sink.redact, path)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(config.Path), 0o750); err != nil {
+		return nil, errors.Wrap(err, "FileSink: Unable to create directory")
+	}
+	if config.HashChain {
+		lastHash, err := sink.findLastHash()
+		if errors.Is(err, errNotChained) {
+			logging.LogForComponent("auditLog").Warnf("Audit log %q is not chained: Archiving existing files and starting a new hash chain", config.Path)
+			err = sink.archiveUnchained()
+		}
+		if err != nil {
+			return nil, err
+		}
+		sink.lastHash = lastHash
+	}
+	if err := sink.open(); err != nil {
+		return nil, err
+	}
+	return sink, nil
+}
+
+// Write - see audit.Sink
+func (s *fileSink) Write(record audit.Record) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.closed {
+		return errors.Errorf("FileSink: Sink is closed")
+	}
+
+	line, hash, err := s.encode(record)
+	if err != nil {
+		return err
+	}
+
+	if s.shouldRotate(int64(len(line))) {
+		if err := s.rotate(); err != nil {
+			return err
+		}
+	}
+
+	n, err := s.file.Write(line)
+	s.size += int64(n)
+	if err != nil {
+		return errors.Wrap(err, "FileSink: Unable to write record")
+	}
+	s.lastHash = hash
+	return nil
+}
+
+// Close - see audit.Sink
+func (s *fileSink) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	if err := s.file.Sync(); err != nil {
+		return errors.Wrap(err, "FileSink: Unable to sync file")
+	}
+	return s.file.Close()
+}
+
+// encode redacts the record and marshals it into a single line. If records are chained, the line ends with the hash
+// of everything before it, which includes the hash of the previous record.
+func (s *fileSink) encode(record audit.Record) ([]byte, string, error) {
+	record.Input = s.redactInput(record.Input)
+	record.PrevHash = ""
+	record.Hash = ""
+	if s.config.HashChain {
+		record.PrevHash = s.lastHash
+	}
+
+	encoded, err := json.Marshal(record)
+	if err != nil {
+		return nil, "", errors.Wrap(err, "FileSink: Unable to marshal record")
+	}
+
+	var hash string
+	if s.config.HashChain {
+		sum := sha256.Sum256(encoded)
+		hash = hex.EncodeToString(sum[:])
+		encoded = append(encoded[:len(encoded)-1], []byte(`,"hash":"`+hash+`"}`)...)
+	}
+	return append(encoded, '\n'), hash, nil
+}
+
+// redactInput replaces all configured fields of the input. Maps along the paths are copied, so that the request is not modified.
+func (s *fileSink) redactInput(input any) any {
+	for _, path := range s.redact {
+		input = redactPath(input, path[1:])
+	}
+	return input
+}
+
+func redactPath(value any, path []string) any {
+	object, ok := value.(map[string]any)
+	if !ok {
+		return value
+	}
+	field, exists := object[path[0]]
+	if !exists {
+		return value
+	}
+
+	copied := make(map[string]any, len(object))
+	for key, v := range object {
+		copied[key] = v
+	}
+	if len(path) == 1 {
+		copied[path[0]] = redacted
+	} else {
+		copied[path[0]] = redactPath(field, path[1:])
+	}
+	return copied
+}
+
+func (s *fileSink) shouldRotate(size int64) bool {
+	if s.size == 0 {
+		return false
+	}
+	if s.config.MaxSize > 0 && s.size+size > s.config.MaxSize {
+		return true
+	}
+	return s.config.MaxAge > 0 && s.now().Sub(s.created) >= s.config.MaxAge
+}
+
+// rotate moves the active file to a timestamped backup, opens a new one and removes the oldest backups.
+// If the file can't be moved, it is reopened, so that the following records are still written.
+func (s *fileSink) rotate() error {
+	if err := s.file.Close(); err != nil {
+		return errors.Wrap(err, "FileSink: Unable to close file for rotation")
+	}
+	backup := s.config.Path + "." + s.now().UTC().Format(backupTimeFormat)
+	if err := os.Rename(s.config.Path, backup); err != nil {
+		if reopenErr := s.open(); reopenErr != nil {
+			return errors.Wrapf(reopenErr, "FileSink: Unable to reopen file after failed rotation (%s)", err)
+		}
+		return errors.Wrap(err, "FileSink: Unable to rotate file")
+	}
+	if err := s.open(); err != nil {
+		return err
+	}
+	return s.removeOldBackups()
+}
+
+func (s *fileSink) open() error {
+	file, err := os.OpenFile(s.config.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return errors.Wrap(err, "FileSink: Unable to open file")
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return errors.Wrap(err, "FileSink: Unable to stat file")
+	}
+
+	s.file = file
+	s.size = info.Size()
+	s.created = s.now()
+	if s.size > 0 {
+		s.created = firstRecordTime(s.config.Path, info.ModTime())
+	}
+	return nil
+}
+
+// firstRecordTime returns the timestamp of the first record in the file, which is used as its creation time.
+// If the record can't be read, the fallback is returned.
+func firstRecordTime(path string, fallback time.Time) time.Time {
+	file, err := os.Open(path)
+	if err != nil {
+		return fallback
+	}
+	defer func() { _ = file.Close() }()
+
+	line, err := bufio.NewReader(file).ReadBytes('\n')
+	if err != nil {
+		return fallback
+	}
+	var first struct {
+		Timestamp time.Time `json:"timestamp"`
+	}
+	if err := json.Unmarshal(line, &first); err != nil || first.Timestamp.IsZero() {
+		return fallback
+	}
+	return first.Timestamp
+}
+
+func (s *fileSink) removeOldBackups() error {
+	if s.config.MaxBackups == 0 {
+		return nil
+	}
+	backups, err := Backups(s.config.Path)
+	if err != nil {
+		return err
+	}
+	for len(backups) > s.config.MaxBackups {
+		if err := os.Remove(backups[0]); err != nil {
+			return errors.Wrap(err, "FileSink: Unable to remove old backup")
+		}
+		backups = backups[1:]
+	}
+	return nil
+}
+
+// archiveUnchained moves the active file and all backups out of the chain's files, so that a new chain can be started
+func (s *fileSink) archiveUnchained() error {
+	backups, err := Backups(s.config.Path)
+	if err != nil {
+		return err
+	}
+	for _, backup := range backups {
+		if err := os.Rename(backup, s.config.Path+unchainedInfix+strings.TrimPrefix(backup, s.config.Path)); err != nil {
+			return errors.Wrap(err, "FileSink: Unable to archive unchained backup")
+		}
+	}
+
+	archive := s.config.Path + unchainedInfix + "." + s.now().UTC().Format(backupTimeFormat)
+	if err := os.Rename(s.config.Path, archive); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return errors.Wrap(err, "FileSink: Unable to archive unchained file")
+	}
+	return nil
+}
+
+// findLastHash returns the hash of the last record in the active file or, if it is empty, in the newest backup.
+// If that record is not chained, errNotChained is returned.
+func (s *fileSink) findLastHash() (string, error) {
+	backups, err := Backups(s.config.Path)
+	if err != nil {
+		return "", err
+	}
+	candidates := append([]string{s.config.Path}, reversed(backups)...)
+	for _, candidate := range candidates {
+		hash, found, err := lastHashOf(candidate)
+		if err != nil {
+			return "", err
+		}
+		if found {
+			return hash, nil
+		}
+	}
+	return "", nil
+}
+
+// Backups returns all rotated files of the audit file at the path, sorted from the oldest to the newest.
+func Backups(path string) ([]string, error) {
+	matches, err := filepath.Glob(path + ".*")
+	if err != nil {
+		return nil, errors.Wrap(err, "FileSink: Unable to list backups")
+	}
+
+	backups := make([]string, 0, len(matches))
+	for _, match := range matches {
+		if _, err := time.Parse(backupTimeFormat, strings.TrimPrefix(match, path+".")); err == nil {
+			backups = append(backups, match)
+		}
+	}
+	sort.Strings(backups)
+	return backups, nil
+}
+
+// VerifyHashChain reads the chained records of all files in the provided order (oldest first) and returns the amount of
+// verified records. If a record was modified, removed or inserted, an error with its position is returned.
+func VerifyHashChain(paths ...string) (int, error) {
+	var (
+		lastHash string
+		records  int
+	)
+	for _, path := range paths {
+		file, err := os.Open(path)
+		if err != nil {
+			return records, errors.Wrap(err, "unable to open audit file")
+		}
+
+		lastHash, records, err = verifyRecords(file, path, lastHash, records)
+		_ = file.Close()
+		if err != nil {
+			return records, err
+		}
+	}
+	return records, nil
+}
+
+func verifyRecords(reader io.Reader, path, lastHash string, records int) (string, int, error) {
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		content := scanner.Bytes()
+		match := hashSuffix.FindSubmatchIndex(content)
+		if match == nil {
+			return lastHash, records, errors.Errorf("%s:%d: record is not chained", path, line)
+		}
+
+		hash := string(content[match[2]:match[3]])
+		unhashed := append(append([]byte{}, content[:match[0]]...), '}')
+		sum := sha256.Sum256(unhashed)
+		if hex.EncodeToString(sum[:]) != hash {
+			return lastHash, records, errors.Errorf("%s:%d: hash does not match the record", path, line)
+		}
+
+		var chained struct {
+			PrevHash string `json:"prev_hash"`
+		}
+		if err := json.Unmarshal(unhashed, &chained); err != nil {
+			return lastHash, records, errors.Wrapf(err, "%s:%d: invalid record", path, line)
+		}
+		// The first record may follow one of an already removed backup
+		if records > 0 && chained.PrevHash != lastHash {
+			return lastHash, records, errors.Errorf("%s:%d: record does not follow the previous record", path, line)
+		}
+
+		lastHash = hash
+		records++
+	}
+	if err := scanner.Err(); err != nil {
+		return lastHash, records, errors.Wrapf(err, "unable to read %s", path)
+	}
+	return lastHash, records, nil
+}
+
+// lastHashOf returns the hash of the last record in the file, if there is one
+func lastHashOf(path string) (string, bool, error) {
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, errors.Wrap(err, "FileSink: Unable to read file")
+	}
+
+	content = bytes.TrimRight(content, "\n")
+	if len(content) == 0 {
+		return "", false, nil
+	}
+	last := content[bytes.LastIndexByte(content, '\n')+1:]
+	match := hashSuffix.FindSubmatch(last)
+	if match == nil {
+		return "", false, errors.Wrapf(errNotChained, "FileSink: %s", path)
+	}
+	return string(match[1]), true, nil
+}
+
+func reversed(values []string) []string {
+	result := make([]string, len(values))
+	for i, value := range values {
+		result[len(values)-1-i] = value
+	}
+	return result
+}
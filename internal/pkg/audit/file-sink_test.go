@@ -0,0 +1,169 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/unbasical/kelon/pkg/audit"
+)
+
+func record(id string, input any) audit.Record {
+	return audit.Record{
+		Timestamp:  time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+		DecisionID: id,
+		Decision:   audit.DecisionAllow,
+		Package:    "applications.pure",
+		Input:      input,
+	}
+}
+
+func Test_FileSink_RedactsFields(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	sink, err := NewFileSink(FileSinkConfig{Path: path, Redact: []string{"input.password", "input.auth.token", "input.missing"}})
+	assert.NoError(t, err)
+
+	input := map[string]any{"user": "Torben", "password": "secret", "auth": map[string]any{"token": "abc", "type": "bearer"}}
+	assert.NoError(t, sink.Write(record("1", input)))
+	assert.NoError(t, sink.Close())
+
+	content, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	var written audit.Record
+	assert.NoError(t, json.Unmarshal(content, &written))
+	assert.Equal(t, map[string]any{"user": "Torben", "password": "[REDACTED]", "auth": map[string]any{"token": "[REDACTED]", "type": "bearer"}}, written.Input)
+
+	// The request's input is not modified
+	assert.Equal(t, "secret", input["password"])
+	assert.Equal(t, "abc", input["auth"].(map[string]any)["token"])
+}
+
+func Test_FileSink_RotatesBySize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	sink, err := NewFileSink(FileSinkConfig{Path: path, MaxSize: 300, MaxBackups: 2, HashChain: true})
+	assert.NoError(t, err)
+
+	for i := 0; i < 10; i++ {
+		assert.NoError(t, sink.Write(record(strings.Repeat("x", i+1), nil)))
+	}
+	assert.NoError(t, sink.Close())
+
+	backups, err := Backups(path)
+	assert.NoError(t, err)
+	assert.Len(t, backups, 2)
+	for _, file := range append(backups, path) {
+		info, err := os.Stat(file)
+		assert.NoError(t, err)
+		assert.LessOrEqual(t, info.Size(), int64(300))
+	}
+
+	// The chain continues across rotated files, even though the oldest backups were removed
+	records, err := VerifyHashChain(append(backups, path)...)
+	assert.NoError(t, err)
+	assert.Greater(t, records, 2)
+}
+
+func Test_FileSink_HashChainDetectsTampering(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	sink, err := NewFileSink(FileSinkConfig{Path: path, HashChain: true})
+	assert.NoError(t, err)
+	assert.NoError(t, sink.Write(record("1", map[string]any{"user": "Torben"})))
+	assert.NoError(t, sink.Write(record("2", map[string]any{"user": "Arnold"})))
+	assert.NoError(t, sink.Close())
+
+	// A restarted sink continues the chain of the existing file
+	sink, err = NewFileSink(FileSinkConfig{Path: path, HashChain: true})
+	assert.NoError(t, err)
+	assert.NoError(t, sink.Write(record("3", map[string]any{"user": "Kevin"})))
+	assert.NoError(t, sink.Close())
+
+	records, err := VerifyHashChain(path)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, records)
+
+	content, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	lines := bytes.SplitAfter(content, []byte("\n"))
+
+	// Modified record
+	tampered := filepath.Join(t.TempDir(), "modified.log")
+	assert.NoError(t, os.WriteFile(tampered, bytes.Replace(content, []byte("Arnold"), []byte("Arnie"), 1), 0o600))
+	_, err = VerifyHashChain(tampered)
+	assert.ErrorContains(t, err, "modified.log:2: hash does not match")
+
+	// Removed record
+	removed := filepath.Join(t.TempDir(), "removed.log")
+	assert.NoError(t, os.WriteFile(removed, append(append([]byte{}, lines[0]...), lines[2]...), 0o600))
+	_, err = VerifyHashChain(removed)
+	assert.ErrorContains(t, err, "removed.log:2: record does not follow")
+}
+
+func Test_FileSink_RotatesByAge(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	clock := func() time.Time { return now }
+
+	sink, err := NewFileSink(FileSinkConfig{Path: path, MaxAge: time.Hour})
+	assert.NoError(t, err)
+	sink.(*fileSink).now = clock
+	sink.(*fileSink).created = now
+
+	assert.NoError(t, sink.Write(record("1", nil)))
+	now = now.Add(59 * time.Minute)
+	assert.NoError(t, sink.Write(record("2", nil)))
+	backups, _ := Backups(path)
+	assert.Empty(t, backups)
+
+	now = now.Add(time.Minute)
+	assert.NoError(t, sink.Write(record("3", nil)))
+	assert.NoError(t, sink.Close())
+	backups, _ = Backups(path)
+	assert.Len(t, backups, 1)
+
+	// The age of a continued file is measured from its first record instead of the restart
+	now = now.Add(time.Minute)
+	sink, err = NewFileSink(FileSinkConfig{Path: path, MaxAge: time.Hour})
+	assert.NoError(t, err)
+	assert.Equal(t, record("", nil).Timestamp, sink.(*fileSink).created)
+	sink.(*fileSink).now = clock
+	assert.NoError(t, sink.Write(record("4", nil)))
+	assert.NoError(t, sink.Close())
+	backups, _ = Backups(path)
+	assert.Len(t, backups, 2)
+}
+
+func Test_FileSink_StartsChainForUnchainedFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	sink, err := NewFileSink(FileSinkConfig{Path: path})
+	assert.NoError(t, err)
+	assert.NoError(t, sink.Write(record("1", nil)))
+	assert.NoError(t, sink.Close())
+
+	// Enabling the chain archives the unchained file instead of failing
+	sink, err = NewFileSink(FileSinkConfig{Path: path, HashChain: true})
+	assert.NoError(t, err)
+	assert.NoError(t, sink.Write(record("2", nil)))
+	assert.NoError(t, sink.Close())
+
+	archived, err := filepath.Glob(path + unchainedInfix + ".*")
+	assert.NoError(t, err)
+	assert.Len(t, archived, 1)
+
+	backups, err := Backups(path)
+	assert.NoError(t, err)
+	records, err := VerifyHashChain(append(backups, path)...)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, records)
+}
+
+func Test_FileSink_InvalidRedactPath(t *testing.T) {
+	for _, field := range []string{"", "input", "password", "decision_id", "input..password"} {
+		_, err := NewFileSink(FileSinkConfig{Path: filepath.Join(t.TempDir(), "audit.log"), Redact: []string{field}})
+		assert.Error(t, err, field)
+	}
+}
@@ -1,10 +1,16 @@
 package builtins
 
 import (
+	"sync"
+
 	"github.com/open-policy-agent/opa/v1/rego"
 	log "github.com/sirupsen/logrus"
 )
 
+// nolint:gochecknoglobals
+// registeredDatastoreFunctions holds the argument count of all registered datastore functions
+var registeredDatastoreFunctions sync.Map
+
 // RegisterLoggingFunctions registers logging function as buildins for Rego
 func RegisterLoggingFunctions() {
 	rego.RegisterBuiltinDyn(logInfo, makeBuiltinLogFuncForLevel(log.InfoLevel))
@@ -18,4 +24,15 @@ func RegisterLoggingFunctions() {
 // These functions are configured as call-operands
 func RegisterDatastoreFunction(name string, argc int) {
 	rego.RegisterBuiltinDyn(makeBuiltinDatastoreFuncDecl(name, argc), makeBuiltinDatastoreFuncImpl())
+	registeredDatastoreFunctions.Store(name, argc)
+}
+
+// DatastoreFunctionArgs returns the argument count a datastore function was registered with.
+// If no datastore function with the name was registered, false is returned.
+func DatastoreFunctionArgs(name string) (int, bool) {
+	argc, ok := registeredDatastoreFunctions.Load(name)
+	if !ok {
+		return 0, false
+	}
+	return argc.(int), true
 }
@@ -0,0 +1,62 @@
+package core
+
+import (
+	auditInt "github.com/unbasical/kelon/internal/pkg/audit"
+	"github.com/unbasical/kelon/pkg/audit"
+)
+
+// makeAuditSink opens the audit log, if a file was configured. Otherwise, no audit trail is written.
+func (k *Kelon) makeAuditSink() audit.Sink {
+	if !isSet(k.config.AuditLogFile) {
+		return nil
+	}
+
+	sinkConf := auditInt.FileSinkConfig{Path: *k.config.AuditLogFile}
+	if k.config.AuditLogMaxSizeMB != nil {
+		sinkConf.MaxSize = *k.config.AuditLogMaxSizeMB * 1024 * 1024
+	}
+	if k.config.AuditLogMaxAge != nil {
+		sinkConf.MaxAge = *k.config.AuditLogMaxAge
+	}
+	if k.config.AuditLogMaxBackups != nil {
+		sinkConf.MaxBackups = *k.config.AuditLogMaxBackups
+	}
+	if k.config.AuditLogRedact != nil {
+		sinkConf.Redact = *k.config.AuditLogRedact
+	}
+	if k.config.AuditLogHashChain != nil {
+		sinkConf.HashChain = *k.config.AuditLogHashChain
+	}
+
+	sink, err := auditInt.NewFileSink(sinkConf)
+	if err != nil {
+		k.logger.Fatalf("Unable to open audit log %q: %s", sinkConf.Path, err)
+	}
+	k.logger.Infof("Writing audit log to %q (hash-chain: %t)", sinkConf.Path, sinkConf.HashChain)
+	return sink
+}
+
+// StartVerifyAuditLog verifies the hash chain of the provided audit files (oldest first) or, if there are none,
+// of the configured audit log and its rotated files. Kelon exits with a fatal error if the chain is broken.
+func (k *Kelon) StartVerifyAuditLog(files []string) {
+	if !k.configured {
+		k.logger.Fatalf("Kelon was not configured! Please call Configure()!")
+	}
+
+	if len(files) == 0 {
+		if !isSet(k.config.AuditLogFile) {
+			k.logger.Fatalln("Either provide the audit files to verify or configure --audit-log-file!")
+		}
+		backups, err := auditInt.Backups(*k.config.AuditLogFile)
+		if err != nil {
+			k.logger.Fatalln(err.Error())
+		}
+		files = append(backups, *k.config.AuditLogFile)
+	}
+
+	records, err := auditInt.VerifyHashChain(files...)
+	if err != nil {
+		k.logger.Fatalf("Audit log was tampered with after %d valid records: %s", records, err)
+	}
+	k.logger.Infof("Verified %d records in %d audit files", records, len(files))
+}
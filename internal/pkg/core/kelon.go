@@ -6,6 +6,7 @@ import (
 	"io"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"strings"
 	"syscall"
 	"time"
@@ -15,13 +16,15 @@ import (
 	apiInt "github.com/unbasical/kelon/internal/pkg/api"
 	"github.com/unbasical/kelon/internal/pkg/api/envoy"
 	"github.com/unbasical/kelon/internal/pkg/builtins"
-	"github.com/unbasical/kelon/internal/pkg/data"
+	dataInt "github.com/unbasical/kelon/internal/pkg/data"
 	opaInt "github.com/unbasical/kelon/internal/pkg/opa"
 	requestInt "github.com/unbasical/kelon/internal/pkg/request"
 	translateInt "github.com/unbasical/kelon/internal/pkg/translate"
 	watcherInt "github.com/unbasical/kelon/internal/pkg/watcher"
 	"github.com/unbasical/kelon/pkg/api"
+	"github.com/unbasical/kelon/pkg/audit"
 	"github.com/unbasical/kelon/pkg/constants/logging"
+	"github.com/unbasical/kelon/pkg/data"
 	"github.com/unbasical/kelon/pkg/opa"
 	"github.com/unbasical/kelon/pkg/request"
 	"github.com/unbasical/kelon/pkg/telemetry"
@@ -34,20 +37,48 @@ type KelonConfiguration struct {
 	ConfigPath        *string
 	ConfigWatcherPath *string
 	RegoDir           *string
+	BundlePath        *string
 	OperandDir        *string
 
+	// Bundle verification
+	BundleVerificationKeyID *string
+	BundleVerificationScope *string
+
+	// Config watcher
+	ConfigWatcherMode         *string
+	ConfigWatcherPollInterval *time.Duration
+	ConfigWatcherDebounce     *time.Duration
+
 	// Additional config
-	PathPrefix     *string
-	Port           *uint32
-	AstSkipUnknown *bool
+	PathPrefix         *string
+	Port               *uint32
+	AstSkipUnknown     *bool
+	StatementCacheSize *int
+
+	// Data-API
+	OPACompatibleResponses *bool
+	BatchWorkers           *int
+	BatchMaxSize           *int
 
 	// Logging
 	AccessDecisionLogLevel *string
+	DecisionLogQueryParams *bool
+
+	// Audit log
+	AuditLogFile       *string
+	AuditLogMaxSizeMB  *int64
+	AuditLogMaxAge     *time.Duration
+	AuditLogMaxBackups *int
+	AuditLogRedact     *[]string
+	AuditLogHashChain  *bool
 
 	// Configs for envoy external auth
 	EnvoyPort       *uint32
 	EnvoyDryRun     *bool
 	EnvoyReflection *bool
+	// Configs for envoy's HTTP service variant of external auth
+	EnvoyHTTPPort       *uint32
+	EnvoyHTTPPathPrefix *string
 
 	// Configs for telemetry
 	MetricProvider           *string
@@ -71,9 +102,13 @@ type Kelon struct {
 	dsLoggingWriter io.Writer
 	proxy           api.ClientProxy
 	envoyProxy      api.ClientProxy
+	compiler        opa.PolicyCompiler
+	appConfig       *configs.AppConfig
+	serverConfig    *api.ClientProxyConfig
 	configWatcher   watcher.ConfigWatcher
 	metricsProvider telemetry.MetricsProvider
 	traceProvider   telemetry.TraceProvider
+	auditSink       audit.Sink
 }
 
 func (k *Kelon) Configure(config *KelonConfiguration) {
@@ -87,6 +122,9 @@ func (k *Kelon) Configure(config *KelonConfiguration) {
 	builtins.RegisterLoggingFunctions()
 
 	k.config = config
+	if isSet(config.RegoDir) && isSet(config.BundlePath) {
+		k.logger.Fatalln("Either a rego dir or a bundle can be loaded, but not both!")
+	}
 
 	k.configured = true
 }
@@ -107,35 +145,36 @@ func (k *Kelon) Start() {
 	k.stopOnSIGTERM()
 }
 
-func (k *Kelon) onConfigLoaded(change watcher.ChangeType, loadedConf *configs.ExternalConfig, err error) {
+func (k *Kelon) onConfigLoaded(change watcher.ChangeType, paths []string, loadedConf *configs.ExternalConfig, err error) {
 	if err != nil {
+		if change != watcher.ChangeAll {
+			k.logger.Errorf("Unable to parse configuration, keeping the previous one: %s", err)
+			return
+		}
 		k.logger.Fatalln("Unable to parse configuration: ", err.Error())
 	}
 
 	ctx := context.Background()
 
-	if change == watcher.ChangeAll {
+	switch {
+	case change == watcher.ChangeAll:
 		// Configure application
 		var (
-			config     = new(configs.AppConfig)
-			compiler   = opaInt.NewPolicyCompiler()
+			config     = k.makeAppConfig(loadedConf)
 			parser     = requestInt.NewURLProcessor()
 			mapper     = requestInt.NewPathMapper()
 			translator = translateInt.NewAstTranslator()
 		)
+		k.compiler = opaInt.NewCachingPolicyCompiler(opaInt.NewPolicyCompiler())
 
-		// Build config
-		config.Global = loadedConf.Global
-		config.APIMappings = loadedConf.APIMappings
-		config.DatastoreSchemas = loadedConf.DatastoreSchemas
-		config.Datastores = loadedConf.Datastores
-		config.OPA = loadedConf.OPA
 		config.MetricsProvider = k.makeTelemetryMetricsProvider(ctx)
 		k.metricsProvider = config.MetricsProvider // Stopped gracefully later on
 		config.TraceProvider = k.makeTelemetryTraceProvider(ctx)
 		k.traceProvider = config.TraceProvider // Stopped gracefully later on
+		config.AuditSink = k.makeAuditSink()
+		k.auditSink = config.AuditSink // Closed after the proxies were stopped
 
-		serverConf := k.makeServerConfig(compiler, parser, mapper, translator, loadedConf)
+		serverConf := k.makeServerConfig(k.compiler, parser, mapper, translator, loadedConf)
 
 		// load call operands for the datastore translator
 		k.loadCallOperands(config)
@@ -144,12 +183,126 @@ func (k *Kelon) onConfigLoaded(change watcher.ChangeType, loadedConf *configs.Ex
 		k.startNewRestProxy(ctx, config, &serverConf)
 
 		// Start envoyProxy proxy in addition to rest proxy as soon as a port was specified!
-		if k.config.EnvoyPort != nil && *k.config.EnvoyPort != 0 {
+		if (k.config.EnvoyPort != nil && *k.config.EnvoyPort != 0) || (k.config.EnvoyHTTPPort != nil && *k.config.EnvoyHTTPPort != 0) {
 			k.startNewEnvoyProxy(ctx, config, &serverConf)
 		}
+		k.appConfig, k.serverConfig = config, &serverConf // Restored if a reload fails
+	case change.Has(watcher.ChangeConf) && k.onlyCallOperandsChanged(paths):
+		k.logger.Infof("Reloading call operands due to changed files %q", paths)
+		k.reloadCallOperands(loadedConf)
+	case change.Has(watcher.ChangeConf):
+		k.logger.Infof("Reloading configuration due to changed files %q", paths)
+		k.reloadConfig(loadedConf)
 	}
 }
 
+// onlyCallOperandsChanged returns true if all changed configuration files are located in the call operands dir
+func (k *Kelon) onlyCallOperandsChanged(paths []string) bool {
+	if k.config.OperandDir == nil || *k.config.OperandDir == "" {
+		return false
+	}
+
+	for _, path := range paths {
+		ext := filepath.Ext(path)
+		if ext != ".yml" && ext != ".yaml" {
+			continue
+		}
+		rel, err := filepath.Rel(*k.config.OperandDir, path)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return false
+		}
+	}
+	return true
+}
+
+// reloadCallOperands validates the call operands on disk and swaps them in all datastores, without reloading the whole configuration.
+func (k *Kelon) reloadCallOperands(loadedConf *configs.ExternalConfig) {
+	reloader, ok := k.compiler.(data.CallOperandsReloader)
+	if !ok {
+		k.logger.Warnln("PolicyCompiler can not reload call operands, a restart is needed to apply the changed call operands")
+		return
+	}
+
+	ops, err := dataInt.ReloadAllCallOperands(loadedConf.Datastores, k.config.OperandDir)
+	if err != nil {
+		k.logger.Errorf("Unable to reload call operands, keeping the previous ones: %s", err)
+		return
+	}
+	if err := reloader.ReloadCallOperands(ops); err != nil {
+		k.logger.Errorf("Unable to reload call operands: %s", err)
+		return
+	}
+	k.logger.Infoln("Reloaded call operands")
+}
+
+// reloadConfig builds all components, which depend on the loaded configuration, and swaps them in afterwards.
+// The telemetry providers and OPA's own configuration are only applied on startup.
+func (k *Kelon) reloadConfig(loadedConf *configs.ExternalConfig) {
+	compiler, ok := k.compiler.(opa.ReloadablePolicyCompiler)
+	if !ok {
+		k.logger.Warnln("PolicyCompiler can not be reloaded, a restart is needed to apply the changed configuration")
+		return
+	}
+
+	var (
+		config     = k.makeAppConfig(loadedConf)
+		parser     = requestInt.NewURLProcessor()
+		mapper     = requestInt.NewPathMapper()
+		translator = translateInt.NewAstTranslator()
+	)
+	config.MetricsProvider = k.metricsProvider
+	config.TraceProvider = k.traceProvider
+	config.AuditSink = k.auditSink
+
+	ops, err := dataInt.ReloadAllCallOperands(config.Datastores, k.config.OperandDir)
+	if err != nil {
+		k.logger.Errorf("Unable to reload configuration, keeping the previous one: %s", err)
+		return
+	}
+	config.CallOperands = ops
+
+	serverConf := k.makeServerConfig(k.compiler, parser, mapper, translator, loadedConf)
+	pending, err := compiler.Reload(config, &serverConf.PolicyCompilerConfig)
+	if err != nil {
+		k.logger.Errorf("Unable to reload configuration, keeping the previous one: %s", err)
+		return
+	}
+
+	// The proxies are reloaded before the previous configuration is retired, so that they never use its closed datastores
+	var proxies []api.ReloadableClientProxy
+	for _, proxy := range []api.ClientProxy{k.proxy, k.envoyProxy} {
+		if reloadable, ok := proxy.(api.ReloadableClientProxy); ok {
+			proxies = append(proxies, reloadable)
+		}
+	}
+	for i, proxy := range proxies {
+		if err := proxy.Reload(config, &serverConf); err != nil {
+			k.logger.Errorf("Unable to reload configuration of proxy %T, keeping the previous one: %s", proxy, err)
+			for _, reloaded := range proxies[:i] {
+				if err := reloaded.Reload(k.appConfig, k.serverConfig); err != nil {
+					k.logger.Errorf("Unable to restore configuration of proxy %T: %s", reloaded, err)
+				}
+			}
+			pending.Abort()
+			return
+		}
+	}
+
+	pending.Commit()
+	k.appConfig, k.serverConfig = config, &serverConf
+	k.logger.Infoln("Reloaded configuration")
+}
+
+func (k *Kelon) makeAppConfig(loadedConf *configs.ExternalConfig) *configs.AppConfig {
+	config := new(configs.AppConfig)
+	config.Global = loadedConf.Global
+	config.APIMappings = loadedConf.APIMappings
+	config.DatastoreSchemas = loadedConf.DatastoreSchemas
+	config.Datastores = loadedConf.Datastores
+	config.OPA = loadedConf.OPA
+	return config
+}
+
 func (k *Kelon) makeTelemetryMetricsProvider(ctx context.Context) telemetry.MetricsProvider {
 	if k.config.MetricProvider != nil && *k.config.MetricProvider != "" {
 		provider, err := telemetry.NewMetricsProvider(ctx, *k.config.OtlpServiceName, *k.config.MetricProvider, *k.config.OtlpMetricExportProtocol, *k.config.OtlpMetricExportEndpoint)
@@ -183,19 +336,52 @@ func (k *Kelon) makeTelemetryTraceProvider(ctx context.Context) telemetry.TraceP
 }
 
 func (k *Kelon) makeConfigWatcher(configLoader configs.FileConfigLoader, configWatcherPath *string) {
-	if k.config.RegoDir == nil || *k.config.RegoDir == "" {
+	if !isSet(k.config.RegoDir) && !isSet(k.config.BundlePath) {
 		k.configWatcher = watcherInt.NewSimple(configLoader)
 	} else {
-		// Set configWatcherPath to rego path by default
-		if configWatcherPath == nil || *configWatcherPath == "" {
-			configWatcherPath = k.config.RegoDir
+		// Set configWatcherPath to rego path or the bundle's dir by default
+		watchPath := k.defaultWatchPath()
+		if isSet(configWatcherPath) {
+			watchPath = *configWatcherPath
+		}
+		watchPaths := []string{watchPath}
+		if k.config.OperandDir != nil && *k.config.OperandDir != "" {
+			watchPaths = append(watchPaths, *k.config.OperandDir)
+		}
+
+		if k.config.ConfigWatcherMode != nil && strings.EqualFold(*k.config.ConfigWatcherMode, "POLL") {
+			pollingWatcher, err := watcherInt.NewPollingWatcher(configLoader, *k.config.ConfigWatcherPollInterval, watchPaths...)
+			if err != nil {
+				k.logger.Fatalf("Unable to start config watcher: %s", err)
+			}
+			k.configWatcher = pollingWatcher
+		} else {
+			var debounce time.Duration
+			if k.config.ConfigWatcherDebounce != nil {
+				debounce = *k.config.ConfigWatcherDebounce
+			}
+			k.configWatcher = watcherInt.NewFileWatcher(configLoader, debounce, watchPaths...)
 		}
-		k.configWatcher = watcherInt.NewFileWatcher(configLoader, *configWatcherPath)
 	}
 }
 
+// defaultWatchPath returns the rego dir or the bundle dir (which contains the bundle, if it is a file)
+func (k *Kelon) defaultWatchPath() string {
+	if isSet(k.config.RegoDir) {
+		return *k.config.RegoDir
+	}
+	if info, err := os.Stat(*k.config.BundlePath); err == nil && !info.IsDir() {
+		return filepath.Dir(*k.config.BundlePath)
+	}
+	return *k.config.BundlePath
+}
+
+func isSet(value *string) bool {
+	return value != nil && *value != ""
+}
+
 func (k *Kelon) loadCallOperands(appConfig *configs.AppConfig) {
-	ops, err := data.LoadAllCallOperands(appConfig.Datastores, k.config.OperandDir)
+	ops, err := dataInt.LoadAllCallOperands(appConfig.Datastores, k.config.OperandDir)
 	if err != nil {
 		k.logger.Fatalln(err.Error())
 	}
@@ -215,13 +401,29 @@ func (k *Kelon) startNewRestProxy(ctx context.Context, appConfig *configs.AppCon
 }
 
 func (k *Kelon) startNewEnvoyProxy(ctx context.Context, appConfig *configs.AppConfig, serverConf *api.ClientProxyConfig) {
-	if *k.config.EnvoyPort == *k.config.Port {
+	var envoyPort, envoyHTTPPort uint32
+	var envoyHTTPPathPrefix string
+	if k.config.EnvoyPort != nil {
+		envoyPort = *k.config.EnvoyPort
+	}
+	if k.config.EnvoyHTTPPort != nil {
+		envoyHTTPPort = *k.config.EnvoyHTTPPort
+	}
+	if k.config.EnvoyHTTPPathPrefix != nil {
+		envoyHTTPPathPrefix = *k.config.EnvoyHTTPPathPrefix
+	}
+	if envoyPort == *k.config.Port || envoyHTTPPort == *k.config.Port {
 		k.logger.Panic("Cannot start envoyProxy proxy and rest proxy on same port!")
 	}
+	if envoyPort == envoyHTTPPort {
+		k.logger.Panic("Cannot start envoyProxy grpc-server and http-server on same port!")
+	}
 
 	// Create Rest proxy and start
 	k.envoyProxy = envoy.NewEnvoyProxy(envoy.Config{
-		Port:                   *k.config.EnvoyPort,
+		Port:                   envoyPort,
+		HTTPPort:               envoyHTTPPort,
+		HTTPPathPrefix:         envoyHTTPPathPrefix,
 		DryRun:                 *k.config.EnvoyDryRun,
 		EnableReflection:       *k.config.EnvoyReflection,
 		AccessDecisionLogLevel: *k.config.AccessDecisionLogLevel,
@@ -242,6 +444,7 @@ func (k *Kelon) makeServerConfig(compiler opa.PolicyCompiler, parser request.Pat
 		PolicyCompilerConfig: opa.PolicyCompilerConfig{
 			Prefix:        k.config.PathPrefix,
 			RegoDir:       k.config.RegoDir,
+			BundlePath:    k.config.BundlePath,
 			OPAConfig:     loadedConf.OPA,
 			ConfigWatcher: &k.configWatcher,
 			PathProcessor: &parser,
@@ -250,13 +453,32 @@ func (k *Kelon) makeServerConfig(compiler opa.PolicyCompiler, parser request.Pat
 			},
 			Translator: &translator,
 			AstTranslatorConfig: translate.AstTranslatorConfig{
-				Datastores:   data.MakeDatastores(loadedConf, k.dsLoggingWriter, k.config.Validate),
-				SkipUnknown:  *k.config.AstSkipUnknown,
-				ValidateMode: k.config.Validate,
+				Datastores:         dataInt.MakeDatastores(loadedConf, k.dsLoggingWriter, k.config.Validate),
+				SkipUnknown:        *k.config.AstSkipUnknown,
+				ValidateMode:       k.config.Validate,
+				StatementCacheSize: *k.config.StatementCacheSize,
 			},
 			AccessDecisionLogLevel: strings.ToUpper(*k.config.AccessDecisionLogLevel),
 		},
 	}
+	if k.config.BundleVerificationKeyID != nil {
+		serverConf.PolicyCompilerConfig.BundleVerificationKeyID = *k.config.BundleVerificationKeyID
+	}
+	if k.config.BundleVerificationScope != nil {
+		serverConf.PolicyCompilerConfig.BundleVerificationScope = *k.config.BundleVerificationScope
+	}
+	if k.config.DecisionLogQueryParams != nil {
+		serverConf.PolicyCompilerConfig.LogQueryParameters = *k.config.DecisionLogQueryParams
+	}
+	if k.config.OPACompatibleResponses != nil {
+		serverConf.OPACompatibleResponses = *k.config.OPACompatibleResponses
+	}
+	if k.config.BatchWorkers != nil {
+		serverConf.BatchWorkers = *k.config.BatchWorkers
+	}
+	if k.config.BatchMaxSize != nil {
+		serverConf.BatchMaxSize = *k.config.BatchMaxSize
+	}
 	return serverConf
 }
 
@@ -298,6 +520,12 @@ func (k *Kelon) stopOnSIGTERM() {
 			k.logger.Warnln(err.Error())
 		}
 	}
+	// Close audit log after all decisions were made
+	if k.auditSink != nil {
+		if err := k.auditSink.Close(); err != nil {
+			k.logger.Warnln(err.Error())
+		}
+	}
 	// Give components enough time for graceful shutdown
 	// This terminates earlier, because rest-proxy prints FATAL if http-server is closed
 	time.Sleep(5 * time.Second)
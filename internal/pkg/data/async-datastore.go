@@ -0,0 +1,224 @@
+package data
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/unbasical/kelon/configs"
+	"github.com/unbasical/kelon/pkg/constants/logging"
+	"github.com/unbasical/kelon/pkg/data"
+	internalErrors "github.com/unbasical/kelon/pkg/errors"
+)
+
+// asyncRetryInterval is the time waited between two failed attempts to configure the wrapped datastore
+const asyncRetryInterval = 5 * time.Second
+
+// asyncDatastore wraps a data.Datastore and configures it in the background, so that the startup of kelon
+// is not blocked by unreachable datastores. Until the wrapped datastore is configured, all queries fail with
+// errors.DatastoreUnavailable.
+//
+// The configuration is validated synchronously and only connection errors are retried, permanent errors
+// (i.e. bad credentials) are reported via Ready instead.
+type asyncDatastore struct {
+	datastore     data.Datastore
+	alias         string
+	configured    bool
+	retryInterval time.Duration
+
+	mutex          sync.RWMutex
+	ready          bool
+	lastErr        error
+	pendingCallOps map[string]map[string]func(args ...string) (string, error)
+	closing        bool
+	closed         chan struct{}
+}
+
+// NewAsyncDatastore wraps the provided data.Datastore, which will be configured in the background.
+func NewAsyncDatastore(datastore data.Datastore) data.Datastore {
+	return &asyncDatastore{
+		datastore:     datastore,
+		alias:         "",
+		configured:    false,
+		retryInterval: asyncRetryInterval,
+		closed:        make(chan struct{}),
+	}
+}
+
+// Configure - see data.Datastore
+func (ds *asyncDatastore) Configure(appConf *configs.AppConfig, alias string) error {
+	// Exit if already configured
+	if ds.configured {
+		return nil
+	}
+
+	if ds.datastore == nil {
+		return errors.Errorf("AsyncDatastore: Datastore not configured!")
+	}
+	// Invalid configurations won't become valid by retrying, therefore they are reported immediately
+	if err := validateDatastore(ds.datastore, appConf, alias); err != nil {
+		return errors.Wrapf(err, "AsyncDatastore: Invalid configuration of datastore [%s]", alias)
+	}
+
+	ds.alias = alias
+	ds.configured = true
+	go ds.connect(appConf, alias)
+	return nil
+}
+
+// connect configures the wrapped datastore until it succeeds, fails permanently or the datastore is closed
+func (ds *asyncDatastore) connect(appConf *configs.AppConfig, alias string) {
+	for {
+		err := ds.datastore.Configure(appConf, alias)
+
+		ds.mutex.Lock()
+		if ds.closing {
+			ds.mutex.Unlock()
+			// The datastore was closed while connecting
+			if err == nil {
+				ds.closeDatastore(context.Background())
+			}
+			return
+		}
+		// Call operands which were reloaded while connecting replace the ones of the initial configuration
+		if err == nil && ds.pendingCallOps != nil {
+			if reloadErr := reloadCallOperands(ds.datastore, ds.pendingCallOps); reloadErr != nil {
+				logging.LogForComponent("asyncDatastore").Errorf("Unable to apply reloaded call operands to datastore [%s]: %s", alias, reloadErr)
+			}
+			ds.pendingCallOps = nil
+		}
+		ds.ready = err == nil
+		ds.lastErr = err
+		ds.mutex.Unlock()
+
+		if err == nil {
+			logging.LogForComponent("asyncDatastore").Infof("Datastore [%s] is ready", alias)
+			return
+		}
+		if isPermanentError(err) {
+			logging.LogForComponent("asyncDatastore").Errorf("Datastore [%s] is not ready and won't be retried, please check its configuration: %s", alias, err)
+			return
+		}
+		logging.LogForComponent("asyncDatastore").Errorf("Datastore [%s] is not ready, retrying in %s: %s", alias, ds.retryInterval, err)
+		select {
+		case <-ds.closed:
+			return
+		case <-time.After(ds.retryInterval):
+		}
+	}
+}
+
+// Close - see data.Closer
+func (ds *asyncDatastore) Close(ctx context.Context) error {
+	ds.mutex.Lock()
+	if ds.closing {
+		ds.mutex.Unlock()
+		return nil
+	}
+	ready := ds.ready
+	ds.closing = true
+	ds.ready = false
+	ds.lastErr = errors.Errorf("datastore was closed")
+	ds.mutex.Unlock()
+
+	close(ds.closed)
+	if !ready {
+		return nil
+	}
+	return ds.closeDatastore(ctx)
+}
+
+func (ds *asyncDatastore) closeDatastore(ctx context.Context) error {
+	if closer, ok := ds.datastore.(data.Closer); ok {
+		return closer.Close(ctx)
+	}
+	return nil
+}
+
+// Ready - see data.ReadinessReporter
+func (ds *asyncDatastore) Ready() (bool, error) {
+	ds.mutex.RLock()
+	defer ds.mutex.RUnlock()
+
+	return ds.ready, ds.lastErr
+}
+
+// Execute - see data.Datastore
+func (ds *asyncDatastore) Execute(ctx context.Context, query data.Node) (bool, error) {
+	if err := ds.checkReady(); err != nil {
+		return false, err
+	}
+	return ds.datastore.Execute(ctx, query)
+}
+
+// Translate - see data.PreparedDatastore
+func (ds *asyncDatastore) Translate(ctx context.Context, query data.Node) (data.DatastoreQuery, error) {
+	if err := ds.checkReady(); err != nil {
+		return data.DatastoreQuery{}, err
+	}
+	prepared, ok := ds.datastore.(data.PreparedDatastore)
+	if !ok {
+		return data.DatastoreQuery{}, errors.Errorf("AsyncDatastore: Wrapped datastore of type %T does not support prepared queries", ds.datastore)
+	}
+	return prepared.Translate(ctx, query)
+}
+
+// ExecuteQuery - see data.PreparedDatastore
+func (ds *asyncDatastore) ExecuteQuery(ctx context.Context, query data.DatastoreQuery) (bool, error) {
+	if err := ds.checkReady(); err != nil {
+		return false, err
+	}
+	prepared, ok := ds.datastore.(data.PreparedDatastore)
+	if !ok {
+		return false, errors.Errorf("AsyncDatastore: Wrapped datastore of type %T does not support prepared queries", ds.datastore)
+	}
+	return prepared.ExecuteQuery(ctx, query)
+}
+
+// Ping - see data.Pinger
+func (ds *asyncDatastore) Ping(ctx context.Context) error {
+	if err := ds.checkReady(); err != nil {
+		return err
+	}
+	if pinger, ok := ds.datastore.(data.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+// ReloadCallOperands - see data.CallOperandsReloader
+// If the wrapped datastore is not ready yet, the call operands are applied as soon as it is configured.
+func (ds *asyncDatastore) ReloadCallOperands(callOps map[string]map[string]func(args ...string) (string, error)) error {
+	if !ds.configured {
+		return errors.Errorf("AsyncDatastore: Datastore was not configured! Please call Configure().")
+	}
+
+	ds.mutex.Lock()
+	defer ds.mutex.Unlock()
+
+	if !ds.ready {
+		ds.pendingCallOps = callOps
+		return nil
+	}
+	return reloadCallOperands(ds.datastore, callOps)
+}
+
+// CircuitState - see data.CircuitBreaker
+func (ds *asyncDatastore) CircuitState() string {
+	if breaker, ok := ds.datastore.(data.CircuitBreaker); ok {
+		return breaker.CircuitState()
+	}
+	return data.CircuitClosed
+}
+
+// checkReady returns errors.DatastoreUnavailable as long as the wrapped datastore is not configured
+func (ds *asyncDatastore) checkReady() error {
+	if !ds.configured {
+		return errors.Errorf("AsyncDatastore: Datastore was not configured! Please call Configure().")
+	}
+	if ready, _ := ds.Ready(); !ready {
+		return internalErrors.DatastoreUnavailable{Alias: ds.alias, Msg: "datastore is not ready yet"}
+	}
+	return nil
+}
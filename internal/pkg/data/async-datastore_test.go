@@ -0,0 +1,146 @@
+package data
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/unbasical/kelon/configs"
+	"github.com/unbasical/kelon/pkg/data"
+	internalErrors "github.com/unbasical/kelon/pkg/errors"
+)
+
+type blockingDatastore struct {
+	flakyDatastore
+	release chan struct{}
+	closed  atomic.Bool
+}
+
+func (ds *blockingDatastore) Close(_ context.Context) error {
+	ds.closed.Store(true)
+	return nil
+}
+
+func (ds *blockingDatastore) Configure(_ *configs.AppConfig, _ string) error {
+	<-ds.release
+	return nil
+}
+
+type reloadingDatastore struct {
+	blockingDatastore
+	callOps atomic.Pointer[map[string]map[string]func(args ...string) (string, error)]
+}
+
+func (ds *reloadingDatastore) ReloadCallOperands(callOps map[string]map[string]func(args ...string) (string, error)) error {
+	ds.callOps.Store(&callOps)
+	return nil
+}
+
+type failingDatastore struct {
+	flakyDatastore
+	err        error
+	configures atomic.Int32
+}
+
+func (ds *failingDatastore) Configure(_ *configs.AppConfig, _ string) error {
+	ds.configures.Add(1)
+	return ds.err
+}
+
+func Test_AsyncDatastore_UnavailableUntilReady(t *testing.T) {
+	inner := &blockingDatastore{release: make(chan struct{})}
+	ds := NewAsyncDatastore(inner)
+	assert.NoError(t, ds.Configure(&configs.AppConfig{}, "blocking"))
+
+	ready, _ := ds.(data.ReadinessReporter).Ready()
+	assert.False(t, ready)
+	_, err := ds.Execute(context.Background(), nil)
+	assert.ErrorAs(t, err, &internalErrors.DatastoreUnavailable{})
+
+	close(inner.release)
+	assert.Eventually(t, func() bool {
+		ready, _ := ds.(data.ReadinessReporter).Ready()
+		return ready
+	}, time.Second, 10*time.Millisecond)
+
+	result, err := ds.Execute(context.Background(), nil)
+	assert.NoError(t, err)
+	assert.True(t, result)
+}
+
+func Test_AsyncDatastore_CloseWhileConnecting(t *testing.T) {
+	inner := &blockingDatastore{release: make(chan struct{})}
+	ds := NewAsyncDatastore(inner)
+	assert.NoError(t, ds.Configure(&configs.AppConfig{}, "blocking"))
+
+	assert.NoError(t, ds.(data.Closer).Close(context.Background()))
+	close(inner.release)
+
+	// The connection established after closing is released right away
+	assert.Eventually(t, inner.closed.Load, time.Second, 10*time.Millisecond)
+	_, err := ds.Execute(context.Background(), nil)
+	assert.ErrorAs(t, err, &internalErrors.DatastoreUnavailable{})
+}
+
+func Test_AsyncDatastore_ReloadCallOperandsWhileConnecting(t *testing.T) {
+	inner := &reloadingDatastore{blockingDatastore: blockingDatastore{release: make(chan struct{})}}
+	ds := NewAsyncDatastore(inner)
+	assert.NoError(t, ds.Configure(&configs.AppConfig{}, "blocking"))
+
+	callOps := map[string]map[string]func(args ...string) (string, error){"mysql": {}}
+	assert.NoError(t, ds.(data.CallOperandsReloader).ReloadCallOperands(callOps))
+	assert.Nil(t, inner.callOps.Load())
+
+	// The reloaded call operands are applied as soon as the datastore is configured
+	close(inner.release)
+	assert.Eventually(t, func() bool {
+		ready, _ := ds.(data.ReadinessReporter).Ready()
+		return ready
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, callOps, *inner.callOps.Load())
+}
+
+func Test_AsyncDatastore_InvalidConfiguration(t *testing.T) {
+	for name, conf := range map[string]*configs.Datastore{
+		"missing password": {Type: data.TypeMysql, Connection: map[string]string{"host": "localhost", "port": "3306", "database": "appstore", "user": "kelon"}},
+		"unknown type":     {Type: "oracle", Connection: map[string]string{"host": "localhost", "port": "1521", "database": "appstore", "user": "kelon", "password": "secret"}},
+		"invalid metadata": {Type: data.TypeMysql, Connection: map[string]string{"host": "localhost", "port": "3306", "database": "appstore", "user": "kelon", "password": "secret"}, Metadata: map[string]string{"maxOpenConnections": "many"}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			appConf := &configs.AppConfig{ExternalConfig: configs.ExternalConfig{Datastores: map[string]*configs.Datastore{"mysql": conf}}}
+			ds := NewAsyncDatastore(NewResilientDatastore(NewDatastore(NewSQLDatastoreTranslator(), NewSQLDatastoreExecutor())))
+			assert.Error(t, ds.Configure(appConf, "mysql"))
+		})
+	}
+}
+
+func Test_AsyncDatastore_PermanentErrorIsNotRetried(t *testing.T) {
+	inner := &failingDatastore{err: permanentError{cause: errors.New("access denied")}}
+	ds := NewAsyncDatastore(inner)
+	ds.(*asyncDatastore).retryInterval = time.Millisecond
+	assert.NoError(t, ds.Configure(&configs.AppConfig{}, "failing"))
+
+	assert.Eventually(t, func() bool {
+		_, err := ds.(data.ReadinessReporter).Ready()
+		return err != nil
+	}, time.Second, 10*time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, int32(1), inner.configures.Load())
+
+	ready, err := ds.(data.ReadinessReporter).Ready()
+	assert.False(t, ready)
+	assert.EqualError(t, err, "access denied")
+}
+
+func Test_AsyncDatastore_ConnectionErrorIsRetried(t *testing.T) {
+	inner := &failingDatastore{err: errors.New("connection refused")}
+	ds := NewAsyncDatastore(inner)
+	ds.(*asyncDatastore).retryInterval = time.Millisecond
+	assert.NoError(t, ds.Configure(&configs.AppConfig{}, "failing"))
+	defer ds.(data.Closer).Close(context.Background())
+
+	assert.Eventually(t, func() bool { return inner.configures.Load() > 2 }, time.Second, 10*time.Millisecond)
+}
@@ -35,14 +35,54 @@ func extractAndValidateDatastore(appConf *configs.AppConfig, alias string) (*con
 	if strings.EqualFold(conf.Type, "") {
 		return nil, errors.Errorf("Alias of datastore is empty! Must be one of %+v!", sql.Drivers())
 	}
-	if err := validateConnection(alias, conf.Connection); err != nil {
+	if err := validateConnection(alias, conf.Connection, len(conf.Hosts) > 0); err != nil {
+		return nil, err
+	}
+	if err := validateHosts(alias, conf.Hosts); err != nil {
 		return nil, err
 	}
 
 	return conf, nil
 }
 
-// pingUntilReachable tries to call the provided ping function until a stable connection is established
+// permanentError marks an error while connecting to a datastore, which won't be solved by retrying (i.e. bad credentials)
+type permanentError struct {
+	cause error
+}
+
+func (err permanentError) Error() string {
+	return err.cause.Error()
+}
+
+func (err permanentError) Unwrap() error {
+	return err.cause
+}
+
+// isPermanentError checks whether retrying to connect after the error is pointless
+func isPermanentError(err error) bool {
+	var permanent permanentError
+	return errors.As(err, &permanent)
+}
+
+// validateDatastore validates the configuration of the component, if it is able to
+func validateDatastore(component any, appConf *configs.AppConfig, alias string) error {
+	if validator, ok := component.(data.ConfigValidator); ok {
+		return validator.Validate(appConf, alias)
+	}
+	return nil
+}
+
+// reloadCallOperands passes the call operands to the component, if it is able to reload them
+func reloadCallOperands(component any, callOps map[string]map[string]func(args ...string) (string, error)) error {
+	reloader, ok := component.(data.CallOperandsReloader)
+	if !ok {
+		return errors.Errorf("%T does not support reloading call operands", component)
+	}
+	return reloader.ReloadCallOperands(callOps)
+}
+
+// pingUntilReachable tries to call the provided ping function until a stable connection is established.
+// Permanent errors are returned immediately.
 func pingUntilReachable(alias string, ping func() error) error {
 	var pingFailure error
 	for i := 0; i < 20; i++ {
@@ -50,6 +90,9 @@ func pingUntilReachable(alias string, ping func() error) error {
 			// Ping succeeded
 			return nil
 		}
+		if isPermanentError(pingFailure) {
+			break
+		}
 		logging.LogForComponent("datastore").Infof("Waiting for [%s] to be reachable...", alias)
 		<-time.After(3 * time.Second)
 	}
@@ -59,12 +102,13 @@ func pingUntilReachable(alias string, ping func() error) error {
 	return nil
 }
 
-// validateConnection checks whether all necessary config options are provided
-func validateConnection(alias string, conn map[string]string) error {
-	if _, ok := conn[keyHost]; !ok {
+// validateConnection checks whether all necessary config options are provided.
+// Host and port are only required if no separate hosts are configured.
+func validateConnection(alias string, conn map[string]string, hasHosts bool) error {
+	if _, ok := conn[keyHost]; !ok && !hasHosts {
 		return errors.Errorf("SqlDatastore: Field %s is missing in configured connection with alias %s!", keyHost, alias)
 	}
-	if _, ok := conn[keyPort]; !ok {
+	if _, ok := conn[keyPort]; !ok && !hasHosts {
 		return errors.Errorf("SqlDatastore: Field %s is missing in configured connection with alias %s!", keyPort, alias)
 	}
 	if _, ok := conn[keyDB]; !ok {
@@ -79,6 +123,35 @@ func validateConnection(alias string, conn map[string]string) error {
 	return nil
 }
 
+// validateHosts checks whether all configured hosts are complete and have a known role
+func validateHosts(alias string, hosts []*configs.DatastoreHost) error {
+	for i, host := range hosts {
+		if host.Host == "" {
+			return errors.Errorf("SqlDatastore: Field %s is missing in host %d of datastore with alias %s!", keyHost, i, alias)
+		}
+		if host.Port == "" {
+			return errors.Errorf("SqlDatastore: Field %s is missing in host %d of datastore with alias %s!", keyPort, i, alias)
+		}
+		switch host.Role {
+		case "", configs.HostRolePrimary, configs.HostRoleReplica:
+		default:
+			return errors.Errorf("SqlDatastore: Host %d of datastore with alias %s has unknown role %q! Must be one of [%s, %s]", i, alias, host.Role, configs.HostRolePrimary, configs.HostRoleReplica)
+		}
+	}
+	return nil
+}
+
+// connectionForHost returns a copy of the connection options with host and port of the provided host
+func connectionForHost(conn map[string]string, host *configs.DatastoreHost) map[string]string {
+	result := make(map[string]string, len(conn)+2)
+	for key, value := range conn {
+		result[key] = value
+	}
+	result[keyHost] = host.Host
+	result[keyPort] = host.Port
+	return result
+}
+
 // getConnectionStringForPlatform builds a connection string from the connection options for a specific platform
 func getConnectionStringForPlatform(platform string, conn map[string]string) string {
 	params := extractAndSortConnectionParameters(conn)
@@ -2,6 +2,7 @@ package data
 
 import (
 	"context"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/unbasical/kelon/configs"
@@ -57,6 +58,17 @@ func (ds *defaultDatastore) Configure(appConf *configs.AppConfig, alias string)
 	return nil
 }
 
+// Validate - see data.ConfigValidator
+func (ds *defaultDatastore) Validate(appConf *configs.AppConfig, alias string) error {
+	if ds.translator == nil {
+		return errors.Errorf("Datastore: DatastoreTranslator not configured!")
+	}
+	if ds.executor == nil {
+		return errors.Errorf("Datastore: DatastoreExecutor not configured!")
+	}
+	return validateDatastore(ds.executor, appConf, alias)
+}
+
 func (ds *defaultDatastore) Execute(ctx context.Context, astQuery data.Node) (bool, error) {
 	if !ds.configured {
 		return false, errors.Errorf("Datastore: Datastore was not configured! Please call Configure().")
@@ -69,5 +81,62 @@ func (ds *defaultDatastore) Execute(ctx context.Context, astQuery data.Node) (bo
 	}
 
 	// Execute native Query
-	return ds.executor.Execute(ctx, dsQuery)
+	return ds.executeAndRecord(ctx, dsQuery)
+}
+
+// Translate - see data.PreparedDatastore
+func (ds *defaultDatastore) Translate(ctx context.Context, astQuery data.Node) (data.DatastoreQuery, error) {
+	if !ds.configured {
+		return data.DatastoreQuery{}, errors.Errorf("Datastore: Datastore was not configured! Please call Configure().")
+	}
+	return ds.translator.Execute(ctx, astQuery)
+}
+
+// ExecuteQuery - see data.PreparedDatastore
+func (ds *defaultDatastore) ExecuteQuery(ctx context.Context, dsQuery data.DatastoreQuery) (bool, error) {
+	if !ds.configured {
+		return false, errors.Errorf("Datastore: Datastore was not configured! Please call Configure().")
+	}
+	return ds.executeAndRecord(ctx, dsQuery)
+}
+
+// executeAndRecord executes the native query and adds it to the query recorder of the context, if there is one.
+// Identical queries of a batch are only executed once.
+func (ds *defaultDatastore) executeAndRecord(ctx context.Context, dsQuery data.DatastoreQuery) (bool, error) {
+	startTime := time.Now()
+	allowed, err := data.ExecuteShared(ctx, ds.alias, dsQuery, func() (bool, error) {
+		return ds.executor.Execute(ctx, dsQuery)
+	})
+	data.RecordQuery(ctx, ds.alias, dsQuery, time.Since(startTime), err)
+	return allowed, err
+}
+
+// ReloadCallOperands - see data.CallOperandsReloader
+func (ds *defaultDatastore) ReloadCallOperands(callOps map[string]map[string]func(args ...string) (string, error)) error {
+	if !ds.configured {
+		return errors.Errorf("Datastore: Datastore was not configured! Please call Configure().")
+	}
+	return reloadCallOperands(ds.translator, callOps)
+}
+
+// Ping - see data.Pinger
+func (ds *defaultDatastore) Ping(ctx context.Context) error {
+	if !ds.configured {
+		return errors.Errorf("Datastore: Datastore was not configured! Please call Configure().")
+	}
+	if pinger, ok := ds.executor.(data.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+// Close - see data.Closer
+func (ds *defaultDatastore) Close(ctx context.Context) error {
+	if !ds.configured {
+		return nil
+	}
+	if closer, ok := ds.executor.(data.Closer); ok {
+		return closer.Close(ctx)
+	}
+	return nil
 }
@@ -23,11 +23,11 @@ func makeExecutingDatastores(config *configs.ExternalConfig) map[string]*data.Da
 	for dsName, ds := range config.Datastores {
 		switch ds.Type {
 		case data.TypeMysql, data.TypePostgres:
-			newDs := NewDatastore(NewSQLDatastoreTranslator(), NewSQLDatastoreExecutor())
+			newDs := NewAsyncDatastore(NewResilientDatastore(NewDatastore(NewSQLDatastoreTranslator(), NewSQLDatastoreExecutor())))
 			logging.LogForComponent("factory").Infof("Init SqlDatastore of type [%s] with alias [%s]", ds.Type, dsName)
 			result[dsName] = &newDs
 		case data.TypeMongo:
-			newDs := NewDatastore(NewMongoDatastoreTranslator(), NewMongoDatastoreExecuter())
+			newDs := NewAsyncDatastore(NewResilientDatastore(NewDatastore(NewMongoDatastoreTranslator(), NewMongoDatastoreExecuter())))
 			logging.LogForComponent("factory").Infof("Init MongoDatastore of type [%s] with alias [%s]", ds.Type, dsName)
 			result[dsName] = &newDs
 		default:
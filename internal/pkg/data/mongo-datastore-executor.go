@@ -15,6 +15,8 @@ import (
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.mongodb.org/mongo-driver/x/mongo/driver/auth"
+	"go.mongodb.org/mongo-driver/x/mongo/driver/topology"
 )
 
 type mongoDatastoreExecuter struct {
@@ -31,12 +33,28 @@ func NewMongoDatastoreExecuter() data.DatastoreExecutor {
 	}
 }
 
+// Validate - see data.ConfigValidator
+func (ds *mongoDatastoreExecuter) Validate(appConf *configs.AppConfig, alias string) error {
+	conf, err := extractAndValidateDatastore(appConf, alias)
+	if err != nil {
+		return errors.Wrap(err, "mongoDatastoreExecuter:")
+	}
+	if conf.Type != data.TypeMongo {
+		return errors.Errorf("MongoDatastore: Datastore [%s] has unsupported type %q! Must be %s", alias, conf.Type, data.TypeMongo)
+	}
+	if len(conf.Hosts) > 0 {
+		// The mongo driver discovers all members of a replica set on its own
+		return errors.Errorf("MongoDatastore: Multiple hosts are not supported for datastore [%s], please use the replicaSet connection option instead", alias)
+	}
+	return nil
+}
+
 func (ds *mongoDatastoreExecuter) Configure(appConf *configs.AppConfig, alias string) error {
 	// Validate config
-	conf, e := extractAndValidateDatastore(appConf, alias)
-	if e != nil {
-		return errors.Wrap(e, "mongoDatastoreExecuter:")
+	if err := ds.Validate(appConf, alias); err != nil {
+		return err
 	}
+	conf := appConf.Datastores[alias]
 
 	// Connect client
 	ctx, cancel := context.WithTimeout(context.TODO(), 10*time.Second)
@@ -49,21 +67,16 @@ func (ds *mongoDatastoreExecuter) Configure(appConf *configs.AppConfig, alias st
 	}
 
 	// Ping mongodb for 60 seconds every 3 seconds
-	ctx, cancel = context.WithTimeout(context.Background(), 2*time.Second)
-	defer cancel()
+	// The client reconnects on its own, therefore each ping only needs a fresh timeout
 	err = pingUntilReachable(alias, func() error {
-		pingErr := client.Ping(ctx, readpref.Primary())
-
-		if pingErr != nil {
-			_ = client.Disconnect(ctx)
-			client, err = mongo.Connect(ctx, clientOptions)
-			time.Sleep(1000 * time.Millisecond)
-			return err
-		}
-		return nil
+		pingCtx, pingCancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer pingCancel()
+		return permanentMongoError(client.Ping(pingCtx, readpref.Primary()))
 	})
 	// Wait for mongo to be able to fulfill query requests
 	if err != nil {
+		// Release the client, because Configure might be called again
+		_ = client.Disconnect(context.Background())
 		return errors.Wrap(err, "MongoDatastoreExecutor:")
 	}
 
@@ -73,6 +86,35 @@ func (ds *mongoDatastoreExecuter) Configure(appConf *configs.AppConfig, alias st
 	return nil
 }
 
+// permanentMongoError marks authentication errors as permanent. Because the driver connects in the background,
+// the error might only be part of the server description of a failed server selection.
+func permanentMongoError(err error) error {
+	var authErr *auth.Error
+	if errors.As(err, &authErr) {
+		return permanentError{cause: err}
+	}
+	var selectionErr topology.ServerSelectionError
+	if errors.As(err, &selectionErr) {
+		for _, server := range selectionErr.Desc.Servers {
+			if errors.As(server.LastError, &authErr) {
+				return permanentError{cause: err}
+			}
+		}
+	}
+	return err
+}
+
+// Close - see data.Closer
+func (ds *mongoDatastoreExecuter) Close(ctx context.Context) error {
+	// Disconnect waits for all in-use connections to be returned to the pool
+	return ds.client.Disconnect(ctx)
+}
+
+// Ping - see data.Pinger
+func (ds *mongoDatastoreExecuter) Ping(ctx context.Context) error {
+	return ds.client.Ping(ctx, readpref.Primary())
+}
+
 func (ds *mongoDatastoreExecuter) Execute(ctx context.Context, query data.DatastoreQuery) (bool, error) {
 	mongoStatements, ok := query.Statement.(map[string]string)
 	if !ok {
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"regexp"
 	"strings"
+	"sync/atomic"
 
 	"github.com/pkg/errors"
 	"github.com/unbasical/kelon/configs"
@@ -23,7 +24,8 @@ type mongoDatastoreTranslator struct {
 	appConf     *configs.AppConfig
 	alias       string
 	entityPaths entityPaths
-	callOps     callOperands
+	platform    string
+	callOps     atomic.Pointer[callOperands]
 	configured  bool
 }
 
@@ -37,7 +39,6 @@ func NewMongoDatastoreTranslator() data.DatastoreTranslator {
 	return &mongoDatastoreTranslator{
 		appConf:    nil,
 		alias:      "",
-		callOps:    nil,
 		configured: false,
 	}
 }
@@ -66,7 +67,7 @@ func (ds *mongoDatastoreTranslator) Configure(appConf *configs.AppConfig, alias
 	if !ok {
 		return errors.Errorf("no call-operands found for datastore with type [%s]", conf.Type)
 	}
-	ds.callOps = operands
+	ds.callOps.Store(&operands)
 	logging.LogForComponent("mongoDatastoreTranslator").Infof("[%s] loaded call operands", alias)
 
 	// Load entity schemas
@@ -81,11 +82,27 @@ func (ds *mongoDatastoreTranslator) Configure(appConf *configs.AppConfig, alias
 	// Assign values
 	ds.appConf = appConf
 	ds.alias = alias
+	ds.platform = conf.Type
 	ds.configured = true
 	logging.LogForComponent("mongoDatastoreTranslator").Infof("Configured [%s]", alias)
 	return nil
 }
 
+// ReloadCallOperands - see data.CallOperandsReloader
+func (ds *mongoDatastoreTranslator) ReloadCallOperands(callOps map[string]map[string]func(args ...string) (string, error)) error {
+	if !ds.configured {
+		return errors.Errorf("MongoDatastoreTranslator: DatastoreTranslator was not configured! Please call Configure(). ")
+	}
+
+	operands, ok := callOps[ds.platform]
+	if !ok {
+		return errors.Errorf("no call-operands found for datastore with type [%s]", ds.platform)
+	}
+	ds.callOps.Store(&operands)
+	logging.LogForComponent("mongoDatastoreTranslator").Infof("[%s] reloaded call operands", ds.alias)
+	return nil
+}
+
 func (ds *mongoDatastoreTranslator) Execute(_ context.Context, query data.Node) (data.DatastoreQuery, error) {
 	if !ds.configured {
 		return data.DatastoreQuery{}, errors.Errorf("MongoDatastoreTranslator: Datastore was not configured! Please call Configure().")
@@ -94,7 +111,7 @@ func (ds *mongoDatastoreTranslator) Execute(_ context.Context, query data.Node)
 
 	// Translate to map: collection -> filter
 	t := newMongoTranslator()
-	statement, err := t.Translate(query, ds.entityPaths, ds.callOps)
+	statement, err := t.Translate(query, ds.entityPaths, *ds.callOps.Load())
 	if err != nil {
 		return data.DatastoreQuery{}, err
 	}
@@ -81,6 +81,9 @@ func (h *loadedCallHandler) Init() error {
 		if err != nil {
 			return errors.Wrap(err, "Unable to load datastore-call-operands")
 		}
+		if num >= h.ArgsCount {
+			return errors.Errorf("mapping of call-operand %q references argument %s, but only has %d args", h.Operator, arg, h.ArgsCount)
+		}
 		h.indexMapping = append(h.indexMapping, num)
 	}
 
@@ -117,6 +120,17 @@ func (h *loadedCallHandler) Map(args ...string) (string, error) {
 // LoadAllCallOperands will try loading the call operands from the configured directory.
 // If directory was not configured or and error while parsing occurred, the default call operands will be used.
 func LoadAllCallOperands(dsConfs map[string]*configs.Datastore, callOperandsDir *string) (map[string]map[string]func(args ...string) (string, error), error) {
+	return loadAllCallOperands(dsConfs, callOperandsDir, false)
+}
+
+// ReloadAllCallOperands loads the call operands from the configured directory just like LoadAllCallOperands, but returns
+// an error if any existing call operands file is invalid. Functions which are registered as builtins must keep the amount
+// of arguments they were registered with, because the loaded regos were compiled against them.
+func ReloadAllCallOperands(dsConfs map[string]*configs.Datastore, callOperandsDir *string) (map[string]map[string]func(args ...string) (string, error), error) {
+	return loadAllCallOperands(dsConfs, callOperandsDir, true)
+}
+
+func loadAllCallOperands(dsConfs map[string]*configs.Datastore, callOperandsDir *string, strict bool) (map[string]map[string]func(args ...string) (string, error), error) {
 	operands := map[string]map[string]func(args ...string) (string, error){}
 	dsFunctions := map[string]int{}
 
@@ -134,6 +148,9 @@ func LoadAllCallOperands(dsConfs map[string]*configs.Datastore, callOperandsDir
 			callOpsFilePath := fmt.Sprintf("%s/%s.yml", *callOperandsDir, strings.ToLower(dsConf.Type))
 			customHandlers, parseErr = loadDatastoreCallOpsFile(callOpsFilePath, dsFunctions)
 			if parseErr != nil {
+				if strict && !errors.Is(parseErr, os.ErrNotExist) {
+					return nil, errors.Wrapf(parseErr, "invalid custom call operands for datastores of type %s", dsConf.Type)
+				}
 				logging.LogForComponent("callOperandsLoader").Warnf("failed loading custom call operands for %s. Only default call operands will be used: %s", dsConf.Type, parseErr.Error())
 			}
 		}
@@ -168,9 +185,11 @@ func loadDatastoreCallOpsBytes(input []byte, dsFunctions map[string]int) ([]data
 		if h.RegisterBuiltin {
 			// Check function to be registered expects same args count as already registered function
 			if argsCount, ok := dsFunctions[h.Operator]; ok && argsCount != h.ArgsCount {
-				return nil, errors.Errorf("tried registering function %q with %d args but was already registered with %d args", h.Operator, argsCount, h.ArgsCount)
+				return nil, errors.Errorf("tried registering function %q with %d args but was already registered with %d args", h.Operator, h.ArgsCount, argsCount)
+			}
+			if argsCount, ok := builtins.DatastoreFunctionArgs(h.Operator); ok && argsCount != h.ArgsCount {
+				return nil, errors.Errorf("tried registering function %q with %d args but the builtin was already registered with %d args", h.Operator, h.ArgsCount, argsCount)
 			}
-			builtins.RegisterDatastoreFunction(h.Operator, h.ArgsCount)
 			dsFunctions[h.Operator] = h.ArgsCount
 		}
 
@@ -180,6 +199,13 @@ func loadDatastoreCallOpsBytes(input []byte, dsFunctions map[string]int) ([]data
 		result[i] = h
 	}
 
+	// Register builtins only after all call operands are valid
+	for _, h := range loadedConf.CallOperands {
+		if h.RegisterBuiltin {
+			builtins.RegisterDatastoreFunction(h.Operator, h.ArgsCount)
+		}
+	}
+
 	return result, nil
 }
 
@@ -1,6 +1,8 @@
 package data
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -45,3 +47,43 @@ func Test_Operands_LoadNonExisting(t *testing.T) {
 	_, err := LoadAllCallOperands(dummyDatastoreConf, &dirpath)
 	assert.NoError(t, err, "no errors should be thrown. default call operands should be loaded")
 }
+
+func writeCallOperands(t *testing.T, dir, content string) {
+	t.Helper()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "mongo.yml"), []byte(content), 0o600))
+}
+
+func Test_Operands_ReloadMissingFile(t *testing.T) {
+	dirpath := t.TempDir()
+	_, err := ReloadAllCallOperands(dummyDatastoreConf, &dirpath)
+	assert.NoError(t, err, "missing custom call operands should fall back to the default ones")
+}
+
+func Test_Operands_ReloadInvalid(t *testing.T) {
+	dirpath := t.TempDir()
+	writeCallOperands(t, dirpath, "call-operands: [")
+
+	_, err := LoadAllCallOperands(dummyDatastoreConf, &dirpath)
+	assert.NoError(t, err, "invalid custom call operands should be ignored on startup")
+	_, err = ReloadAllCallOperands(dummyDatastoreConf, &dirpath)
+	assert.Error(t, err, "invalid custom call operands should be rejected on reload")
+}
+
+func Test_Operands_ReloadUnknownArgument(t *testing.T) {
+	dirpath := t.TempDir()
+	writeCallOperands(t, dirpath, "call-operands:\n  - op: custom\n    args: 1\n    mapping: \"custom $1\"\n")
+
+	_, err := ReloadAllCallOperands(dummyDatastoreConf, &dirpath)
+	assert.Error(t, err, "mappings must only reference declared arguments")
+}
+
+func Test_Operands_ReloadChangedBuiltinArgs(t *testing.T) {
+	dirpath := t.TempDir()
+	writeCallOperands(t, dirpath, "call-operands:\n  - op: reload_builtin\n    args: 1\n    mapping: \"fn($0)\"\n    register-builtin: true\n")
+	_, err := ReloadAllCallOperands(dummyDatastoreConf, &dirpath)
+	assert.NoError(t, err)
+
+	writeCallOperands(t, dirpath, "call-operands:\n  - op: reload_builtin\n    args: 2\n    mapping: \"fn($0, $1)\"\n    register-builtin: true\n")
+	_, err = ReloadAllCallOperands(dummyDatastoreConf, &dirpath)
+	assert.Error(t, err, "registered builtins must keep their amount of arguments")
+}
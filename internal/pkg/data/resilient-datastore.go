@@ -0,0 +1,337 @@
+package data
+
+import (
+	"context"
+	"database/sql/driver"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/pkg/errors"
+	"github.com/unbasical/kelon/configs"
+	"github.com/unbasical/kelon/pkg/constants"
+	"github.com/unbasical/kelon/pkg/constants/logging"
+	"github.com/unbasical/kelon/pkg/data"
+	internalErrors "github.com/unbasical/kelon/pkg/errors"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// resilientDatastore wraps a data.Datastore and retries transient errors with an exponential backoff.
+// Additionally, a circuit breaker opens after a configured amount of consecutive failures, so that requests
+// fail fast instead of waiting for a dead backend.
+type resilientDatastore struct {
+	datastore  data.Datastore
+	appConf    *configs.AppConfig
+	alias      string
+	configured bool
+
+	maxRetries   int
+	backoff      time.Duration
+	threshold    int
+	resetTimeout time.Duration
+
+	mutex    sync.Mutex
+	state    string
+	failures int
+	openedAt time.Time
+	trial    bool
+}
+
+// NewResilientDatastore wraps the provided data.Datastore with retries and a circuit breaker,
+// which are configured via the metadata of the datastore.
+func NewResilientDatastore(datastore data.Datastore) data.Datastore {
+	return &resilientDatastore{
+		datastore:  datastore,
+		appConf:    nil,
+		alias:      "",
+		configured: false,
+		state:      data.CircuitClosed,
+	}
+}
+
+// Configure - see data.Datastore
+func (ds *resilientDatastore) Configure(appConf *configs.AppConfig, alias string) error {
+	// Exit if already configured
+	if ds.configured {
+		return nil
+	}
+
+	if ds.datastore == nil {
+		return errors.Errorf("ResilientDatastore: Datastore not configured!")
+	}
+	if err := ds.datastore.Configure(appConf, alias); err != nil {
+		return err
+	}
+
+	conf, ok := appConf.Datastores[alias]
+	if !ok {
+		return errors.Errorf("ResilientDatastore: No datastore with alias [%s] configured!", alias)
+	}
+	if err := ds.applyMetadataConfigs(conf); err != nil {
+		return errors.Wrapf(err, "ResilientDatastore: Error while configuring metadata of [%s]", alias)
+	}
+
+	// Assign values
+	ds.appConf = appConf
+	ds.alias = alias
+	ds.configured = true
+	return nil
+}
+
+// Validate - see data.ConfigValidator
+func (ds *resilientDatastore) Validate(appConf *configs.AppConfig, alias string) error {
+	if ds.datastore == nil {
+		return errors.Errorf("ResilientDatastore: Datastore not configured!")
+	}
+	if err := validateDatastore(ds.datastore, appConf, alias); err != nil {
+		return err
+	}
+
+	conf, ok := appConf.Datastores[alias]
+	if !ok {
+		return errors.Errorf("ResilientDatastore: No datastore with alias [%s] configured!", alias)
+	}
+	// The metadata is applied to a throwaway datastore, so that the configuration of this one stays untouched
+	if err := (&resilientDatastore{}).applyMetadataConfigs(conf); err != nil {
+		return errors.Wrapf(err, "ResilientDatastore: Error while configuring metadata of [%s]", alias)
+	}
+	return nil
+}
+
+// applyMetadataConfigs sets the optional retry and circuit breaker configuration.
+// Retries and the circuit breaker are disabled if not configured.
+func (ds *resilientDatastore) applyMetadataConfigs(conf *configs.Datastore) error {
+	var err error
+	if ds.maxRetries, err = metadataInt(conf.Metadata, constants.MetaRetryMaxAttempts, 0); err != nil {
+		return err
+	}
+	backoffMillis, err := metadataInt(conf.Metadata, constants.MetaRetryBackoffMilliseconds, 100)
+	if err != nil {
+		return err
+	}
+	ds.backoff = time.Duration(backoffMillis) * time.Millisecond
+	if ds.threshold, err = metadataInt(conf.Metadata, constants.MetaCircuitBreakerThreshold, 0); err != nil {
+		return err
+	}
+	resetSeconds, err := metadataInt(conf.Metadata, constants.MetaCircuitBreakerResetSeconds, 30)
+	if err != nil {
+		return err
+	}
+	ds.resetTimeout = time.Duration(resetSeconds) * time.Second
+	return nil
+}
+
+// Execute - see data.Datastore
+func (ds *resilientDatastore) Execute(ctx context.Context, query data.Node) (bool, error) {
+	return ds.execute(ctx, func(ctx context.Context) (bool, error) {
+		return ds.datastore.Execute(ctx, query)
+	})
+}
+
+// Translate - see data.PreparedDatastore
+func (ds *resilientDatastore) Translate(ctx context.Context, query data.Node) (data.DatastoreQuery, error) {
+	prepared, ok := ds.datastore.(data.PreparedDatastore)
+	if !ok {
+		return data.DatastoreQuery{}, errors.Errorf("ResilientDatastore: Wrapped datastore of type %T does not support prepared queries", ds.datastore)
+	}
+	return prepared.Translate(ctx, query)
+}
+
+// ExecuteQuery - see data.PreparedDatastore
+func (ds *resilientDatastore) ExecuteQuery(ctx context.Context, query data.DatastoreQuery) (bool, error) {
+	prepared, ok := ds.datastore.(data.PreparedDatastore)
+	if !ok {
+		return false, errors.Errorf("ResilientDatastore: Wrapped datastore of type %T does not support prepared queries", ds.datastore)
+	}
+	return ds.execute(ctx, func(ctx context.Context) (bool, error) {
+		return prepared.ExecuteQuery(ctx, query)
+	})
+}
+
+// ReloadCallOperands - see data.CallOperandsReloader
+func (ds *resilientDatastore) ReloadCallOperands(callOps map[string]map[string]func(args ...string) (string, error)) error {
+	return reloadCallOperands(ds.datastore, callOps)
+}
+
+// Ping - see data.Pinger
+func (ds *resilientDatastore) Ping(ctx context.Context) error {
+	if pinger, ok := ds.datastore.(data.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+// Close - see data.Closer
+func (ds *resilientDatastore) Close(ctx context.Context) error {
+	if closer, ok := ds.datastore.(data.Closer); ok {
+		return closer.Close(ctx)
+	}
+	return nil
+}
+
+// execute runs the provided function guarded by the circuit breaker and retries transient errors
+func (ds *resilientDatastore) execute(ctx context.Context, function func(ctx context.Context) (bool, error)) (bool, error) {
+	if !ds.configured {
+		return false, errors.Errorf("ResilientDatastore: Datastore was not configured! Please call Configure().")
+	}
+
+	var (
+		result  bool
+		err     error
+		backoff = ds.backoff
+	)
+	for attempt := 0; attempt <= ds.maxRetries; attempt++ {
+		if attempt > 0 {
+			logging.LogForComponent("resilientDatastore").Warnf("Retrying query against [%s] in %s due to: %s", ds.alias, backoff, err)
+			select {
+			case <-ctx.Done():
+				return false, errors.Wrap(ctx.Err(), "ResilientDatastore: Aborted retry")
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+		}
+
+		if !ds.allowRequest(ctx) {
+			return false, internalErrors.DatastoreUnavailable{Alias: ds.alias, Msg: "circuit breaker is open"}
+		}
+
+		result, err = function(ctx)
+		if err == nil {
+			ds.recordSuccess(ctx)
+			return result, nil
+		}
+
+		// Only errors of the backend itself are retried and counted as failure
+		if ctx.Err() != nil || !isTransientError(err) {
+			ds.recordNeutral()
+			return false, err
+		}
+		ds.recordFailure(ctx)
+	}
+	return false, errors.Wrapf(err, "ResilientDatastore: Query against [%s] failed after %d attempts", ds.alias, ds.maxRetries+1)
+}
+
+// CircuitState - see data.CircuitBreaker
+func (ds *resilientDatastore) CircuitState() string {
+	ds.mutex.Lock()
+	defer ds.mutex.Unlock()
+
+	// An expired open circuit will let the next request pass
+	if ds.state == data.CircuitOpen && time.Since(ds.openedAt) >= ds.resetTimeout {
+		return data.CircuitHalfOpen
+	}
+	return ds.state
+}
+
+// allowRequest checks whether the circuit lets a request pass.
+// After the reset timeout an open circuit becomes half-open and lets exactly one trial request pass.
+func (ds *resilientDatastore) allowRequest(ctx context.Context) bool {
+	ds.mutex.Lock()
+	defer ds.mutex.Unlock()
+
+	switch ds.state {
+	case data.CircuitOpen:
+		if time.Since(ds.openedAt) < ds.resetTimeout {
+			return false
+		}
+		ds.transition(ctx, data.CircuitHalfOpen)
+		ds.trial = true
+		return true
+	case data.CircuitHalfOpen:
+		if ds.trial {
+			return false
+		}
+		ds.trial = true
+		return true
+	default:
+		return true
+	}
+}
+
+// recordSuccess closes the circuit
+func (ds *resilientDatastore) recordSuccess(ctx context.Context) {
+	ds.mutex.Lock()
+	defer ds.mutex.Unlock()
+
+	ds.failures = 0
+	ds.trial = false
+	ds.transition(ctx, data.CircuitClosed)
+}
+
+// recordNeutral releases a running trial without changing the state of the circuit
+func (ds *resilientDatastore) recordNeutral() {
+	ds.mutex.Lock()
+	defer ds.mutex.Unlock()
+
+	ds.trial = false
+}
+
+// recordFailure counts the failure and opens the circuit if the threshold is reached or the trial request failed
+func (ds *resilientDatastore) recordFailure(ctx context.Context) {
+	ds.mutex.Lock()
+	defer ds.mutex.Unlock()
+
+	ds.failures++
+	ds.trial = false
+	if ds.state == data.CircuitHalfOpen || (ds.threshold > 0 && ds.failures >= ds.threshold) {
+		ds.openedAt = time.Now()
+		ds.transition(ctx, data.CircuitOpen)
+	}
+}
+
+// transition changes the state of the circuit and updates the metrics. The mutex has to be held by the caller.
+func (ds *resilientDatastore) transition(ctx context.Context, state string) {
+	if ds.state == state {
+		return
+	}
+
+	labels := map[string]string{
+		constants.LabelDBPoolName: ds.alias,
+	}
+	switch {
+	case ds.state == data.CircuitClosed:
+		ds.appConf.MetricsProvider.UpdateGaugeMetric(ctx, constants.InstrumentDatastoreCircuitOpen, int64(1), labels)
+	case state == data.CircuitClosed:
+		ds.appConf.MetricsProvider.UpdateGaugeMetric(ctx, constants.InstrumentDatastoreCircuitOpen, int64(-1), labels)
+	}
+
+	logging.LogForComponent("resilientDatastore").Warnf("Circuit of datastore [%s] changed from %s to %s", ds.alias, ds.state, state)
+	ds.state = state
+}
+
+// isTransientError checks whether the error was caused by an unreachable or overloaded backend
+func isTransientError(err error) bool {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, context.DeadlineExceeded),
+		errors.Is(err, driver.ErrBadConn),
+		errors.Is(err, mysql.ErrInvalidConn),
+		errors.Is(err, io.EOF),
+		errors.Is(err, io.ErrUnexpectedEOF),
+		errors.As(err, &netErr),
+		mongo.IsNetworkError(err),
+		mongo.IsTimeout(err):
+		return true
+	default:
+		return false
+	}
+}
+
+// metadataInt parses the metadata value for the provided key as integer or returns the default value if not present
+func metadataInt(metadata map[string]string, key string, defaultValue int) (int, error) {
+	value, ok := metadata[key]
+	if !ok {
+		return defaultValue, nil
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, errors.Wrapf(err, "metadata %q is no valid integer", key)
+	}
+	if parsed < 0 {
+		return 0, errors.Errorf("metadata %q must not be negative", key)
+	}
+	return parsed, nil
+}
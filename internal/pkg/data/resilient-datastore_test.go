@@ -0,0 +1,151 @@
+package data
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/unbasical/kelon/configs"
+	"github.com/unbasical/kelon/pkg/constants"
+	"github.com/unbasical/kelon/pkg/data"
+	internalErrors "github.com/unbasical/kelon/pkg/errors"
+	"github.com/unbasical/kelon/pkg/telemetry"
+)
+
+type flakyDatastore struct {
+	errs  []error
+	calls int
+}
+
+func (ds *flakyDatastore) Configure(_ *configs.AppConfig, _ string) error {
+	return nil
+}
+
+func (ds *flakyDatastore) Execute(_ context.Context, _ data.Node) (bool, error) {
+	ds.calls++
+	if len(ds.errs) == 0 {
+		return true, nil
+	}
+	err := ds.errs[0]
+	ds.errs = ds.errs[1:]
+	return false, err
+}
+
+func makeResilientDatastore(t *testing.T, inner data.Datastore, metadata map[string]string) data.Datastore {
+	appConf := &configs.AppConfig{
+		ExternalConfig: configs.ExternalConfig{
+			Datastores: map[string]*configs.Datastore{
+				"flaky": {Type: data.TypePostgres, Metadata: metadata},
+			},
+		},
+		MetricsProvider: telemetry.NewNoopMetricProvider(),
+	}
+
+	ds := NewResilientDatastore(inner)
+	assert.NoError(t, ds.Configure(appConf, "flaky"))
+	return ds
+}
+
+func Test_ResilientDatastore_RetriesTransientErrors(t *testing.T) {
+	inner := &flakyDatastore{errs: []error{context.DeadlineExceeded, context.DeadlineExceeded}}
+	ds := makeResilientDatastore(t, inner, map[string]string{
+		constants.MetaRetryMaxAttempts:         "2",
+		constants.MetaRetryBackoffMilliseconds: "1",
+	})
+
+	result, err := ds.Execute(context.Background(), nil)
+	assert.NoError(t, err)
+	assert.True(t, result)
+	assert.Equal(t, 3, inner.calls)
+}
+
+func Test_ResilientDatastore_DoesNotRetryOtherErrors(t *testing.T) {
+	inner := &flakyDatastore{errs: []error{errors.New("syntax error")}}
+	ds := makeResilientDatastore(t, inner, map[string]string{
+		constants.MetaRetryMaxAttempts:         "2",
+		constants.MetaRetryBackoffMilliseconds: "1",
+		constants.MetaCircuitBreakerThreshold:  "1",
+	})
+
+	_, err := ds.Execute(context.Background(), nil)
+	assert.EqualError(t, err, "syntax error")
+	assert.Equal(t, 1, inner.calls)
+	assert.Equal(t, data.CircuitClosed, ds.(data.CircuitBreaker).CircuitState())
+}
+
+func Test_ResilientDatastore_OpensCircuit(t *testing.T) {
+	inner := &flakyDatastore{errs: []error{context.DeadlineExceeded, context.DeadlineExceeded}}
+	ds := makeResilientDatastore(t, inner, map[string]string{
+		constants.MetaCircuitBreakerThreshold:    "2",
+		constants.MetaCircuitBreakerResetSeconds: "60",
+	})
+
+	_, err := ds.Execute(context.Background(), nil)
+	assert.Error(t, err)
+	assert.Equal(t, data.CircuitClosed, ds.(data.CircuitBreaker).CircuitState())
+
+	_, err = ds.Execute(context.Background(), nil)
+	assert.Error(t, err)
+	assert.Equal(t, data.CircuitOpen, ds.(data.CircuitBreaker).CircuitState())
+
+	// Requests fail fast without reaching the backend
+	_, err = ds.Execute(context.Background(), nil)
+	assert.ErrorAs(t, err, &internalErrors.DatastoreUnavailable{})
+	assert.Equal(t, 2, inner.calls)
+}
+
+func Test_ResilientDatastore_ClosesCircuitAfterSuccessfulTrial(t *testing.T) {
+	inner := &flakyDatastore{errs: []error{context.DeadlineExceeded}}
+	ds := makeResilientDatastore(t, inner, map[string]string{
+		constants.MetaCircuitBreakerThreshold:    "1",
+		constants.MetaCircuitBreakerResetSeconds: "0",
+	})
+
+	_, err := ds.Execute(context.Background(), nil)
+	assert.Error(t, err)
+	assert.Equal(t, data.CircuitHalfOpen, ds.(data.CircuitBreaker).CircuitState())
+
+	result, err := ds.Execute(context.Background(), nil)
+	assert.NoError(t, err)
+	assert.True(t, result)
+	assert.Equal(t, data.CircuitClosed, ds.(data.CircuitBreaker).CircuitState())
+}
+
+// sharedDatastore executes all queries of the wrapped datastore as identical shared query, like defaultDatastore does
+type sharedDatastore struct {
+	datastore data.Datastore
+}
+
+func (ds *sharedDatastore) Configure(appConf *configs.AppConfig, alias string) error {
+	return ds.datastore.Configure(appConf, alias)
+}
+
+func (ds *sharedDatastore) Execute(ctx context.Context, query data.Node) (bool, error) {
+	return data.ExecuteShared(ctx, "flaky", data.DatastoreQuery{Statement: "SELECT 1"}, func() (bool, error) {
+		return ds.datastore.Execute(ctx, query)
+	})
+}
+
+func Test_ResilientDatastore_RetriesSharedQueries(t *testing.T) {
+	inner := &flakyDatastore{errs: []error{context.DeadlineExceeded}}
+	ds := makeResilientDatastore(t, &sharedDatastore{datastore: inner}, map[string]string{
+		constants.MetaRetryMaxAttempts:         "1",
+		constants.MetaRetryBackoffMilliseconds: "1",
+		constants.MetaCircuitBreakerThreshold:  "2",
+	})
+
+	// The failed query is not shared, so the retry within the batch reaches the backend
+	ctx := data.WithSharedQueries(context.Background(), &data.SharedQueries{})
+	result, err := ds.Execute(ctx, nil)
+	assert.NoError(t, err)
+	assert.True(t, result)
+	assert.Equal(t, 2, inner.calls)
+	assert.Equal(t, data.CircuitClosed, ds.(data.CircuitBreaker).CircuitState())
+
+	// Successful queries are still shared
+	result, err = ds.Execute(ctx, nil)
+	assert.NoError(t, err)
+	assert.True(t, result)
+	assert.Equal(t, 2, inner.calls)
+}
@@ -3,62 +3,142 @@ package data
 import (
 	"context"
 	"database/sql"
+	"fmt"
 	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
 	"github.com/pkg/errors"
 	"github.com/unbasical/kelon/configs"
 	"github.com/unbasical/kelon/pkg/constants"
 	"github.com/unbasical/kelon/pkg/constants/logging"
 	"github.com/unbasical/kelon/pkg/data"
+)
+
+// MySQL errors, which are caused by the configuration of the datastore
+const (
+	mysqlErrDBAccessDenied = 1044
+	mysqlErrAccessDenied   = 1045
+	mysqlErrBadDB          = 1049
+)
 
-	// Import mysql dirver
-	_ "github.com/go-sql-driver/mysql"
-	// import postgres driver
-	_ "github.com/lib/pq"
+// PostgreSQL errors, which are caused by the configuration of the datastore
+const (
+	pqErrClassInvalidAuthorization = "28"
+	pqErrInvalidCatalogName        = "3D000"
 )
 
 type sqlDatastoreExecutor struct {
+	hosts     []*sqlHost
+	next      atomic.Uint64
+	alias     string
+	appConf   *configs.AppConfig
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+// sqlHost is a single host of a datastore with its own connection pool
+type sqlHost struct {
+	address string
+	role    string
 	dbPool  *sql.DB
-	appConf *configs.AppConfig
+	healthy atomic.Bool
 }
 
 // NewSQLDatastoreExecutor instantiates a new DatastoreExecutor, which can be used for MySQL and PostgreSQL queries.
 func NewSQLDatastoreExecutor() data.DatastoreExecutor {
 	return &sqlDatastoreExecutor{
-		dbPool:  nil,
+		hosts:   nil,
+		alias:   "",
 		appConf: nil,
+		closed:  make(chan struct{}),
+	}
+}
+
+// Validate - see data.ConfigValidator
+func (ds *sqlDatastoreExecutor) Validate(appConf *configs.AppConfig, alias string) error {
+	conf, err := extractAndValidateDatastore(appConf, alias)
+	if err != nil {
+		return errors.Wrap(err, "sqlDatastoreExecutor:")
+	}
+	if conf.Type != data.TypeMysql && conf.Type != data.TypePostgres {
+		return errors.Errorf("sqlDatastoreExecutor: Datastore [%s] has unsupported type %q! Must be one of [%s, %s]", alias, conf.Type, data.TypeMysql, data.TypePostgres)
+	}
+	for _, key := range []string{constants.MetaMaxOpenConnections, constants.MetaMaxIdleConnections, constants.MetaConnectionMaxLifetimeSeconds} {
+		if value, ok := conf.Metadata[key]; ok {
+			if _, err := strconv.Atoi(value); err != nil {
+				return errors.Wrapf(err, "sqlDatastoreExecutor: Metadata %q of datastore [%s] is no valid integer", key, alias)
+			}
+		}
+	}
+	if _, err := metadataInt(conf.Metadata, constants.MetaHealthCheckIntervalSeconds, 10); err != nil {
+		return errors.Wrapf(err, "sqlDatastoreExecutor: Invalid metadata of datastore [%s]", alias)
 	}
+	return nil
 }
 
 // Configure -- see data.DatastoreExecutor
 func (ds *sqlDatastoreExecutor) Configure(appConf *configs.AppConfig, alias string) error {
 	// Validate config
-	conf, e := extractAndValidateDatastore(appConf, alias)
-	if e != nil {
-		return errors.Wrap(e, "sqlDatastoreExecutor:")
+	if err := ds.Validate(appConf, alias); err != nil {
+		return err
 	}
+	conf := appConf.Datastores[alias]
 
-	// Init database connection pool
-	db, err := sql.Open(conf.Type, getConnectionStringForPlatform(conf.Type, conf.Connection))
-	if err != nil {
-		return errors.Wrap(err, "SqlDatastore: Error while connecting to database")
+	// Without separate hosts, the connection itself is the only (primary) host
+	hostConfs := conf.Hosts
+	if len(hostConfs) == 0 {
+		hostConfs = []*configs.DatastoreHost{{Host: conf.Connection[keyHost], Port: conf.Connection[keyPort], Role: configs.HostRolePrimary}}
 	}
 
-	// Configure metadata
-	metadataError := ds.applyMetadataConfigs(conf, db)
-	if metadataError != nil {
-		return errors.Wrap(err, "sqlDatastoreExecutor: Error while configuring metadata")
+	// Init one database connection pool per host
+	ds.hosts = make([]*sqlHost, 0, len(hostConfs))
+	ds.alias = alias
+	for _, hostConf := range hostConfs {
+		db, err := sql.Open(conf.Type, getConnectionStringForPlatform(conf.Type, connectionForHost(conf.Connection, hostConf)))
+		if err != nil {
+			ds.closeHosts()
+			return errors.Wrap(err, "SqlDatastore: Error while connecting to database")
+		}
+
+		role := hostConf.Role
+		if role == "" {
+			role = configs.HostRolePrimary
+		}
+		ds.hosts = append(ds.hosts, &sqlHost{address: fmt.Sprintf("%s:%s", hostConf.Host, hostConf.Port), role: role, dbPool: db})
+
+		// Configure metadata
+		if metadataError := ds.applyMetadataConfigs(conf, db); metadataError != nil {
+			ds.closeHosts()
+			return errors.Wrap(metadataError, "sqlDatastoreExecutor: Error while configuring metadata")
+		}
 	}
 
-	// Ping database for 60 seconds every 3 seconds
-	err = pingUntilReachable(alias, db.Ping)
+	// Ping database for 60 seconds every 3 seconds until at least one host is reachable
+	err := pingUntilReachable(alias, func() error {
+		return permanentSQLError(ds.checkHosts())
+	})
 	if err != nil {
+		// Release the pools, because Configure might be called again
+		ds.closeHosts()
 		return errors.Wrap(err, "sqlDatastoreExecutor:")
 	}
 
+	// Health-check all hosts in the background, so that queries fail over to reachable hosts
+	if len(ds.hosts) > 1 {
+		interval, intervalErr := metadataInt(conf.Metadata, constants.MetaHealthCheckIntervalSeconds, 10)
+		if intervalErr != nil {
+			return errors.Wrap(intervalErr, "sqlDatastoreExecutor: Error while configuring metadata")
+		}
+		if interval > 0 {
+			go ds.healthCheck(time.Duration(interval) * time.Second)
+		}
+	}
+
 	ds.appConf = appConf
-	ds.dbPool = db
 	return nil
 }
 
@@ -94,6 +174,136 @@ func (ds *sqlDatastoreExecutor) applyMetadataConfigs(conf *configs.Datastore, db
 	return nil
 }
 
+// Close - see data.Closer
+// Only the first call closes the datastore, i.e. if it is retired by a reload and closed on shutdown afterwards.
+func (ds *sqlDatastoreExecutor) Close(_ context.Context) error {
+	ds.closeOnce.Do(func() {
+		close(ds.closed)
+		// Closing a pool waits for all running queries to finish
+		ds.closeHosts()
+		logging.LogForComponent("sqlDatastoreExecutor").Infof("Closed datastore [%s]", ds.alias)
+	})
+	return nil
+}
+
+// closeHosts closes the connection pools of all hosts. The hosts are kept, because health checks and pings might
+// still iterate them concurrently, which fail afterwards.
+func (ds *sqlDatastoreExecutor) closeHosts() {
+	for _, host := range ds.hosts {
+		if err := host.dbPool.Close(); err != nil {
+			logging.LogForComponent("sqlDatastoreExecutor").Warnf("Unable to close connection pool of host %s: %s", host.address, err)
+		}
+	}
+}
+
+// Ping - see data.Pinger
+func (ds *sqlDatastoreExecutor) Ping(ctx context.Context) error {
+	return ds.pingHosts(ctx)
+}
+
+// permanentSQLError marks errors, which are caused by the configuration of the datastore (i.e. bad credentials or an
+// unknown database), as permanent. All other errors are returned as they are.
+func permanentSQLError(err error) error {
+	var mysqlErr *mysql.MySQLError
+	var pqErr *pq.Error
+	switch {
+	case errors.As(err, &mysqlErr) && (mysqlErr.Number == mysqlErrDBAccessDenied || mysqlErr.Number == mysqlErrAccessDenied || mysqlErr.Number == mysqlErrBadDB):
+	case errors.As(err, &pqErr) && (pqErr.Code.Class() == pqErrClassInvalidAuthorization || pqErr.Code == pqErrInvalidCatalogName):
+	default:
+		return err
+	}
+	return permanentError{cause: err}
+}
+
+// checkHosts pings all hosts with a timeout of 2 seconds each
+func (ds *sqlDatastoreExecutor) checkHosts() error {
+	return ds.pingHosts(context.Background())
+}
+
+// pingHosts pings all hosts, updates their health and returns an error if no host is reachable
+func (ds *sqlDatastoreExecutor) pingHosts(parent context.Context) error {
+	var lastErr error
+	reachable := false
+	for _, host := range ds.hosts {
+		ctx, cancel := context.WithTimeout(parent, 2*time.Second)
+		err := host.dbPool.PingContext(ctx)
+		cancel()
+
+		// The caller gave up, which says nothing about the health of the host
+		if parent.Err() != nil {
+			return parent.Err()
+		}
+		ds.setHealth(host, err)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		reachable = true
+	}
+
+	if !reachable {
+		return lastErr
+	}
+	return nil
+}
+
+// healthCheck pings all hosts in the provided interval
+func (ds *sqlDatastoreExecutor) healthCheck(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ds.closed:
+			return
+		case <-ticker.C:
+			if err := ds.checkHosts(); err != nil {
+				logging.LogForComponent("sqlDatastoreExecutor").Errorf("No host of datastore [%s] is reachable: %s", ds.alias, err)
+			}
+		}
+	}
+}
+
+// setHealth updates the health of the host and logs changes
+func (ds *sqlDatastoreExecutor) setHealth(host *sqlHost, err error) {
+	healthy := err == nil
+	if host.healthy.Swap(healthy) == healthy {
+		return
+	}
+
+	if healthy {
+		logging.LogForComponent("sqlDatastoreExecutor").Infof("Host %s (%s) of datastore [%s] is reachable", host.address, host.role, ds.alias)
+	} else {
+		logging.LogForComponent("sqlDatastoreExecutor").Warnf("Host %s (%s) of datastore [%s] is unreachable: %s", host.address, host.role, ds.alias, err)
+	}
+}
+
+// candidates returns all hosts in the order in which they should be queried.
+// Healthy replicas are preferred (in round-robin) over healthy primaries, unhealthy hosts are only used as last resort.
+func (ds *sqlDatastoreExecutor) candidates() []*sqlHost {
+	var replicas, primaries, unhealthy []*sqlHost
+	for _, host := range ds.hosts {
+		switch {
+		case !host.healthy.Load():
+			unhealthy = append(unhealthy, host)
+		case host.role == configs.HostRoleReplica:
+			replicas = append(replicas, host)
+		default:
+			primaries = append(primaries, host)
+		}
+	}
+
+	if len(replicas) > 1 {
+		offset := int(ds.next.Add(1) % uint64(len(replicas)))
+		replicas = append(replicas[offset:], replicas[:offset]...)
+	}
+
+	result := make([]*sqlHost, 0, len(ds.hosts))
+	result = append(result, replicas...)
+	result = append(result, primaries...)
+	return append(result, unhealthy...)
+}
+
 // Execute -- see data.DatastoreExecutor
 func (ds *sqlDatastoreExecutor) Execute(_ context.Context, query data.DatastoreQuery) (bool, error) {
 	sqlStatement, ok := query.Statement.(string)
@@ -101,8 +311,27 @@ func (ds *sqlDatastoreExecutor) Execute(_ context.Context, query data.DatastoreQ
 		return false, errors.Errorf("Passed statement was not of type string but of type: %T", query.Statement)
 	}
 
+	// Fail over to the next host if a host is not reachable
+	var err error
+	for _, host := range ds.candidates() {
+		var result bool
+		result, err = ds.executeOnHost(host, sqlStatement, query.Parameters)
+		if err == nil {
+			ds.setHealth(host, nil)
+			return result, nil
+		}
+		if !isTransientError(err) {
+			return false, err
+		}
+		ds.setHealth(host, err)
+	}
+	return false, err
+}
+
+// executeOnHost executes the statement against the connection pool of the provided host
+func (ds *sqlDatastoreExecutor) executeOnHost(host *sqlHost, sqlStatement string, params []any) (bool, error) {
 	// execute query against DB
-	rows, err := ds.dbPool.Query(sqlStatement, query.Parameters...)
+	rows, err := host.dbPool.Query(sqlStatement, params...)
 	if err != nil {
 		return false, errors.Wrap(err, "sqlDatastoreExecutor: Error while executing statement")
 	}
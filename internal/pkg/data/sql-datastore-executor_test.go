@@ -0,0 +1,98 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/unbasical/kelon/configs"
+	"github.com/unbasical/kelon/pkg/data"
+)
+
+func makeSQLHost(address, role string, healthy bool) *sqlHost {
+	host := &sqlHost{address: address, role: role}
+	host.healthy.Store(healthy)
+	return host
+}
+
+func addresses(hosts []*sqlHost) []string {
+	result := make([]string, len(hosts))
+	for i, host := range hosts {
+		result[i] = host.address
+	}
+	return result
+}
+
+func Test_sqlDatastoreExecutor_PrefersHealthyReplicas(t *testing.T) {
+	ds := &sqlDatastoreExecutor{hosts: []*sqlHost{
+		makeSQLHost("primary", configs.HostRolePrimary, true),
+		makeSQLHost("replica-down", configs.HostRoleReplica, false),
+		makeSQLHost("replica", configs.HostRoleReplica, true),
+	}}
+
+	assert.Equal(t, []string{"replica", "primary", "replica-down"}, addresses(ds.candidates()))
+}
+
+func Test_sqlDatastoreExecutor_RotatesReplicas(t *testing.T) {
+	ds := &sqlDatastoreExecutor{hosts: []*sqlHost{
+		makeSQLHost("replica-1", configs.HostRoleReplica, true),
+		makeSQLHost("replica-2", configs.HostRoleReplica, true),
+	}}
+
+	first := ds.candidates()[0].address
+	second := ds.candidates()[0].address
+	assert.NotEqual(t, first, second)
+}
+
+func Test_validateHosts(t *testing.T) {
+	assert.NoError(t, validateHosts("pg", []*configs.DatastoreHost{{Host: "localhost", Port: "5432"}}))
+	assert.Error(t, validateHosts("pg", []*configs.DatastoreHost{{Host: "localhost"}}))
+	assert.Error(t, validateHosts("pg", []*configs.DatastoreHost{{Host: "localhost", Port: "5432", Role: "leader"}}))
+}
+
+func Test_permanentSQLError(t *testing.T) {
+	assert.True(t, isPermanentError(permanentSQLError(&mysql.MySQLError{Number: 1045, Message: "Access denied"})))
+	assert.True(t, isPermanentError(permanentSQLError(&mysql.MySQLError{Number: 1049, Message: "Unknown database"})))
+	assert.True(t, isPermanentError(permanentSQLError(errors.Wrap(&pq.Error{Code: "28P01"}, "ping"))))
+	assert.True(t, isPermanentError(permanentSQLError(&pq.Error{Code: "3D000"})))
+
+	assert.False(t, isPermanentError(permanentSQLError(&mysql.MySQLError{Number: 1040, Message: "Too many connections"})))
+	assert.False(t, isPermanentError(permanentSQLError(&pq.Error{Code: "57P03"})))
+	assert.False(t, isPermanentError(permanentSQLError(mysql.ErrInvalidConn)))
+	assert.Nil(t, permanentSQLError(nil))
+}
+
+func Test_sqlDatastoreExecutor_CloseTwice(t *testing.T) {
+	ds := NewSQLDatastoreExecutor()
+
+	// i.e. retired by a reload and closed on shutdown afterwards
+	assert.NoError(t, ds.(data.Closer).Close(context.Background()))
+	assert.NotPanics(t, func() { assert.NoError(t, ds.(data.Closer).Close(context.Background())) })
+}
+
+func Test_sqlDatastoreExecutor_PingWhileClosing(t *testing.T) {
+	ds := NewSQLDatastoreExecutor().(*sqlDatastoreExecutor)
+	for _, address := range []string{"127.0.0.1:1", "127.0.0.1:2"} {
+		db, err := sql.Open(data.TypeMysql, "kelon:secret@tcp("+address+")/appstore")
+		assert.NoError(t, err)
+		ds.hosts = append(ds.hosts, &sqlHost{address: address, role: configs.HostRolePrimary, dbPool: db})
+	}
+
+	// i.e. the health endpoint pings a datastore which is retired by a reload
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 10; i++ {
+			_ = ds.Ping(context.Background())
+		}
+	}()
+	assert.NoError(t, ds.Close(context.Background()))
+	<-done
+
+	// The closed hosts are unreachable
+	assert.Error(t, ds.Ping(context.Background()))
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"sync/atomic"
 
 	"github.com/pkg/errors"
 	"github.com/unbasical/kelon/configs"
@@ -18,7 +19,7 @@ type sqlDatastoreTranslator struct {
 	platform   string
 	conn       map[string]string
 	schemas    map[string]*configs.EntitySchema
-	callOps    callOperands
+	callOps    atomic.Pointer[callOperands]
 	configured bool
 }
 
@@ -27,7 +28,6 @@ func NewSQLDatastoreTranslator() data.DatastoreTranslator {
 	return &sqlDatastoreTranslator{
 		appConf:    nil,
 		alias:      "",
-		callOps:    nil,
 		configured: false,
 	}
 }
@@ -62,7 +62,7 @@ func (ds *sqlDatastoreTranslator) Configure(appConf *configs.AppConfig, alias st
 	if !ok {
 		return errors.Errorf("no call-operands found for datastore with type [%s]", conf.Type)
 	}
-	ds.callOps = operands
+	ds.callOps.Store(&operands)
 	logging.LogForComponent("sqlDatastoreTranslator").Infof("SqlDatastoreTranslator [%s] laoded call operands", alias)
 
 	// Assign values
@@ -76,6 +76,21 @@ func (ds *sqlDatastoreTranslator) Configure(appConf *configs.AppConfig, alias st
 	return nil
 }
 
+// ReloadCallOperands - see data.CallOperandsReloader
+func (ds *sqlDatastoreTranslator) ReloadCallOperands(callOps map[string]map[string]func(args ...string) (string, error)) error {
+	if !ds.configured {
+		return errors.Errorf("SqlDatastoreTranslator: DatastoreTranslator was not configured! Please call Configure(). ")
+	}
+
+	operands, ok := callOps[ds.platform]
+	if !ok {
+		return errors.Errorf("no call-operands found for datastore with type [%s]", ds.platform)
+	}
+	ds.callOps.Store(&operands)
+	logging.LogForComponent("sqlDatastoreTranslator").Infof("[%s] reloaded call operands", ds.alias)
+	return nil
+}
+
 func (ds *sqlDatastoreTranslator) Execute(_ context.Context, query data.Node) (data.DatastoreQuery, error) {
 	if !ds.configured {
 		return data.DatastoreQuery{}, errors.Errorf("SqlDatastoreTranslator: DatastoreTranslator was not configured! Please call Configure(). ")
@@ -84,7 +99,7 @@ func (ds *sqlDatastoreTranslator) Execute(_ context.Context, query data.Node) (d
 
 	// Translate query to into sql statement
 	t := newSqlTranslator()
-	statement, params, err := t.Translate(query, ds.platform, ds.callOps, ds.schemas)
+	statement, params, err := t.Translate(query, ds.platform, *ds.callOps.Load(), ds.schemas)
 	if err != nil {
 		return data.DatastoreQuery{}, errors.Wrapf(err, "SqlDatastoreTranslator: Translate failed for datastore with alias %s - query: %s", ds.alias, query)
 	}
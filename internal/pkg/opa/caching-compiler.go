@@ -0,0 +1,288 @@
+package opa
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/open-policy-agent/opa/v1/plugins"
+	"github.com/open-policy-agent/opa/v1/storage"
+	"github.com/pkg/errors"
+	"github.com/unbasical/kelon/configs"
+	"github.com/unbasical/kelon/internal/pkg/util"
+	"github.com/unbasical/kelon/pkg/audit"
+	"github.com/unbasical/kelon/pkg/constants"
+	"github.com/unbasical/kelon/pkg/constants/logging"
+	"github.com/unbasical/kelon/pkg/data"
+	"github.com/unbasical/kelon/pkg/opa"
+)
+
+type cachingPolicyCompiler struct {
+	compiler   opa.PolicyCompiler
+	configured bool
+	state      atomic.Pointer[decisionCache]
+}
+
+// decisionCache holds the cache together with the key fields and the audit sink it was configured with.
+// Each invalidation starts a new generation, so that decisions which were made before it can't be cached afterwards.
+type decisionCache struct {
+	keyFields  [][]string
+	cache      *util.LRUCache[string, cachedDecision]
+	auditSink  audit.Sink
+	generation atomic.Uint64
+}
+
+// cachedDecision is a decision tagged with the generation of the cache it was made in
+type cachedDecision struct {
+	decision   opa.Decision
+	generation uint64
+}
+
+// NewCachingPolicyCompiler wraps the provided opa.PolicyCompiler with an in-process decision cache.
+// The cache is only active if it is configured via configs.DecisionCache, otherwise all calls are passed through.
+func NewCachingPolicyCompiler(compiler opa.PolicyCompiler) opa.PolicyCompiler {
+	return &cachingPolicyCompiler{
+		compiler:   compiler,
+		configured: false,
+	}
+}
+
+// GetEngine - see GetEngine from opa.PolicyCompiler
+func (compiler *cachingPolicyCompiler) GetEngine() *plugins.Manager {
+	return compiler.compiler.GetEngine()
+}
+
+// Configure - see Configure from opa.PolicyCompiler
+func (compiler *cachingPolicyCompiler) Configure(appConf *configs.AppConfig, compConf *opa.PolicyCompilerConfig) error {
+	// Exit if already configured
+	if compiler.configured {
+		return nil
+	}
+
+	if err := compiler.compiler.Configure(appConf, compConf); err != nil {
+		return err
+	}
+
+	// Each write to OPA's store (data or policies) invalidates all cached decisions
+	if err := compiler.registerInvalidation(context.Background()); err != nil {
+		return errors.Wrap(err, "CachingPolicyCompiler: Unable to register cache invalidation")
+	}
+	compiler.applyConfig(appConf)
+
+	compiler.configured = true
+	return nil
+}
+
+// PolicyRevision - see PolicyRevision from opa.RevisionReporter
+func (compiler *cachingPolicyCompiler) PolicyRevision() string {
+	if reporter, ok := compiler.compiler.(opa.RevisionReporter); ok {
+		return reporter.PolicyRevision()
+	}
+	return ""
+}
+
+// Reload - see Reload from opa.ReloadablePolicyCompiler
+func (compiler *cachingPolicyCompiler) Reload(appConf *configs.AppConfig, compConf *opa.PolicyCompilerConfig) (opa.PendingReload, error) {
+	reloadable, ok := compiler.compiler.(opa.ReloadablePolicyCompiler)
+	if !ok {
+		return nil, errors.Errorf("CachingPolicyCompiler: Wrapped PolicyCompiler %T can not be reloaded", compiler.compiler)
+	}
+	pending, err := reloadable.Reload(appConf, compConf)
+	if err != nil {
+		return nil, err
+	}
+	return &pendingCache{PendingReload: pending, compiler: compiler, appConf: appConf}, nil
+}
+
+// pendingCache replaces the cache once the reload of the wrapped PolicyCompiler is committed
+type pendingCache struct {
+	opa.PendingReload
+	compiler *cachingPolicyCompiler
+	appConf  *configs.AppConfig
+}
+
+// Commit - see Commit from opa.PendingReload
+func (pending *pendingCache) Commit() {
+	pending.PendingReload.Commit()
+
+	// Cached decisions might have been made with the previous mappings or datastores
+	pending.compiler.applyConfig(pending.appConf)
+}
+
+// ReloadCallOperands - see data.CallOperandsReloader
+func (compiler *cachingPolicyCompiler) ReloadCallOperands(callOps map[string]map[string]func(args ...string) (string, error)) error {
+	reloader, ok := compiler.compiler.(data.CallOperandsReloader)
+	if !ok {
+		return errors.Errorf("CachingPolicyCompiler: Wrapped PolicyCompiler %T can not reload call operands", compiler.compiler)
+	}
+	if err := reloader.ReloadCallOperands(callOps); err != nil {
+		return err
+	}
+
+	// Cached decisions might have been made with the previous call operands
+	if state := compiler.state.Load(); state != nil {
+		state.invalidate()
+	}
+	return nil
+}
+
+// applyConfig replaces the cache with an empty one according to the configuration.
+func (compiler *cachingPolicyCompiler) applyConfig(appConf *configs.AppConfig) {
+	cacheConf := appConf.Global.DecisionCache
+	if cacheConf == nil {
+		compiler.state.Store(nil)
+		return
+	}
+
+	state := &decisionCache{
+		cache:     util.NewLRUCache[string, cachedDecision](cacheConf.MaxSize, cacheConf.TTL),
+		auditSink: appConf.AuditSink,
+	}
+	for _, field := range cacheConf.KeyFields {
+		state.keyFields = append(state.keyFields, strings.Split(field, "."))
+	}
+	compiler.state.Store(state)
+	logging.LogForComponent("cachingPolicyCompiler").Infof("Decision cache enabled with ttl %s and max-size %d", cacheConf.TTL, cacheConf.MaxSize)
+}
+
+// Execute - see Execute from opa.PolicyCompiler
+func (compiler *cachingPolicyCompiler) Execute(ctx context.Context, requestBody map[string]any) (*opa.Decision, error) {
+	state := compiler.state.Load()
+	if state == nil {
+		return compiler.compiler.Execute(ctx, requestBody)
+	}
+
+	key, ok := state.cacheKey(requestBody)
+	if !ok {
+		return compiler.compiler.Execute(ctx, requestBody)
+	}
+
+	if decision, hit := state.get(key); hit {
+		logging.LogForComponent("cachingPolicyCompiler").Debugf("Decision cache hit for key %s", key)
+		decision.ID = decisionID(ctx)
+		compiler.recordCachedDecision(ctx, state, requestBody, &decision)
+		return &decision, nil
+	}
+
+	// The generation has to be read before the decision is made, because the store might change during the execution
+	generation := state.generation.Load()
+	decision, err := compiler.compiler.Execute(ctx, requestBody)
+	if err == nil && decision != nil && cacheable(decision) {
+		state.put(key, generation, *decision)
+	}
+	return decision, err
+}
+
+// cacheable reports whether the decision can be served from the cache. Decisions of mappings in shadow mode or with
+// a candidate package are always evaluated, because the shadow denials and candidate mismatches have to be counted and
+// logged for every request and the cached decision would have already been allowed by the shadow mode.
+func cacheable(decision *opa.Decision) bool {
+	return !decision.Shadow && decision.Candidate == nil
+}
+
+// recordCachedDecision writes the decision, which was served from the cache, to OPA's decision log and the audit trail if they are configured
+func (compiler *cachingPolicyCompiler) recordCachedDecision(ctx context.Context, state *decisionCache, requestBody map[string]any, decision *opa.Decision) {
+	decisionLogs := decisionLogger(compiler.GetEngine())
+	if decisionLogs == nil && state.auditSink == nil {
+		return
+	}
+	recordDecision(ctx, decisionLogs, state.auditSink, &decisionLogEntry{
+		id:        decision.ID,
+		timestamp: time.Now(),
+		input:     requestInput(requestBody),
+		revision:  compiler.PolicyRevision(),
+		decision:  decision,
+		metrics:   decisionMetrics(ctx),
+		cached:    true,
+	})
+}
+
+// registerInvalidation clears the cache on every committed write transaction, which includes
+// reloaded regos, updated policies and data written via the data endpoints.
+func (compiler *cachingPolicyCompiler) registerInvalidation(ctx context.Context) error {
+	store := compiler.compiler.GetEngine().Store
+
+	return storage.Txn(ctx, store, storage.WriteParams, func(txn storage.Transaction) error {
+		_, err := store.Register(ctx, txn, storage.TriggerConfig{
+			OnCommit: func(_ context.Context, _ storage.Transaction, event storage.TriggerEvent) {
+				state := compiler.state.Load()
+				if state != nil && (event.PolicyChanged() || event.DataChanged()) {
+					logging.LogForComponent("cachingPolicyCompiler").Debugln("Store changed, clearing decision cache")
+					state.invalidate()
+				}
+			},
+		})
+		return err
+	})
+}
+
+// invalidate drops all cached decisions and starts a new generation
+func (state *decisionCache) invalidate() {
+	state.generation.Add(1)
+	state.cache.Clear()
+}
+
+// get returns the cached decision for the key, if it was made in the current generation
+func (state *decisionCache) get(key string) (opa.Decision, bool) {
+	entry, hit := state.cache.Get(key)
+	if !hit || entry.generation != state.generation.Load() {
+		return opa.Decision{}, false
+	}
+	return entry.decision, true
+}
+
+// put caches the decision, which was made in the given generation. Decisions of previous generations are dropped,
+// because the cache was invalidated while they were made.
+func (state *decisionCache) put(key string, generation uint64, decision opa.Decision) {
+	if generation != state.generation.Load() {
+		return
+	}
+	state.cache.Put(key, cachedDecision{decision: decision, generation: generation})
+}
+
+// cacheKey builds a normalized key out of the request's method, path and the configured key fields.
+// If the input can not be used as key, false is returned.
+func (state *decisionCache) cacheKey(requestBody map[string]any) (string, bool) {
+	input, ok := requestBody[constants.Input].(map[string]any)
+	if !ok {
+		return "", false
+	}
+
+	keyInput := input
+	if len(state.keyFields) > 0 {
+		keyInput = map[string]any{
+			"method": strings.ToUpper(extractString(input, "method")),
+			"path":   input["path"],
+		}
+		for _, field := range state.keyFields {
+			keyInput[strings.Join(field, ".")] = lookupField(input, field)
+		}
+	}
+
+	// Map keys are sorted during marshalling, which normalizes the key
+	key, err := json.Marshal(keyInput)
+	if err != nil {
+		return "", false
+	}
+	return string(key), true
+}
+
+// lookupField returns the value inside the nested objects at the given path or nil if it does not exist
+func lookupField(input map[string]any, path []string) any {
+	var current any = input
+	for _, segment := range path {
+		object, ok := current.(map[string]any)
+		if !ok {
+			return nil
+		}
+		current = object[segment]
+	}
+	return current
+}
+
+func extractString(input map[string]any, key string) string {
+	value, _ := input[key].(string)
+	return value
+}
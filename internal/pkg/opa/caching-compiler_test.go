@@ -0,0 +1,265 @@
+package opa
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/open-policy-agent/opa/v1/plugins"
+	"github.com/open-policy-agent/opa/v1/storage"
+	"github.com/open-policy-agent/opa/v1/storage/inmem"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/unbasical/kelon/configs"
+	"github.com/unbasical/kelon/pkg/opa"
+)
+
+// countingCompiler allows each request and counts the executions. The hook is run during each execution.
+// If shadow or candidate is set, the decisions are made for a mapping in shadow mode or with a candidate package.
+type countingCompiler struct {
+	manager   *plugins.Manager
+	calls     int
+	hook      func()
+	shadow    bool
+	candidate string
+}
+
+func (c *countingCompiler) Configure(_ *configs.AppConfig, _ *opa.PolicyCompilerConfig) error {
+	return nil
+}
+
+func (c *countingCompiler) GetEngine() *plugins.Manager {
+	return c.manager
+}
+
+func (c *countingCompiler) Execute(ctx context.Context, _ map[string]any) (*opa.Decision, error) {
+	c.calls++
+	if c.hook != nil {
+		c.hook()
+	}
+	decision := &opa.Decision{ID: opa.DecisionIDFromContext(ctx), Allow: true, Verify: true, Shadow: c.shadow}
+	if c.candidate != "" {
+		decision.Candidate = &opa.CandidateDecision{Package: c.candidate, Allow: true, Verify: true}
+	}
+	return decision, nil
+}
+
+func newCachingCompiler(t *testing.T, keyFields ...string) (opa.PolicyCompiler, *countingCompiler) {
+	t.Helper()
+	manager, err := plugins.New(nil, "test", inmem.New())
+	assert.NoError(t, err)
+
+	inner := &countingCompiler{manager: manager}
+	compiler := NewCachingPolicyCompiler(inner)
+	appConf := &configs.AppConfig{}
+	appConf.Global.DecisionCache = &configs.DecisionCache{TTL: time.Minute, MaxSize: 10, KeyFields: keyFields}
+	assert.NoError(t, compiler.Configure(appConf, &opa.PolicyCompilerConfig{}))
+	return compiler, inner
+}
+
+func cacheInput(input map[string]any) map[string]any {
+	return map[string]any{"input": input}
+}
+
+func writeData(t *testing.T, manager *plugins.Manager) {
+	t.Helper()
+	assert.NoError(t, storage.WriteOne(context.Background(), manager.Store, storage.AddOp, storage.MustParsePath("/users"), []any{"Arnold"}))
+}
+
+func Test_cacheKey(t *testing.T) {
+	state := &decisionCache{}
+
+	// Without key fields the entire input is used and normalized
+	first, ok := state.cacheKey(cacheInput(map[string]any{"method": "GET", "path": "/apps", "user": "Arnold"}))
+	assert.True(t, ok)
+	second, ok := state.cacheKey(cacheInput(map[string]any{"user": "Arnold", "path": "/apps", "method": "GET"}))
+	assert.True(t, ok)
+	assert.Equal(t, first, second)
+	other, _ := state.cacheKey(cacheInput(map[string]any{"method": "GET", "path": "/apps", "user": "Kevin"}))
+	assert.NotEqual(t, first, other)
+
+	// Requests without nested input can't be cached
+	_, ok = state.cacheKey(map[string]any{"input": "GET /apps"})
+	assert.False(t, ok)
+}
+
+func Test_cacheKey_keyFields(t *testing.T) {
+	state := &decisionCache{keyFields: [][]string{{"user"}, {"jwt", "sub"}}}
+
+	key, ok := state.cacheKey(cacheInput(map[string]any{
+		"method": "get",
+		"path":   "/apps",
+		"user":   "Arnold",
+		"jwt":    map[string]any{"sub": "arnold", "iat": 1},
+		"trace":  "1",
+	}))
+	assert.True(t, ok)
+	assert.JSONEq(t, `{"method": "GET", "path": "/apps", "user": "Arnold", "jwt.sub": "arnold"}`, key)
+
+	// Fields, which are not part of the key, are ignored
+	same, _ := state.cacheKey(cacheInput(map[string]any{
+		"method": "GET",
+		"path":   "/apps",
+		"user":   "Arnold",
+		"jwt":    map[string]any{"sub": "arnold", "iat": 2},
+		"trace":  "2",
+	}))
+	assert.Equal(t, key, same)
+
+	// Missing fields are part of the key as null
+	missing, _ := state.cacheKey(cacheInput(map[string]any{"method": "GET", "path": "/apps", "user": "Arnold"}))
+	assert.JSONEq(t, `{"method": "GET", "path": "/apps", "user": "Arnold", "jwt.sub": null}`, missing)
+}
+
+func Test_cachingPolicyCompiler_Execute(t *testing.T) {
+	compiler, inner := newCachingCompiler(t, "user")
+	ctx := context.Background()
+
+	for _, input := range []map[string]any{
+		{"method": "GET", "path": "/apps", "user": "Arnold", "trace": "1"},
+		{"method": "GET", "path": "/apps", "user": "Arnold", "trace": "2"},
+	} {
+		decision, err := compiler.Execute(opa.WithDecisionID(ctx, input["trace"].(string)), cacheInput(input))
+		assert.NoError(t, err)
+		assert.True(t, decision.Allow)
+		assert.Equal(t, input["trace"], decision.ID)
+	}
+	assert.Equal(t, 1, inner.calls)
+
+	_, err := compiler.Execute(ctx, cacheInput(map[string]any{"method": "GET", "path": "/apps", "user": "Kevin"}))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, inner.calls)
+}
+
+func Test_cachingPolicyCompiler_Execute_Rollout(t *testing.T) {
+	input := cacheInput(map[string]any{"method": "GET", "path": "/rollout/apps"})
+
+	// Decisions in shadow mode are never cached, so that each denial is counted and logged
+	compiler, inner := newCachingCompiler(t)
+	inner.shadow = true
+	for range 3 {
+		_, err := compiler.Execute(context.Background(), input)
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, 3, inner.calls)
+
+	// The same applies to decisions with a candidate package, so that each mismatch is counted and logged
+	compiler, inner = newCachingCompiler(t)
+	inner.candidate = "applications.rollout_next"
+	for range 3 {
+		_, err := compiler.Execute(context.Background(), input)
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, 3, inner.calls)
+}
+
+func Test_cachingPolicyCompiler_invalidateOnDataWrite(t *testing.T) {
+	compiler, inner := newCachingCompiler(t)
+	input := cacheInput(map[string]any{"method": "GET", "path": "/apps"})
+
+	_, _ = compiler.Execute(context.Background(), input)
+	_, _ = compiler.Execute(context.Background(), input)
+	assert.Equal(t, 1, inner.calls)
+
+	writeData(t, inner.manager)
+	_, _ = compiler.Execute(context.Background(), input)
+	assert.Equal(t, 2, inner.calls)
+}
+
+func Test_cachingPolicyCompiler_dropStalePut(t *testing.T) {
+	compiler, inner := newCachingCompiler(t)
+	input := cacheInput(map[string]any{"method": "GET", "path": "/apps"})
+
+	// The data is written while the decision is made, so it must not be cached
+	inner.hook = func() { writeData(t, inner.manager) }
+	_, _ = compiler.Execute(context.Background(), input)
+	inner.hook = nil
+
+	_, _ = compiler.Execute(context.Background(), input)
+	_, _ = compiler.Execute(context.Background(), input)
+	assert.Equal(t, 2, inner.calls)
+}
+
+// reloadingCompiler is a countingCompiler which can be reloaded. It is its own opa.PendingReload.
+type reloadingCompiler struct {
+	countingCompiler
+	err     error
+	reloads int
+	commits int
+	aborts  int
+}
+
+func (c *reloadingCompiler) Reload(_ *configs.AppConfig, _ *opa.PolicyCompilerConfig) (opa.PendingReload, error) {
+	c.reloads++
+	if c.err != nil {
+		return nil, c.err
+	}
+	return c, nil
+}
+
+func (c *reloadingCompiler) Commit() {
+	c.commits++
+}
+
+func (c *reloadingCompiler) Abort() {
+	c.aborts++
+}
+
+// reload reloads the compiler and commits the reload if it succeeded
+func reload(compiler opa.PolicyCompiler, appConf *configs.AppConfig) error {
+	pending, err := compiler.(opa.ReloadablePolicyCompiler).Reload(appConf, &opa.PolicyCompilerConfig{})
+	if err != nil {
+		return err
+	}
+	pending.Commit()
+	return nil
+}
+
+func Test_cachingPolicyCompiler_Reload(t *testing.T) {
+	manager, err := plugins.New(nil, "test", inmem.New())
+	assert.NoError(t, err)
+	inner := &reloadingCompiler{countingCompiler: countingCompiler{manager: manager}}
+	compiler := NewCachingPolicyCompiler(inner)
+
+	appConf := &configs.AppConfig{}
+	appConf.Global.DecisionCache = &configs.DecisionCache{TTL: time.Minute, MaxSize: 10}
+	assert.NoError(t, compiler.Configure(appConf, &opa.PolicyCompilerConfig{}))
+
+	input := cacheInput(map[string]any{"method": "GET", "path": "/apps"})
+	_, _ = compiler.Execute(context.Background(), input)
+	_, _ = compiler.Execute(context.Background(), input)
+	assert.Equal(t, 1, inner.calls)
+
+	// A failed reload keeps the cached decisions
+	inner.err = errors.New("invalid configuration")
+	assert.Error(t, reload(compiler, appConf))
+	_, _ = compiler.Execute(context.Background(), input)
+	assert.Equal(t, 1, inner.calls)
+
+	// An aborted reload keeps them as well
+	inner.err = nil
+	pending, err := compiler.(opa.ReloadablePolicyCompiler).Reload(&configs.AppConfig{}, &opa.PolicyCompilerConfig{})
+	assert.NoError(t, err)
+	pending.Abort()
+	_, _ = compiler.Execute(context.Background(), input)
+	assert.Equal(t, 1, inner.calls)
+	assert.Equal(t, 1, inner.aborts)
+
+	// A committed reload drops them
+	assert.NoError(t, reload(compiler, appConf))
+	_, _ = compiler.Execute(context.Background(), input)
+	assert.Equal(t, 2, inner.calls)
+
+	// The reloaded configuration is applied, i.e. the cache is disabled
+	assert.NoError(t, reload(compiler, &configs.AppConfig{}))
+	_, _ = compiler.Execute(context.Background(), input)
+	_, _ = compiler.Execute(context.Background(), input)
+	assert.Equal(t, 4, inner.calls)
+	assert.Equal(t, 4, inner.reloads)
+	assert.Equal(t, 2, inner.commits)
+}
+
+func Test_cachingPolicyCompiler_Reload_NotReloadable(t *testing.T) {
+	compiler, _ := newCachingCompiler(t)
+	assert.Error(t, reload(compiler, &configs.AppConfig{}))
+}
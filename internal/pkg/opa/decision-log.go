@@ -0,0 +1,221 @@
+package opa
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/open-policy-agent/opa/v1/metrics"
+	"github.com/open-policy-agent/opa/v1/plugins"
+	"github.com/open-policy-agent/opa/v1/plugins/logs"
+	"github.com/open-policy-agent/opa/v1/server"
+	"github.com/open-policy-agent/opa/v1/server/types"
+	"github.com/unbasical/kelon/pkg/audit"
+	"github.com/unbasical/kelon/pkg/constants"
+	"github.com/unbasical/kelon/pkg/constants/logging"
+	"github.com/unbasical/kelon/pkg/data"
+	"github.com/unbasical/kelon/pkg/opa"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// resultRules are the optional rules of a package, which are fully evaluated after the decision was made.
+// Their values are returned to the caller in addition to the decision (see opa.Decision).
+var resultRules = []string{"headers", "reason", "obligations"}
+
+// Timers which are written to the metrics of each decision log entry
+const (
+	timerDecision       = "kelon_decision"
+	timerPartialEval    = "rego_partial_eval"
+	timerEval           = "rego_query_eval"
+	timerDatastoreQuery = "kelon_datastore_query"
+)
+
+// redactedParameter replaces the parameters of datastore queries in the decision logs, unless they are logged explicitly
+const redactedParameter = "[REDACTED]"
+
+// decisionLogEntry collects everything which is written to OPA's decision log for a single decision
+type decisionLogEntry struct {
+	id               string
+	timestamp        time.Time
+	input            any
+	revision         string
+	decision         *opa.Decision
+	queries          *data.QueryRecorder
+	candidateQueries *data.QueryRecorder
+	logParameters    bool
+	metrics          metrics.Metrics
+	cached           bool
+	err              error
+}
+
+// decisionID returns the ID, which the caller provided via opa.WithDecisionID, or a new one.
+// The ID is added to the request's span, so that the decision can be found in the traces.
+func decisionID(ctx context.Context) string {
+	id := opa.DecisionIDFromContext(ctx)
+	if id == "" {
+		id = uuid.New().String()
+	}
+	trace.SpanFromContext(ctx).SetAttributes(attribute.String(constants.LabelDecisionID, id))
+	return id
+}
+
+// decisionMetrics returns the metrics, which the caller provided via opa.WithMetrics, or new ones.
+func decisionMetrics(ctx context.Context) metrics.Metrics {
+	if m := opa.MetricsFromContext(ctx); m != nil {
+		return m
+	}
+	return metrics.New()
+}
+
+// recordDecision writes the decision to OPA's decision log and the audit trail, if they are configured
+func recordDecision(ctx context.Context, decisionLogs *logs.Plugin, sink audit.Sink, entry *decisionLogEntry) {
+	if decisionLogs != nil {
+		logDecision(ctx, decisionLogs, entry)
+	}
+	if sink != nil {
+		if err := sink.Write(auditRecord(entry)); err != nil {
+			logging.LogForComponent("auditLog").Errorf("Unable to write decision %s to the audit log: %s", entry.id, err)
+		}
+	}
+}
+
+// auditRecord converts the entry into a record of the audit trail
+func auditRecord(entry *decisionLogEntry) audit.Record {
+	record := audit.Record{
+		Timestamp:  entry.timestamp,
+		DecisionID: entry.id,
+		Decision:   audit.DecisionError,
+		Revision:   entry.revision,
+		DurationMs: float64(entry.metrics.Timer(timerDecision).Int64()) / float64(time.Millisecond),
+		Cached:     entry.cached,
+		Input:      entry.input,
+	}
+
+	if entry.decision != nil {
+		record.Package = entry.decision.Package
+		record.Method = entry.decision.Method
+		record.Path = entry.decision.Path
+		switch {
+		case entry.decision.Allow:
+			record.Decision = audit.DecisionAllow
+		case !entry.decision.Verify:
+			record.Decision = audit.DecisionDeny
+			record.Reason = "Unauthenticated"
+		default:
+			record.Decision = audit.DecisionDeny
+			record.Reason = "Unauthorized"
+		}
+	}
+	if entry.err != nil {
+		record.Error = entry.err.Error()
+	}
+	return record
+}
+
+// decisionLogger returns OPA's decision log plugin, if decision logs are configured via 'decision_logs'
+func decisionLogger(manager *plugins.Manager) *logs.Plugin {
+	if manager == nil {
+		return nil
+	}
+	return logs.Lookup(manager)
+}
+
+// logDecision writes the entry to OPA's decision log in OPA's event schema. The Kelon specific fields (i.e. the
+// translated datastore queries) are part of the result, so that existing log pipelines can process the entries.
+func logDecision(ctx context.Context, plugin *logs.Plugin, entry *decisionLogEntry) {
+	info := &server.Info{
+		DecisionID: entry.id,
+		Revision:   entry.revision,
+		Timestamp:  entry.timestamp,
+		Metrics:    entry.metrics,
+	}
+	if entry.input != nil {
+		info.Input = &entry.input
+	}
+
+	if entry.decision != nil {
+		info.Path = strings.ReplaceAll(entry.decision.Package, ".", "/")
+		results := entry.decision.Results()
+		results["allow"] = entry.decision.Allow
+		results["verify"] = entry.decision.Verify
+		results["package"] = entry.decision.Package
+		results["method"] = entry.decision.Method
+		results["path"] = entry.decision.Path
+		results["cached"] = entry.cached
+		results["queries"] = recordedQueries(entry.queries, entry.logParameters)
+		if entry.decision.Shadow {
+			results["shadow"] = true
+		}
+		if candidate := entry.decision.Candidate; candidate != nil {
+			results["candidate"] = candidateResult(candidate, recordedQueries(entry.candidateQueries, entry.logParameters))
+		}
+		result := any(results)
+		info.Results = &result
+	}
+	if entry.err != nil {
+		info.Error = types.NewErrorV1(types.CodeInternal, "%s", entry.err.Error())
+	}
+
+	if err := plugin.Log(ctx, info); err != nil {
+		logging.LogForComponent("decisionLog").Warnf("Unable to log decision %s: %s", entry.id, err)
+	}
+}
+
+// candidateResult returns the decision of the candidate package, whether it disagrees with the live decision and
+// the datastore queries of the candidate
+func candidateResult(candidate *opa.CandidateDecision, queries []any) map[string]any {
+	result := map[string]any{
+		"package":  candidate.Package,
+		"verify":   candidate.Verify,
+		"allow":    candidate.Allow,
+		"mismatch": candidate.Mismatch,
+		"queries":  queries,
+	}
+	if candidate.Error != nil {
+		result["error"] = candidate.Error.Error()
+	}
+	return result
+}
+
+// recordedQueries converts the recorded queries to a JSON compatible list, which is never nil.
+// Unless logParameters is set, the parameters of the queries are redacted.
+func recordedQueries(recorder *data.QueryRecorder, logParameters bool) []any {
+	result := []any{}
+	if recorder == nil {
+		return result
+	}
+	for _, query := range recorder.Queries() {
+		recorded := map[string]any{
+			"datastore":   query.Datastore,
+			"statement":   query.Statement,
+			"duration_ns": query.Duration.Nanoseconds(),
+		}
+		if len(query.Parameters) > 0 {
+			recorded["parameters"] = queryParameters(query.Parameters, logParameters)
+		}
+		if query.Error != "" {
+			recorded["error"] = query.Error
+		}
+		result = append(result, recorded)
+	}
+	return result
+}
+
+// queryParameters returns the parameters or, unless logParameters is set, a redacted placeholder for each of them
+func queryParameters(parameters []any, logParameters bool) []any {
+	if logParameters {
+		return parameters
+	}
+	redacted := make([]any, len(parameters))
+	for i := range redacted {
+		redacted[i] = redactedParameter
+	}
+	return redacted
+}
+
+// requestInput returns the input of the request body, which is logged as the decision's input
+func requestInput(requestBody map[string]any) any {
+	return requestBody[constants.Input]
+}
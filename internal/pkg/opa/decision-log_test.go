@@ -0,0 +1,50 @@
+package opa
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/unbasical/kelon/pkg/data"
+	"github.com/unbasical/kelon/pkg/opa"
+)
+
+func Test_recordedQueries(t *testing.T) {
+	recorder := &data.QueryRecorder{}
+	ctx := data.WithQueryRecorder(context.Background(), recorder)
+	data.RecordQuery(ctx, "mysql", data.DatastoreQuery{
+		Statement:  "SELECT count(*) FROM users WHERE name = ? AND password = ?",
+		Parameters: []any{"Arnold", "pw_arnold"},
+	}, time.Millisecond, nil)
+	data.RecordQuery(ctx, "mysql", data.DatastoreQuery{Statement: "SELECT count(*) FROM apps"}, time.Millisecond, nil)
+
+	// The parameters are redacted by default
+	queries := recordedQueries(recorder, false)
+	if assert.Len(t, queries, 2) {
+		assert.Equal(t, []any{redactedParameter, redactedParameter}, queries[0].(map[string]any)["parameters"])
+		assert.NotContains(t, queries[1], "parameters")
+	}
+
+	queries = recordedQueries(recorder, true)
+	if assert.Len(t, queries, 2) {
+		assert.Equal(t, []any{"Arnold", "pw_arnold"}, queries[0].(map[string]any)["parameters"])
+	}
+
+	assert.Equal(t, []any{}, recordedQueries(nil, false))
+}
+
+func Test_candidateResult(t *testing.T) {
+	candidate := &opa.CandidateDecision{Package: "applications.rollout_next", Verify: true, Error: errors.New("undefined rule")}
+	queries := []any{map[string]any{"datastore": "mysql", "statement": "SELECT count(*) FROM apps"}}
+
+	assert.Equal(t, map[string]any{
+		"package":  "applications.rollout_next",
+		"verify":   true,
+		"allow":    false,
+		"mismatch": false,
+		"queries":  queries,
+		"error":    "undefined rule",
+	}, candidateResult(candidate, queries))
+}
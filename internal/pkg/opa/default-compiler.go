@@ -4,8 +4,13 @@ import (
 	"context"
 	"fmt"
 	"net/url"
+	"path/filepath"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/open-policy-agent/opa/v1/metrics"
 	"github.com/open-policy-agent/opa/v1/plugins"
 	"github.com/open-policy-agent/opa/v1/rego"
 	"github.com/pkg/errors"
@@ -14,24 +19,44 @@ import (
 	requestInt "github.com/unbasical/kelon/internal/pkg/request"
 	"github.com/unbasical/kelon/pkg/constants"
 	"github.com/unbasical/kelon/pkg/constants/logging"
+	"github.com/unbasical/kelon/pkg/data"
 	internalErrors "github.com/unbasical/kelon/pkg/errors"
 	"github.com/unbasical/kelon/pkg/opa"
 	"github.com/unbasical/kelon/pkg/request"
 	"github.com/unbasical/kelon/pkg/watcher"
 )
 
+const (
+	// reloadReadyTimeout is the maximum time a reload waits for the new datastores to connect
+	reloadReadyTimeout = 30 * time.Second
+	// retireTimeout is the maximum time the datastores of a replaced configuration get to close
+	retireTimeout = 30 * time.Second
+)
+
 type policyCompiler struct {
-	configured bool
-	appConfig  *configs.AppConfig
-	config     *opa.PolicyCompilerConfig
-	engine     *OPA
+	configured   bool
+	generation   atomic.Pointer[compilerGeneration]
+	engine       *OPA
+	revision     string
+	revMutex     sync.Mutex
+	readyTimeout time.Duration
+}
+
+// compilerGeneration is one loaded version of the configuration.
+// Each request holds a read lock on the generation it started with, so that a replaced generation
+// is only retired after all of its requests have finished.
+type compilerGeneration struct {
+	mutex     sync.RWMutex
+	retired   bool
+	appConfig *configs.AppConfig
+	config    *opa.PolicyCompilerConfig
 }
 
 // NewPolicyCompiler returns a new instance of the default implementation of the opa.PolicyCompiler.
 func NewPolicyCompiler() opa.PolicyCompiler {
 	return &policyCompiler{
-		configured: false,
-		config:     nil,
+		configured:   false,
+		readyTimeout: reloadReadyTimeout,
 	}
 }
 
@@ -52,29 +77,185 @@ func (compiler *policyCompiler) Configure(appConf *configs.AppConfig, compConf *
 	}
 
 	// Start OPA in background
-	engine, err := startOPA(compConf.OPAConfig, *compConf.RegoDir)
+	engine, err := startOPA(compConf, compiler.onActivation)
 	if err != nil {
 		return errors.Wrap(err, "PolicyCompiler: Error while starting OPA.")
 	}
 
-	// Register watcher for rego changes
-	(*compConf.ConfigWatcher).Watch(func(changeType watcher.ChangeType, _ *configs.ExternalConfig, _ error) {
-		if changeType == watcher.ChangeRego {
-			if err := engine.LoadRegosFromPath(context.Background(), *compConf.RegoDir); err != nil {
-				logging.LogForComponent("policyCompiler").Error("Unable to reload regos on file change due to: ", err)
+	// Assign variables
+	compiler.engine = engine
+	compiler.generation.Store(&compilerGeneration{appConfig: appConf, config: compConf})
+	compiler.reportRevision(engine.Revision())
+
+	// Register watcher for rego and bundle changes
+	(*compConf.ConfigWatcher).Watch(func(changeType watcher.ChangeType, paths []string, _ *configs.ExternalConfig, _ error) {
+		if changeType.Has(watcher.ChangeRego) || bundleChanged(compConf.BundlePath, paths) {
+			logging.LogForComponent("policyCompiler").Infof("Reloading policies due to changed files %q", paths)
+			if err := engine.ReloadPolicies(context.Background()); err != nil {
+				logging.LogForComponent("policyCompiler").Errorf("Unable to reload policies on file change, keeping revision %q due to: %s", engine.Revision(), err)
+				return
 			}
+			compiler.reportRevision(engine.Revision())
 		}
 	})
 
-	// Assign variables
-	compiler.engine = engine
-	compiler.appConfig = appConf
-	compiler.config = compConf
 	compiler.configured = true
 	logging.LogForComponent("policyCompiler").Infoln("Configured PolicyCompiler")
 	return nil
 }
 
+// PolicyRevision - see PolicyRevision from opa.RevisionReporter
+func (compiler *policyCompiler) PolicyRevision() string {
+	if compiler.engine == nil {
+		return ""
+	}
+	return compiler.engine.Revision()
+}
+
+// onActivation is called by OPA once remote bundles were downloaded and activated.
+// The decision cache is already cleared by the committed store transaction.
+func (compiler *policyCompiler) onActivation(revision string) {
+	logging.LogForComponent("policyCompiler").Infof("Remote bundles were activated with revision %q", revision)
+	compiler.reportRevision(revision)
+}
+
+// reportRevision moves the revision metric to the revision of the active regos
+func (compiler *policyCompiler) reportRevision(revision string) {
+	compiler.revMutex.Lock()
+	defer compiler.revMutex.Unlock()
+
+	gen := compiler.generation.Load()
+	if gen == nil || revision == compiler.revision {
+		// Nothing to report before the configuration is stored or if the revision did not change
+		return
+	}
+
+	if provider := gen.appConfig.MetricsProvider; provider != nil {
+		ctx := context.Background()
+		if compiler.revision != "" {
+			provider.UpdateGaugeMetric(ctx, constants.InstrumentPolicyRevision, int64(-1), map[string]string{constants.LabelPolicyRevision: compiler.revision})
+		}
+		provider.UpdateGaugeMetric(ctx, constants.InstrumentPolicyRevision, int64(1), map[string]string{constants.LabelPolicyRevision: revision})
+	}
+	compiler.revision = revision
+}
+
+// Reload - see Reload from opa.ReloadablePolicyCompiler
+func (compiler *policyCompiler) Reload(appConf *configs.AppConfig, compConf *opa.PolicyCompilerConfig) (opa.PendingReload, error) {
+	if !compiler.configured {
+		return nil, errors.Errorf("PolicyCompiler was not configured! Please call Configure(). ")
+	}
+
+	if e := initDependencies(compConf, appConf); e != nil {
+		closeDatastores(compConf.Datastores)
+		return nil, errors.Wrap(e, "PolicyCompiler: Error while initializing dependencies.")
+	}
+	if e := awaitDatastores(compConf.Datastores, compiler.readyTimeout); e != nil {
+		closeDatastores(compConf.Datastores)
+		return nil, errors.Wrap(e, "PolicyCompiler: Error while connecting datastores.")
+	}
+
+	return &pendingGeneration{compiler: compiler, gen: &compilerGeneration{appConfig: appConf, config: compConf}}, nil
+}
+
+// pendingGeneration is a generation whose datastores are connected, but which is not yet swapped in
+type pendingGeneration struct {
+	compiler *policyCompiler
+	gen      *compilerGeneration
+}
+
+// Commit - see Commit from opa.PendingReload
+func (pending *pendingGeneration) Commit() {
+	previous := pending.compiler.generation.Swap(pending.gen)
+	go retire(previous)
+	logging.LogForComponent("policyCompiler").Infoln("Reloaded PolicyCompiler")
+}
+
+// Abort - see Abort from opa.PendingReload
+func (pending *pendingGeneration) Abort() {
+	closeDatastores(pending.gen.config.Datastores)
+}
+
+// ReloadCallOperands - see data.CallOperandsReloader
+func (compiler *policyCompiler) ReloadCallOperands(callOps map[string]map[string]func(args ...string) (string, error)) error {
+	if !compiler.configured {
+		return errors.Errorf("PolicyCompiler was not configured! Please call Configure(). ")
+	}
+
+	gen := compiler.acquire()
+	defer gen.mutex.RUnlock()
+
+	reloader, ok := (*gen.config.Translator).(data.CallOperandsReloader)
+	if !ok {
+		return errors.Errorf("PolicyCompiler: Translator %T can not reload call operands", *gen.config.Translator)
+	}
+	return reloader.ReloadCallOperands(callOps)
+}
+
+// acquire returns the current generation, which has to be released with RUnlock after the request finished
+func (compiler *policyCompiler) acquire() *compilerGeneration {
+	for {
+		gen := compiler.generation.Load()
+		gen.mutex.RLock()
+		if !gen.retired {
+			return gen
+		}
+		// The generation was replaced in the meantime
+		gen.mutex.RUnlock()
+	}
+}
+
+// retire waits until all requests of the generation have finished and closes its datastores afterwards
+func retire(gen *compilerGeneration) {
+	gen.mutex.Lock()
+	gen.retired = true
+	gen.mutex.Unlock()
+
+	closeDatastores(gen.config.Datastores)
+}
+
+// awaitDatastores waits until all datastores, which report their readiness, are connected.
+// If any of them is not ready before the timeout passed, an error containing its last connection error is returned.
+func awaitDatastores(datastores map[string]*data.Datastore, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for alias, ds := range datastores {
+		reporter, ok := (*ds).(data.ReadinessReporter)
+		if !ok {
+			continue
+		}
+		for {
+			ready, err := reporter.Ready()
+			if ready {
+				break
+			}
+			if time.Now().After(deadline) {
+				if err == nil {
+					err = errors.Errorf("timeout of %s exceeded", timeout)
+				}
+				return errors.Wrapf(err, "Datastore [%s] is not ready", alias)
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+	}
+	return nil
+}
+
+// closeDatastores closes all datastores which hold connections
+func closeDatastores(datastores map[string]*data.Datastore) {
+	ctx, cancel := context.WithTimeout(context.Background(), retireTimeout)
+	defer cancel()
+
+	for alias, ds := range datastores {
+		closer, ok := (*ds).(data.Closer)
+		if !ok {
+			continue
+		}
+		if err := closer.Close(ctx); err != nil {
+			logging.LogForComponent("policyCompiler").Warnf("Unable to close datastore [%s]: %s", alias, err)
+		}
+	}
+}
+
 // Execute expects a map with the following structure:
 //
 // - input
@@ -86,6 +267,47 @@ func (compiler *policyCompiler) Execute(ctx context.Context, requestBody map[str
 		return nil, errors.Errorf("PolicyCompiler was not configured! Please call Configure(). ")
 	}
 
+	entry := &decisionLogEntry{
+		id:        decisionID(ctx),
+		timestamp: time.Now(),
+		input:     requestInput(requestBody),
+		metrics:   decisionMetrics(ctx),
+	}
+	ctx = opa.WithDecisionID(ctx, entry.id)
+	decisionLogs := decisionLogger(compiler.engine.manager)
+	if decisionLogs != nil {
+		entry.queries = &data.QueryRecorder{}
+		entry.candidateQueries = &data.QueryRecorder{}
+		ctx = data.WithQueryRecorder(ctx, entry.queries)
+	}
+
+	entry.metrics.Timer(timerDecision).Start()
+	decision, err := compiler.decide(ctx, requestBody, entry.metrics, entry.candidateQueries)
+	entry.metrics.Timer(timerDecision).Stop()
+	if decision != nil {
+		decision.ID = entry.id
+	}
+
+	gen := compiler.generation.Load()
+	auditSink := gen.appConfig.AuditSink
+	if decisionLogs != nil || auditSink != nil {
+		entry.logParameters = gen.config.LogQueryParameters
+		entry.revision = compiler.PolicyRevision()
+		entry.decision = decision
+		entry.err = err
+		recordDecision(ctx, decisionLogs, auditSink, entry)
+	}
+
+	// The decision logs contain the evaluated decision, even if the request is allowed in shadow mode
+	if decision != nil && decision.Shadow {
+		err = compiler.allowShadowed(ctx, decision, err)
+	}
+	return decision, err
+}
+
+// decide makes the decision for the request body. The durations of the partial evaluation and the datastore queries are
+// recorded in the metrics, the datastore queries of a candidate package in candidateQueries.
+func (compiler *policyCompiler) decide(ctx context.Context, requestBody map[string]any, m metrics.Metrics, candidateQueries *data.QueryRecorder) (*opa.Decision, error) {
 	// Extract input
 	for rootKey := range requestBody {
 		if rootKey != "input" {
@@ -104,8 +326,12 @@ func (compiler *policyCompiler) Execute(ctx context.Context, requestBody map[str
 	}
 	logging.LogForComponent("policyCompiler").Debugf("Received input: %+v", input)
 
+	// Finish the request with the configuration it started with, even if it is reloaded in the meantime
+	gen := compiler.acquire()
+	defer gen.mutex.RUnlock()
+
 	// Process path
-	output, err := compiler.processPath(input)
+	output, err := compiler.processPath(gen.config, input)
 	if err != nil {
 		return nil, err
 	}
@@ -120,31 +346,125 @@ func (compiler *policyCompiler) Execute(ctx context.Context, requestBody map[str
 		return nil, err
 	}
 
+	decision := &opa.Decision{Package: output.Package, Method: method, Path: path.String(), Revision: compiler.PolicyRevision(), Shadow: output.Shadow}
+
 	// Authentication
-	verify, err := compiler.authenticate(ctx, input, output)
-	if err != nil || !verify {
-		return &opa.Decision{Verify: verify, Allow: false, Package: output.Package, Method: method, Path: path.String()}, err
+	decision.Verify, err = compiler.authenticate(ctx, gen.config, input, output, m)
+	if err != nil {
+		return decision, err
 	}
 
 	// Authorization
-	allow, err := compiler.authorize(ctx, input, output)
-	return &opa.Decision{Verify: verify, Allow: allow, Package: output.Package, Method: method, Path: path.String()}, err
+	if decision.Verify {
+		decision.Allow, err = compiler.authorize(ctx, gen.config, input, output, m)
+		if err != nil {
+			return decision, err
+		}
+	}
+
+	// The candidate package is evaluated side by side, but never changes the decision
+	if output.CandidatePackage != "" {
+		compiler.evalCandidate(ctx, gen.config, input, output, decision, candidateQueries)
+	}
+
+	// Additional results are returned for allowed and denied requests
+	m.Timer(timerEval).Start()
+	defer m.Timer(timerEval).Stop()
+	compiler.evalResults(ctx, input, output, decision)
+	return decision, nil
 }
 
-func (compiler *policyCompiler) authenticate(ctx context.Context, input map[string]any, output *request.PathProcessorOutput) (bool, error) {
+func (compiler *policyCompiler) authenticate(ctx context.Context, config *opa.PolicyCompilerConfig, input map[string]any, output *request.PathProcessorOutput, m metrics.Metrics) (bool, error) {
 	if output.Authentication {
-		return compiler.evalFunction(ctx, "verify", input, output)
+		return compiler.evalFunction(ctx, config, output.Rules.Verify, input, output, m)
 	}
 	return true, nil
 }
 
-func (compiler *policyCompiler) authorize(ctx context.Context, input map[string]any, output *request.PathProcessorOutput) (bool, error) {
-	if output.Authorization {
-		return compiler.evalFunction(ctx, "allow", input, output)
+func (compiler *policyCompiler) authorize(ctx context.Context, config *opa.PolicyCompilerConfig, input map[string]any, output *request.PathProcessorOutput, m metrics.Metrics) (bool, error) {
+	if !output.Authorization {
+		return true, nil
+	}
+
+	if output.Rules.Allow != "" {
+		allow, err := compiler.evalFunction(ctx, config, output.Rules.Allow, input, output, m)
+		if err != nil || !allow {
+			return false, err
+		}
+	}
+	for _, rule := range output.Rules.Deny {
+		deny, err := compiler.evalFunction(ctx, config, rule, input, output, m)
+		if err != nil || deny {
+			return false, err
+		}
 	}
 	return true, nil
 }
 
+// evalResults fully evaluates the optional result rules, which are defined in the package, and adds their values to the decision.
+// The results never change the decision, so a rule which can't be evaluated is logged and its result is dropped.
+func (compiler *policyCompiler) evalResults(ctx context.Context, input map[string]any, output *request.PathProcessorOutput, decision *opa.Decision) {
+	opaInput := extractOpaInput(output, input)
+	for _, rule := range resultRules {
+		if !compiler.engine.HasRule(output.Package, rule) {
+			continue
+		}
+
+		value, defined, err := compiler.evalResult(ctx, opaInput, output.Package, rule)
+		if err == nil && defined {
+			err = applyResult(decision, rule, value)
+		}
+		if err != nil {
+			logging.LogForComponent("policyCompiler").WithField(logging.LabelDecisionID, opa.DecisionIDFromContext(ctx)).
+				Warnf("Dropping result of rule '%s' in package %s: %s", rule, output.Package, err)
+		}
+	}
+}
+
+// evalResult fully evaluates the rule in the package. If the rule is undefined for the input, defined is false.
+func (compiler *policyCompiler) evalResult(ctx context.Context, input any, pkg, rule string) (value any, defined bool, err error) {
+	query := fmt.Sprintf("data.%s.%s", pkg, rule)
+	logging.LogForComponent("policyCompiler").Debugf("Evaluating result with query=%s", query)
+
+	resultSet, err := compiler.engine.Evaluate(ctx, input, query)
+	if err != nil {
+		return nil, false, errors.Wrap(err, "PolicyCompiler: Unable to evaluate result")
+	}
+	if len(resultSet) == 0 || len(resultSet[0].Expressions) == 0 {
+		return nil, false, nil
+	}
+	return resultSet[0].Expressions[0].Value, true, nil
+}
+
+// applyResult validates the value of the result rule and sets it on the decision
+func applyResult(decision *opa.Decision, rule string, value any) error {
+	switch rule {
+	case "headers":
+		raw, ok := value.(map[string]any)
+		if !ok {
+			return errors.Errorf("PolicyCompiler: Rule 'headers' of package %s has to be an object, but was %T", decision.Package, value)
+		}
+		headers := make(map[string]string, len(raw))
+		for name, header := range raw {
+			str, ok := header.(string)
+			if !ok {
+				return errors.Errorf("PolicyCompiler: Header %q of package %s has to be a string, but was %T", name, decision.Package, header)
+			}
+			headers[name] = str
+		}
+		decision.Headers = headers
+	case "reason":
+		reason, ok := value.(string)
+		if !ok {
+			return errors.Errorf("PolicyCompiler: Rule 'reason' of package %s has to be a string, but was %T", decision.Package, value)
+		}
+		decision.Reason = reason
+	case "obligations":
+		decision.Obligations = value
+	}
+	return nil
+}
+
 func anyQuerySucceeded(queries *rego.PartialQueries) bool {
 	// If there are no queries, we are done
 	if len(queries.Queries) == 0 {
@@ -160,7 +480,7 @@ func anyQuerySucceeded(queries *rego.PartialQueries) bool {
 	return false
 }
 
-func (compiler *policyCompiler) processPath(input map[string]any) (*request.PathProcessorOutput, error) {
+func (compiler *policyCompiler) processPath(config *opa.PolicyCompilerConfig, input map[string]any) (*request.PathProcessorOutput, error) {
 	inputURL, err := extractURLFromRequestBody(input)
 	if err != nil {
 		return nil, err
@@ -170,7 +490,7 @@ func (compiler *policyCompiler) processPath(input map[string]any) (*request.Path
 		return nil, err
 	}
 
-	output, err := (*compiler.config.PathProcessor).Process(&requestInt.URLProcessorInput{
+	output, err := (*config.PathProcessor).Process(&requestInt.URLProcessorInput{
 		Method: method,
 		URL:    inputURL,
 	})
@@ -181,9 +501,11 @@ func (compiler *policyCompiler) processPath(input map[string]any) (*request.Path
 	return output, nil
 }
 
-func (compiler *policyCompiler) evalFunction(ctx context.Context, function string, input map[string]any, output *request.PathProcessorOutput) (bool, error) {
+func (compiler *policyCompiler) evalFunction(ctx context.Context, config *opa.PolicyCompilerConfig, function string, input map[string]any, output *request.PathProcessorOutput, m metrics.Metrics) (bool, error) {
 	// Compile mapped path
+	m.Timer(timerPartialEval).Start()
 	queries, err := compiler.opaCompile(ctx, input, function, output)
+	m.Timer(timerPartialEval).Stop()
 	if err != nil {
 		return false, err
 	}
@@ -198,14 +520,18 @@ func (compiler *policyCompiler) evalFunction(ctx context.Context, function strin
 	}
 
 	// Otherwise translate ast
-	return (*compiler.config.Translator).Process(context.WithValue(ctx, constants.ContextKeyRegoPackage, output.Package), queries, output.Datastores)
+	ctx = context.WithValue(ctx, constants.ContextKeyRegoPackage, output.Package)
+	ctx = context.WithValue(ctx, constants.ContextKeyRegoRule, function)
+	m.Timer(timerDatastoreQuery).Start()
+	defer m.Timer(timerDatastoreQuery).Stop()
+	return (*config.Translator).Process(ctx, queries, output.Datastores)
 }
 
 func (compiler *policyCompiler) opaCompile(ctx context.Context, input map[string]any, function string, output *request.PathProcessorOutput) (*rego.PartialQueries, error) {
 	// Extract parameters for partial evaluation
 	opts := compiler.extractOpaOpts(output)
 	extractedInput := extractOpaInput(output, input)
-	query := fmt.Sprintf("data.%s.%s == true", output.Package, function)
+	query := compiler.engine.RuleQuery(output.Package, function)
 	logging.LogForComponent("policyCompiler").Debugf("Sending query=%s", query)
 
 	// Compile clientRequest and return answer
@@ -300,12 +626,18 @@ func initDependencies(compConf *opa.PolicyCompilerConfig, appConf *configs.AppCo
 	return translator.Configure(appConf, &compConf.AstTranslatorConfig)
 }
 
-func startOPA(conf any, regosPath string) (*OPA, error) {
+func startOPA(compConf *opa.PolicyCompilerConfig, onActivation func(revision string)) (*OPA, error) {
+	opts := []func(*OPA) error{ConfigOPA(compConf.OPAConfig)}
+	if bundlePath := stringOrEmpty(compConf.BundlePath); bundlePath != "" {
+		opts = append(opts, BundleOPA(bundlePath, compConf.BundleVerificationKeyID, compConf.BundleVerificationScope))
+	}
+
 	ctx := context.Background()
-	engine, err := NewOPA(ctx, regosPath, ConfigOPA(conf))
+	engine, err := NewOPA(ctx, stringOrEmpty(compConf.RegoDir), opts...)
 	if err != nil {
 		return nil, errors.Wrap(err, "Failed to initialize OPA!")
 	}
+	engine.OnActivation(onActivation)
 
 	if err := engine.Start(ctx); err != nil {
 		return nil, errors.Wrap(err, "Failed to start OPA!")
@@ -313,3 +645,24 @@ func startOPA(conf any, regosPath string) (*OPA, error) {
 
 	return engine, nil
 }
+
+// bundleChanged returns true if any of the changed paths belongs to the bundle file or directory
+func bundleChanged(bundlePath *string, paths []string) bool {
+	if stringOrEmpty(bundlePath) == "" {
+		return false
+	}
+	for _, path := range paths {
+		rel, err := filepath.Rel(*bundlePath, path)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+func stringOrEmpty(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
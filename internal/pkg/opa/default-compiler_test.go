@@ -0,0 +1,139 @@
+package opa
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/unbasical/kelon/configs"
+	requestInt "github.com/unbasical/kelon/internal/pkg/request"
+	translateInt "github.com/unbasical/kelon/internal/pkg/translate"
+	"github.com/unbasical/kelon/pkg/data"
+	"github.com/unbasical/kelon/pkg/opa"
+	"github.com/unbasical/kelon/pkg/request"
+	"github.com/unbasical/kelon/pkg/translate"
+)
+
+// trackingDatastore reports the configured readiness and records whether it was closed
+type trackingDatastore struct {
+	ready  bool
+	closed atomic.Bool
+}
+
+func (ds *trackingDatastore) Configure(_ *configs.AppConfig, _ string) error {
+	return nil
+}
+
+func (ds *trackingDatastore) Execute(_ context.Context, _ data.Node) (bool, error) {
+	return true, nil
+}
+
+func (ds *trackingDatastore) Ready() (bool, error) {
+	return ds.ready, nil
+}
+
+func (ds *trackingDatastore) Close(_ context.Context) error {
+	ds.closed.Store(true)
+	return nil
+}
+
+func compilerConfig(ds data.Datastore) (*configs.AppConfig, *opa.PolicyCompilerConfig) {
+	var (
+		processor  = requestInt.NewURLProcessor()
+		mapper     = requestInt.NewPathMapper()
+		translator = translateInt.NewAstTranslator()
+	)
+	return &configs.AppConfig{}, &opa.PolicyCompilerConfig{
+		PathProcessor:       &processor,
+		PathProcessorConfig: request.PathProcessorConfig{PathMapper: &mapper},
+		Translator:          &translator,
+		AstTranslatorConfig: translate.AstTranslatorConfig{Datastores: map[string]*data.Datastore{"mysql": &ds}},
+	}
+}
+
+func newReloadableCompiler(ds data.Datastore) *policyCompiler {
+	compiler := &policyCompiler{configured: true, readyTimeout: 200 * time.Millisecond}
+	appConf, compConf := compilerConfig(ds)
+	compiler.generation.Store(&compilerGeneration{appConfig: appConf, config: compConf})
+	return compiler
+}
+
+func Test_policyCompiler_Reload(t *testing.T) {
+	previous, next := &trackingDatastore{ready: true}, &trackingDatastore{ready: true}
+	compiler := newReloadableCompiler(previous)
+
+	current := compiler.generation.Load()
+	appConf, compConf := compilerConfig(next)
+	pending, err := compiler.Reload(appConf, compConf)
+	assert.NoError(t, err)
+
+	// The previous generation stays active until the reload is committed
+	assert.Same(t, current, compiler.generation.Load())
+	pending.Commit()
+	assert.Same(t, compConf, compiler.generation.Load().config)
+
+	// The replaced generation is retired in the background
+	assert.Eventually(t, previous.closed.Load, time.Second, 10*time.Millisecond)
+	assert.False(t, next.closed.Load())
+}
+
+func Test_policyCompiler_Reload_Abort(t *testing.T) {
+	previous, next := &trackingDatastore{ready: true}, &trackingDatastore{ready: true}
+	compiler := newReloadableCompiler(previous)
+	current := compiler.generation.Load()
+
+	// The previous generation stays active and the datastores of the new configuration are released
+	appConf, compConf := compilerConfig(next)
+	pending, err := compiler.Reload(appConf, compConf)
+	assert.NoError(t, err)
+	pending.Abort()
+	assert.Same(t, current, compiler.generation.Load())
+	assert.True(t, next.closed.Load())
+	assert.False(t, previous.closed.Load())
+}
+
+func Test_policyCompiler_Reload_DatastoreNotReady(t *testing.T) {
+	previous, next := &trackingDatastore{ready: true}, &trackingDatastore{ready: false}
+	compiler := newReloadableCompiler(previous)
+	current := compiler.generation.Load()
+
+	// The swap is aborted and the datastores of the new configuration are released
+	appConf, compConf := compilerConfig(next)
+	_, err := compiler.Reload(appConf, compConf)
+	assert.Error(t, err)
+	assert.Same(t, current, compiler.generation.Load())
+	assert.True(t, next.closed.Load())
+	assert.False(t, previous.closed.Load())
+}
+
+func Test_policyCompiler_Reload_NotConfigured(t *testing.T) {
+	appConf, compConf := compilerConfig(&trackingDatastore{ready: true})
+	_, err := (&policyCompiler{}).Reload(appConf, compConf)
+	assert.Error(t, err)
+}
+
+func Test_policyCompiler_retireWaitsForRequests(t *testing.T) {
+	previous, next := &trackingDatastore{ready: true}, &trackingDatastore{ready: true}
+	compiler := newReloadableCompiler(previous)
+
+	// A running request holds the generation it started with
+	running := compiler.acquire()
+	appConf, compConf := compilerConfig(next)
+	pending, err := compiler.Reload(appConf, compConf)
+	assert.NoError(t, err)
+	pending.Commit()
+
+	// New requests acquire the new generation right away
+	gen := compiler.acquire()
+	assert.Same(t, compConf, gen.config)
+	gen.mutex.RUnlock()
+
+	time.Sleep(50 * time.Millisecond)
+	assert.False(t, previous.closed.Load())
+
+	running.mutex.RUnlock()
+	assert.Eventually(t, previous.closed.Load, time.Second, 10*time.Millisecond)
+	assert.True(t, running.retired)
+}
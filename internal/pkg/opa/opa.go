@@ -7,16 +7,23 @@ package opa
 import (
 	"context"
 	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
+	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 
 	"github.com/open-policy-agent/opa/v1/ast"
 	"github.com/open-policy-agent/opa/v1/bundle"
 	"github.com/open-policy-agent/opa/v1/loader"
 	"github.com/open-policy-agent/opa/v1/metrics"
 	"github.com/open-policy-agent/opa/v1/plugins"
+	bundlePlugin "github.com/open-policy-agent/opa/v1/plugins/bundle"
 	"github.com/open-policy-agent/opa/v1/plugins/discovery"
 	"github.com/open-policy-agent/opa/v1/rego"
 	"github.com/open-policy-agent/opa/v1/storage"
@@ -30,6 +37,15 @@ import (
 type OPA struct {
 	configBytes []byte
 	manager     *plugins.Manager
+	regosPath   string
+	bundlePath  string
+	keyID       string
+	scope       string
+	digest      string
+	revision    atomic.Value
+	bundles     *bundlePlugin.Plugin
+	listeners   []func(revision string)
+	listenMutex sync.Mutex
 }
 
 type loadResult struct {
@@ -49,6 +65,17 @@ func ConfigOPA(conf any) func(opa *OPA) error {
 	}
 }
 
+// BundleOPA sets the bundle file or directory which is loaded instead of the regos.
+// If keys are configured in OPA's configuration, the bundle has to be signed by one of them or, if a keyID is provided, by that key.
+func BundleOPA(bundlePath, keyID, scope string) func(opa *OPA) error {
+	return func(opa *OPA) error {
+		opa.bundlePath = bundlePath
+		opa.keyID = keyID
+		opa.scope = scope
+		return nil
+	}
+}
+
 // Returns a new OPA instance.
 func NewOPA(ctx context.Context, regosPath string, opts ...func(*OPA) error) (*OPA, error) {
 	opa := &OPA{}
@@ -73,6 +100,10 @@ func NewOPA(ctx context.Context, regosPath string, opts ...func(*OPA) error) (*O
 		return nil, errors.Wrap(err, "NewOPA: Error while creating manager plugin")
 	}
 
+	if err := opa.registerBundlePlugin(); err != nil {
+		return nil, err
+	}
+
 	disc, err := discovery.New(opa.manager)
 	if err != nil {
 		return nil, errors.Wrap(err, "NewOPA: Error while creating discovery plugin")
@@ -80,21 +111,161 @@ func NewOPA(ctx context.Context, regosPath string, opts ...func(*OPA) error) (*O
 	opa.manager.Register("discovery", disc)
 
 	// Load regos
-	if err := opa.LoadRegosFromPath(ctx, regosPath); err != nil {
+	opa.regosPath = regosPath
+	if opa.bundles != nil && (opa.regosPath != "" || opa.bundlePath != "") {
+		return nil, errors.Errorf("NewOPA: Remote bundles can not be combined with a rego dir or a local bundle")
+	}
+	if err := opa.ReloadPolicies(ctx); err != nil {
 		return nil, errors.Wrap(err, "NewOPA: Unable to load regos")
 	}
 
 	return opa, nil
 }
 
+// registerBundlePlugin registers OPA's bundle plugin, if bundles are configured, which downloads and activates the
+// bundles as soon as OPA is started. The discovery plugin reuses the registered plugin.
+func (opa *OPA) registerBundlePlugin() error {
+	config, err := bundlePlugin.NewConfigBuilder().
+		WithBytes(opa.manager.Config.Bundles).
+		WithServices(opa.manager.Services()).
+		WithKeyConfigs(opa.manager.PublicKeys()).
+		Parse()
+	if err != nil {
+		return errors.Wrap(err, "NewOPA: Invalid bundles configuration")
+	}
+	if config == nil {
+		return nil
+	}
+
+	opa.bundles = bundlePlugin.New(config, opa.manager)
+	opa.bundles.RegisterBulkListener("kelon", opa.onBundleStatus)
+	opa.manager.Register(bundlePlugin.Name, opa.bundles)
+	for name := range config.Bundles {
+		logging.LogForComponent("OPA").Infof("Registered remote bundle: %s", name)
+	}
+	return nil
+}
+
+// OnActivation registers a listener, which is called with the new revision after remote bundles were activated.
+func (opa *OPA) OnActivation(listener func(revision string)) {
+	opa.listenMutex.Lock()
+	defer opa.listenMutex.Unlock()
+	opa.listeners = append(opa.listeners, listener)
+}
+
+// onBundleStatus updates the revision and notifies the listeners, if the status of the bundle plugin
+// contains newly activated revisions. The revision of multiple bundles is built as 'name=revision,...'.
+func (opa *OPA) onBundleStatus(statuses map[string]*bundlePlugin.Status) {
+	names := make([]string, 0, len(statuses))
+	for name, status := range statuses {
+		if status.Code != "" {
+			logging.LogForComponent("OPA").Warnf("Unable to activate bundle %s: %s", name, status.Message)
+		}
+		if status.ActiveRevision != "" || !status.LastSuccessfulActivation.IsZero() {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return
+	}
+	sort.Strings(names)
+
+	revision := statuses[names[0]].ActiveRevision
+	if len(names) > 1 {
+		revisions := make([]string, len(names))
+		for i, name := range names {
+			revisions[i] = fmt.Sprintf("%s=%s", name, statuses[name].ActiveRevision)
+		}
+		revision = strings.Join(revisions, ",")
+	}
+
+	opa.listenMutex.Lock()
+	defer opa.listenMutex.Unlock()
+	if revision == opa.Revision() {
+		return
+	}
+	opa.revision.Store(revision)
+	logging.LogForComponent("OPA").Infof("Activated remote bundles with revision %s", revision)
+	for _, listener := range opa.listeners {
+		listener(revision)
+	}
+}
+
+// ReloadPolicies loads the configured bundle or, if no bundle is configured, the regos again.
+func (opa *OPA) ReloadPolicies(ctx context.Context) error {
+	if opa.bundlePath != "" {
+		return opa.LoadBundleFromPath(ctx, opa.bundlePath)
+	}
+	return opa.LoadRegosFromPath(ctx, opa.regosPath)
+}
+
+// LoadBundleFromPath replaces the active policies and documents with the bundle file (i.e. built by 'opa build') or
+// directory at the path. If keys are configured for OPA, the bundle has to contain a '.signatures.json' which is verified
+// with them, so that a verification can't be skipped by removing the signatures. The revision of the bundle's manifest is reported as revision. If the bundle can not be loaded, verified or compiled,
+// the previous revision stays active.
+func (opa *OPA) LoadBundleFromPath(ctx context.Context, bundlePath string) error {
+	logging.LogForComponent("OPA").Debugf("Loading bundle from: %s", bundlePath)
+
+	verification, err := opa.verificationConfig()
+	if err != nil {
+		return err
+	}
+	fileLoader := loader.NewFileLoader()
+	if verification != nil {
+		fileLoader = fileLoader.WithBundleVerificationConfig(verification)
+	}
+
+	loadedBundle, err := fileLoader.AsBundle(bundlePath)
+	if err != nil {
+		return errors.Wrap(err, "NewOPA: Error while loading bundle")
+	}
+	// OPA only rejects unsigned bundles if a keyID is set
+	if verification != nil && len(loadedBundle.Signatures.Signatures) == 0 {
+		return errors.Errorf("NewOPA: Bundle %q is not signed, but verification keys are configured", bundlePath)
+	}
+	loaded := &loadResult{Bundles: map[string]*bundle.Bundle{bundlePath: loadedBundle}}
+
+	digest, err := computeRevision(loaded)
+	if err != nil {
+		return errors.Wrap(err, "NewOPA: Error while computing revision")
+	}
+	revision := loadedBundle.Manifest.Revision
+	if revision == "" {
+		revision = digest
+	}
+	return opa.activate(ctx, loaded, "", digest, revision)
+}
+
+// verificationConfig builds the configuration to verify signed bundles from the keys of OPA's configuration.
+// If only a single key is configured and no keyID was provided, the bundle has to be signed by that key.
+func (opa *OPA) verificationConfig() (*bundle.VerificationConfig, error) {
+	keys := opa.manager.PublicKeys()
+	keyID := opa.keyID
+	if keyID != "" {
+		if _, ok := keys[keyID]; !ok {
+			return nil, errors.Errorf("NewOPA: Bundle verification key %q is not configured in the keys of OPA's configuration", keyID)
+		}
+	}
+	if len(keys) == 0 {
+		// Signed bundles are rejected, because they can not be verified
+		return nil, nil
+	}
+	if keyID == "" && len(keys) == 1 {
+		for id := range keys {
+			keyID = id
+		}
+	}
+	return bundle.NewVerificationConfig(keys, keyID, opa.scope, nil), nil
+}
+
+// LoadRegosFromPath replaces all regos and documents, which were loaded from the path before, with the ones currently on disk.
+// All files are activated in a single transaction. If they can not be loaded or compiled, the previous revision stays active.
 func (opa *OPA) LoadRegosFromPath(ctx context.Context, regosPath string) error {
 	// Return with no error on empty path
 	if regosPath == "" {
 		return nil
 	}
 
-	store := opa.manager.Store
-
 	logging.LogForComponent("OPA").Debugf("Loading regos from dir: %s", regosPath)
 	filter := func(abspath string, _ os.FileInfo, _ int) bool {
 		return !strings.HasSuffix(abspath, ".rego")
@@ -103,21 +274,44 @@ func (opa *OPA) LoadRegosFromPath(ctx context.Context, regosPath string) error {
 	if err != nil {
 		return errors.Wrap(err, "NewOPA: Error while loading rego dir")
 	}
+
+	revision, err := computeRevision(loaded)
+	if err != nil {
+		return errors.Wrap(err, "NewOPA: Error while computing revision")
+	}
+	for _, loadedBundle := range loaded.Bundles {
+		loadedBundle.Manifest.Revision = revision
+	}
+	return opa.activate(ctx, loaded, regosPath, revision, revision)
+}
+
+// activate replaces the active bundle with the loaded one in a single transaction, unless its digest did not change.
+// Loaded documents are written to the documentPath.
+func (opa *OPA) activate(ctx context.Context, loaded *loadResult, documentPath, digest, revision string) error {
+	if digest == opa.digest {
+		logging.LogForComponent("OPA").Debugf("Policies are unchanged (revision %s)", revision)
+		return nil
+	}
+
 	for bundleName, loadedBundle := range loaded.Bundles {
 		logging.LogForComponent("OPA").Infof("Loading Bundle: %s", bundleName)
 		for _, module := range loadedBundle.Modules {
 			logging.LogForComponent("OPA").Infof("Loaded Package: [%s] -> module [%s]", module.Parsed.Package.String(), module.Path)
 		}
 	}
+
+	store := opa.manager.Store
 	txn, err := store.NewTransaction(ctx, storage.WriteParams)
 	if err != nil {
 		return errors.Wrap(err, "NewOPA: Error while opening transaction")
 	}
 	if len(loaded.Documents) > 0 {
-		if err := store.Write(ctx, txn, storage.AddOp, storage.MustParsePath(regosPath), loaded.Documents); err != nil {
+		if err := store.Write(ctx, txn, storage.AddOp, storage.MustParsePath(documentPath), loaded.Documents); err != nil {
+			store.Abort(ctx, txn)
 			return errors.Wrap(err, "NewOPA: Error while writing document")
 		}
 	}
+	// Activating the bundle erases all modules and documents of its previous version
 	if err := compileAndStoreInputs(ctx, store, txn, loaded, 1); err != nil {
 		store.Abort(ctx, txn)
 		return errors.Wrap(err, "NewOPA: Error while storing inputs")
@@ -126,9 +320,58 @@ func (opa *OPA) LoadRegosFromPath(ctx context.Context, regosPath string) error {
 		return errors.Wrap(err, "NewOPA: Error while commit")
 	}
 
+	opa.digest = digest
+	opa.revision.Store(revision)
+	logging.LogForComponent("OPA").Infof("Activated policies with revision %s", revision)
 	return nil
 }
 
+// Revision returns the revision of the active regos or bundle, which is empty until the first policies were loaded.
+func (opa *OPA) Revision() string {
+	revision, _ := opa.revision.Load().(string)
+	return revision
+}
+
+// computeRevision hashes the paths and contents of all loaded modules and documents
+func computeRevision(loaded *loadResult) (string, error) {
+	hash := sha256.New()
+
+	names := make([]string, 0, len(loaded.Bundles))
+	for name := range loaded.Bundles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		loadedBundle := loaded.Bundles[name]
+		modules := make([]bundle.ModuleFile, len(loadedBundle.Modules))
+		copy(modules, loadedBundle.Modules)
+		sort.Slice(modules, func(i, j int) bool { return modules[i].Path < modules[j].Path })
+
+		for _, module := range modules {
+			hash.Write([]byte(module.Path))
+			hash.Write([]byte{0})
+			hash.Write(module.Raw)
+			hash.Write([]byte{0})
+		}
+
+		// Map keys are sorted during marshalling
+		documents, err := json.Marshal(loadedBundle.Data)
+		if err != nil {
+			return "", err
+		}
+		hash.Write(documents)
+	}
+
+	documents, err := json.Marshal(loaded.Documents)
+	if err != nil {
+		return "", err
+	}
+	hash.Write(documents)
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
 // Start asynchronously starts the policy engine's plugins that download
 // policies, report status, etc.
 func (opa *OPA) Start(ctx context.Context) error {
@@ -162,6 +405,61 @@ func (opa *OPA) PartialEvaluate(ctx context.Context, input any, query string, op
 	return partialResult, err
 }
 
+// Evaluate fully evaluates the query, which must not depend on unknowns.
+func (opa *OPA) Evaluate(ctx context.Context, input any, query string) (rego.ResultSet, error) {
+	var resultSet rego.ResultSet
+
+	err := storage.Txn(ctx, opa.manager.Store, storage.TransactionParams{}, func(txn storage.Transaction) error {
+		r := rego.New(
+			rego.Query(query),
+			rego.Input(input),
+			rego.Compiler(opa.manager.GetCompiler()),
+			rego.Store(opa.manager.Store),
+			rego.Transaction(txn))
+
+		rs, err := r.Eval(ctx)
+		if err != nil {
+			return err
+		}
+		resultSet = rs
+		return nil
+	})
+
+	return resultSet, err
+}
+
+// RuleQuery returns the query, which succeeds if the rule in the package is true. Rules containing multiple values
+// (i.e. 'deny contains msg if ...' or partial objects) succeed if they contain any value, which must not depend on unknowns.
+func (opa *OPA) RuleQuery(pkg, rule string) string {
+	document := fmt.Sprintf("data.%s.%s", pkg, rule)
+	ref, err := ast.ParseRef(document)
+	if err != nil {
+		return document + " == true"
+	}
+	for _, r := range opa.manager.GetCompiler().GetRulesWithPrefix(ref) {
+		if r.Head.RuleKind() == ast.MultiValue || len(r.Head.Ref()) > 1 {
+			return fmt.Sprintf("count(%s) > 0", document)
+		}
+	}
+	return document + " == true"
+}
+
+// HasRule returns true if the currently loaded policies define the rule in the package (including partial rules).
+// Rules of sub packages with the same name (i.e. package 'pkg.rule') don't count.
+func (opa *OPA) HasRule(pkg, rule string) bool {
+	pkgRef, err := ast.ParseRef("data." + pkg)
+	if err != nil {
+		return false
+	}
+	ruleRef := pkgRef.Append(ast.StringTerm(rule))
+	for _, r := range opa.manager.GetCompiler().GetRulesWithPrefix(ruleRef) {
+		if r.Module.Package.Path.Equal(pkgRef) && r.Head.Ref()[0].Value.Compare(ast.Var(rule)) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
 func uuid4() (string, error) {
 	bs := make([]byte, 16)
 	n, err := io.ReadFull(rand.Reader, bs)
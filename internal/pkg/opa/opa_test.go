@@ -0,0 +1,164 @@
+package opa
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/open-policy-agent/opa/v1/bundle"
+	"github.com/open-policy-agent/opa/v1/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func writeRego(t *testing.T, dir, name, content string) {
+	t.Helper()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600))
+}
+
+func loadedPackages(t *testing.T, engine *OPA) []string {
+	t.Helper()
+	var packages []string
+	assert.NoError(t, storage.Txn(context.Background(), engine.manager.Store, storage.TransactionParams{}, func(_ storage.Transaction) error {
+		for _, module := range engine.manager.GetCompiler().Modules {
+			packages = append(packages, module.Package.Path.String())
+		}
+		return nil
+	}))
+	return packages
+}
+
+func Test_LoadRegosFromPath(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	writeRego(t, dir, "a.rego", "package a\nallow := true\n")
+	writeRego(t, dir, "b.rego", "package b\nallow := true\n")
+
+	engine, err := NewOPA(ctx, dir)
+	assert.NoError(t, err)
+	assert.NoError(t, engine.Start(ctx))
+	initial := engine.Revision()
+	assert.NotEmpty(t, initial)
+	assert.ElementsMatch(t, []string{"data.a", "data.b"}, loadedPackages(t, engine))
+
+	// Unchanged files keep the revision
+	assert.NoError(t, engine.LoadRegosFromPath(ctx, dir))
+	assert.Equal(t, initial, engine.Revision())
+
+	// Deleted files remove their modules
+	assert.NoError(t, os.Remove(filepath.Join(dir, "b.rego")))
+	assert.NoError(t, engine.LoadRegosFromPath(ctx, dir))
+	assert.ElementsMatch(t, []string{"data.a"}, loadedPackages(t, engine))
+	lastGood := engine.Revision()
+	assert.NotEqual(t, initial, lastGood)
+
+	// Compile errors keep the last good revision
+	writeRego(t, dir, "a.rego", "package a\nallow if unknown_function(1)\n")
+	writeRego(t, dir, "c.rego", "package c\nallow := true\n")
+	assert.Error(t, engine.LoadRegosFromPath(ctx, dir))
+	assert.Equal(t, lastGood, engine.Revision())
+	assert.ElementsMatch(t, []string{"data.a"}, loadedPackages(t, engine))
+}
+
+func writeBundle(t *testing.T, path, revision, signingKey string) {
+	t.Helper()
+	b := bundle.Bundle{
+		Manifest: bundle.Manifest{Revision: revision, Roots: &[]string{"a"}},
+		Data:     map[string]any{},
+		Modules: []bundle.ModuleFile{{
+			URL:  "/a/policy.rego",
+			Path: "/a/policy.rego",
+			Raw:  []byte("package a\nallow := true\n"),
+		}},
+	}
+	if signingKey != "" {
+		assert.NoError(t, b.GenerateSignature(bundle.NewSigningConfig(signingKey, "HS256", ""), "test", false))
+	}
+
+	file, err := os.Create(path)
+	assert.NoError(t, err)
+	defer file.Close()
+	assert.NoError(t, bundle.NewWriter(file).Write(b))
+}
+
+func Test_LoadBundleFromPath(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "bundle.tar.gz")
+	keys := ConfigOPA(map[string]any{"keys": map[string]any{"test": map[string]any{"algorithm": "HS256", "key": "secret"}}})
+
+	// Signed bundles are verified and report the revision of their manifest
+	writeBundle(t, path, "v1", "secret")
+	engine, err := NewOPA(ctx, "", keys, BundleOPA(path, "test", ""))
+	assert.NoError(t, err)
+	assert.NoError(t, engine.Start(ctx))
+	assert.Equal(t, "v1", engine.Revision())
+	assert.ElementsMatch(t, []string{"data.a"}, loadedPackages(t, engine))
+
+	// Bundles signed with an unknown key are rejected
+	writeBundle(t, path, "v2", "wrong-secret")
+	assert.Error(t, engine.ReloadPolicies(ctx))
+	assert.Equal(t, "v1", engine.Revision())
+
+	// Unsigned bundles are rejected if a key is required
+	writeBundle(t, path, "v3", "")
+	assert.Error(t, engine.ReloadPolicies(ctx))
+	assert.Equal(t, "v1", engine.Revision())
+
+	// Signed bundles are rejected if no key is configured
+	writeBundle(t, path, "v4", "secret")
+	_, err = NewOPA(ctx, "", BundleOPA(path, "", ""))
+	assert.Error(t, err)
+}
+
+func Test_LoadBundleFromPath_WithoutKeyID(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "bundle.tar.gz")
+
+	for name, keys := range map[string]map[string]any{
+		"single key": {"test": map[string]any{"algorithm": "HS256", "key": "secret"}},
+		"multiple keys": {
+			"test":  map[string]any{"algorithm": "HS256", "key": "secret"},
+			"other": map[string]any{"algorithm": "HS256", "key": "other-secret"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			opaConfig := ConfigOPA(map[string]any{"keys": keys})
+
+			// Unsigned bundles are rejected as soon as keys are configured, even if no key is required explicitly
+			writeBundle(t, path, "v1", "")
+			_, err := NewOPA(ctx, "", opaConfig, BundleOPA(path, "", ""))
+			assert.Error(t, err)
+
+			writeBundle(t, path, "v2", "secret")
+			engine, err := NewOPA(ctx, "", opaConfig, BundleOPA(path, "", ""))
+			assert.NoError(t, err)
+			assert.NoError(t, engine.Start(ctx))
+			assert.Equal(t, "v2", engine.Revision())
+
+			// Removing the signatures doesn't skip the verification
+			writeBundle(t, path, "v3", "")
+			assert.Error(t, engine.ReloadPolicies(ctx))
+			assert.Equal(t, "v2", engine.Revision())
+		})
+	}
+}
+
+func Test_HasRule(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	writeRego(t, dir, "pure.rego", "package applications.pure\nallow := true\nheaders[\"X-User\"] := input.user\n")
+	writeRego(t, dir, "reason.rego", "package applications.pure.reason\nmessage := \"sub package\"\n")
+
+	engine, err := NewOPA(ctx, dir)
+	assert.NoError(t, err)
+	assert.NoError(t, engine.Start(ctx))
+
+	assert.True(t, engine.HasRule("applications.pure", "allow"))
+	assert.True(t, engine.HasRule("applications.pure", "headers"))
+	assert.False(t, engine.HasRule("applications.pure", "obligations"))
+	// A sub package is not a rule of the package
+	assert.False(t, engine.HasRule("applications.pure", "reason"))
+	assert.True(t, engine.HasRule("applications.pure.reason", "message"))
+	assert.False(t, engine.HasRule("applications", "pure"))
+}
@@ -0,0 +1,88 @@
+package opa
+
+import (
+	"context"
+
+	"github.com/open-policy-agent/opa/v1/metrics"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/unbasical/kelon/pkg/constants"
+	"github.com/unbasical/kelon/pkg/constants/logging"
+	"github.com/unbasical/kelon/pkg/data"
+	internalErrors "github.com/unbasical/kelon/pkg/errors"
+	"github.com/unbasical/kelon/pkg/opa"
+	"github.com/unbasical/kelon/pkg/request"
+)
+
+// allowShadowed allows the request, if it was denied (by the policy or by the request translation) for a mapping in
+// shadow mode. The denial is logged and counted. All other errors are returned unchanged.
+func (compiler *policyCompiler) allowShadowed(ctx context.Context, decision *opa.Decision, err error) error {
+	var invalidRequestTranslation internalErrors.InvalidRequestTranslation
+	if err != nil && !errors.As(errors.Cause(err), &invalidRequestTranslation) {
+		return err
+	}
+	if err == nil && decision.Allow {
+		return nil
+	}
+
+	reason := "Unauthorized"
+	if !decision.Verify {
+		reason = "Unauthenticated"
+	}
+	if provider := compiler.generation.Load().appConfig.MetricsProvider; provider != nil {
+		provider.UpdateCounterMetric(ctx, constants.InstrumentShadowDenials, int64(1), map[string]string{
+			constants.LabelRegoPackage:          decision.Package,
+			constants.LabelPolicyDecisionReason: reason,
+		})
+	}
+	logging.LogForComponent("policyCompiler").WithFields(log.Fields{
+		logging.LabelDecisionID: decision.ID,
+		logging.LabelPath:       decision.Path,
+		logging.LabelMethod:     decision.Method,
+		logging.LabelReason:     reason,
+	}).Infof("Allowed request, which package %s denied, in shadow mode", decision.Package)
+
+	decision.Allow = true
+	decision.Verify = true
+	return nil
+}
+
+// evalCandidate evaluates the candidate package of the mapping with the same input and reports if its decision
+// disagrees with the decision of the live package. The metrics of the candidate are not recorded and its datastore queries
+// are neither shared with the live decision nor recorded for it, but recorded in the candidate's own recorder (which may be nil).
+func (compiler *policyCompiler) evalCandidate(ctx context.Context, config *opa.PolicyCompilerConfig, input map[string]any, output *request.PathProcessorOutput, decision *opa.Decision, queries *data.QueryRecorder) {
+	candidateOutput := *output
+	candidateOutput.Package = output.CandidatePackage
+	candidate := &opa.CandidateDecision{Package: output.CandidatePackage}
+	decision.Candidate = candidate
+
+	m := metrics.New()
+	candidateCtx := data.WithQueryRecorder(data.WithSharedQueries(ctx, nil), queries)
+	candidate.Verify, candidate.Error = compiler.authenticate(candidateCtx, config, input, &candidateOutput, m)
+	if candidate.Error == nil && candidate.Verify {
+		candidate.Allow, candidate.Error = compiler.authorize(candidateCtx, config, input, &candidateOutput, m)
+	}
+
+	logger := logging.LogForComponent("policyCompiler").WithFields(log.Fields{
+		logging.LabelDecisionID: opa.DecisionIDFromContext(ctx),
+		logging.LabelPath:       decision.Path,
+		logging.LabelMethod:     decision.Method,
+	})
+	if candidate.Error != nil {
+		logger.Warnf("Unable to evaluate candidate package %s: %s", candidate.Package, candidate.Error)
+		return
+	}
+	candidate.Mismatch = candidate.Allow != decision.Allow || candidate.Verify != decision.Verify
+	if !candidate.Mismatch {
+		return
+	}
+
+	if provider := compiler.generation.Load().appConfig.MetricsProvider; provider != nil {
+		provider.UpdateCounterMetric(ctx, constants.InstrumentCandidateMismatches, int64(1), map[string]string{
+			constants.LabelRegoPackage:          decision.Package,
+			constants.LabelRegoCandidatePackage: candidate.Package,
+		})
+	}
+	logger.Warnf("Candidate package %s disagrees with package %s: verify=%t allow=%t instead of verify=%t allow=%t",
+		candidate.Package, decision.Package, candidate.Verify, candidate.Allow, decision.Verify, decision.Allow)
+}
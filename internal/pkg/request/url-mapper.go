@@ -25,6 +25,7 @@ type compiledMapping struct {
 	mapping        *configs.APIMapping
 	authorization  bool
 	authentication bool
+	rules          request.PolicyRules
 	importance     int
 	datastores     []string
 }
@@ -107,10 +108,13 @@ func (mapper *pathMapper) handleInput(input *pathMapperInput) (*request.MapperOu
 
 		// Match found
 		return &request.MapperOutput{
-			Datastores:     matches[0].datastores,
-			Package:        matches[0].mapping.Package,
-			Authentication: matches[0].authentication,
-			Authorization:  matches[0].authorization,
+			Datastores:       matches[0].datastores,
+			Package:          matches[0].mapping.Package,
+			Authentication:   matches[0].authentication,
+			Authorization:    matches[0].authorization,
+			Rules:            matches[0].rules,
+			Shadow:           matches[0].mapping.Shadow,
+			CandidatePackage: matches[0].mapping.CandidatePackage,
 		}, nil
 	}
 
@@ -133,6 +137,7 @@ func (mapper *pathMapper) generateMappings() error {
 				mapping:        mapping,
 				authentication: *dsMapping.Authentication,
 				authorization:  *dsMapping.Authorization,
+				rules:          policyRules(mapping),
 				importance:     len(pathPrefix) + len(mapping.Path) + len(mapping.Queries) + len(mapping.Methods),
 				datastores:     dsMapping.Datastores,
 			})
@@ -141,6 +146,22 @@ func (mapper *pathMapper) generateMappings() error {
 	return nil
 }
 
+// policyRules returns the rules of the mapping, which default to 'verify' and 'allow'
+func policyRules(mapping *configs.APIMapping) request.PolicyRules {
+	rules := request.PolicyRules{
+		Verify: mapping.VerifyRule,
+		Allow:  mapping.AllowRule,
+		Deny:   mapping.DenyRules,
+	}
+	if rules.Verify == "" {
+		rules.Verify = "verify"
+	}
+	if rules.Allow == "" && len(rules.Deny) == 0 {
+		rules.Allow = "allow"
+	}
+	return rules
+}
+
 // compileMappingRegex compiles the regex used for the API Mapping based on the config, including Method and Query limitations
 func compileMappingRegex(pathPrefix string, mapping *configs.APIMapping) (*regexp.Regexp, error) {
 	endpointsRegex := "[(GET)|(POST)|(PUT)|(DELETE)|(PATCH)]"
@@ -93,12 +93,15 @@ func (processor *urlProcessor) handleInput(input *URLProcessorInput) (*request.P
 		return nil, errors.Wrap(err, "UrlProcessor: Error during path mapping.")
 	}
 	output := request.PathProcessorOutput{
-		Datastores:     out.Datastores,
-		Package:        out.Package,
-		Authentication: out.Authentication,
-		Authorization:  out.Authorization,
-		Path:           pathSegments,
-		Queries:        queries,
+		Datastores:       out.Datastores,
+		Package:          out.Package,
+		Authentication:   out.Authentication,
+		Authorization:    out.Authorization,
+		Rules:            out.Rules,
+		Shadow:           out.Shadow,
+		CandidatePackage: out.CandidatePackage,
+		Path:             pathSegments,
+		Queries:          queries,
 	}
 	return &output, nil
 }
@@ -2,6 +2,7 @@ package translate
 
 import (
 	"context"
+	"strings"
 	"time"
 
 	"github.com/open-policy-agent/opa/v1/rego"
@@ -19,6 +20,7 @@ type astTranslator struct {
 	appConf    *configs.AppConfig
 	config     *translate.AstTranslatorConfig
 	configured bool
+	cache      *statementCache
 }
 
 // NewAstTranslator creates a new instance of the default translate.AstTranslator.
@@ -50,6 +52,10 @@ func (trans *astTranslator) Configure(appConf *configs.AppConfig, transConf *tra
 		}
 	}
 
+	if transConf.StatementCacheSize > 0 {
+		trans.cache = newStatementCache(transConf.StatementCacheSize)
+	}
+
 	// Assign variables
 	trans.appConf = appConf
 	trans.config = transConf
@@ -58,22 +64,109 @@ func (trans *astTranslator) Configure(appConf *configs.AppConfig, transConf *tra
 	return nil
 }
 
+// ReloadCallOperands - see data.CallOperandsReloader
+func (trans *astTranslator) ReloadCallOperands(callOps map[string]map[string]func(args ...string) (string, error)) error {
+	if !trans.configured {
+		return errors.Errorf("AstTranslator was not configured! Please call Configure(). ")
+	}
+
+	for dsName, ds := range trans.config.Datastores {
+		reloader, ok := (*ds).(data.CallOperandsReloader)
+		if !ok {
+			return errors.Errorf("AstTranslator: Datastore %s can not reload call operands", dsName)
+		}
+		if err := reloader.ReloadCallOperands(callOps); err != nil {
+			return errors.Wrap(err, "AstTranslator: Error while reloading call operands of datastore "+dsName)
+		}
+	}
+
+	// Cached statements were translated with the previous call operands
+	if trans.cache != nil {
+		trans.cache.invalidate()
+	}
+	return nil
+}
+
 // Process - see translate.AstTranslator
 func (trans *astTranslator) Process(ctx context.Context, response *rego.PartialQueries, datastores []string) (bool, error) {
 	if !trans.configured {
 		return false, errors.Errorf("AstTranslator was not configured! Please call Configure(). ")
 	}
 
+	if trans.cache == nil {
+		return trans.processUncached(ctx, response, datastores)
+	}
+
+	// Requests which only differ in the constants of the residual queries share the same translated statements
+	pkg, _ := ctx.Value(constants.ContextKeyRegoPackage).(string)
+	rule, _ := ctx.Value(constants.ContextKeyRegoRule).(string)
+	shape, values := residualShape(response.Queries)
+	key := strings.Join([]string{pkg, rule, strings.Join(datastores, ","), shape}, "|")
+
+	if statements, hit := trans.cache.get(key); hit {
+		trans.recordCacheLookup(ctx, pkg, "hit")
+		queries := make(map[string]data.DatastoreQuery, len(statements))
+		for _, statement := range statements {
+			queries[statement.datastore] = statement.bind(values)
+		}
+		return trans.executePrepared(ctx, queries)
+	}
+	trans.recordCacheLookup(ctx, pkg, "miss")
+
+	// The generation has to be read before the translation, because the call operands might be reloaded during it
+	generation := trans.cache.generation.Load()
+	datastoreSpecificQueries, err := trans.translate(ctx, response, datastores)
+	if err != nil {
+		return false, err
+	}
+
+	queries := make(map[string]data.DatastoreQuery, len(datastoreSpecificQueries))
+	for datastore, specificQuery := range datastoreSpecificQueries {
+		targetDB, ok := trans.config.Datastores[datastore]
+		if !ok {
+			return false, errors.Errorf("AstTranslator: Unable to find datastore: %s", datastore)
+		}
+		prepared, ok := (*targetDB).(data.PreparedDatastore)
+		if !ok {
+			// Statements can not be cached for this datastore
+			return trans.execute(ctx, datastoreSpecificQueries)
+		}
+		query, translateErr := prepared.Translate(ctx, specificQuery)
+		if translateErr != nil {
+			return false, translateErr
+		}
+		queries[datastore] = query
+	}
+
+	if statements, ok := bindStatements(queries, values); ok {
+		trans.cache.put(key, generation, statements)
+	} else {
+		logging.LogForComponent("astTranslator").Debugf("Statements of package [%s] contain inlined constants and are not cached", pkg)
+	}
+	return trans.executePrepared(ctx, queries)
+}
+
+// processUncached translates and executes the partially evaluated queries without using the statement cache
+func (trans *astTranslator) processUncached(ctx context.Context, response *rego.PartialQueries, datastores []string) (bool, error) {
+	datastoreSpecificQueries, err := trans.translate(ctx, response, datastores)
+	if err != nil {
+		return false, err
+	}
+	return trans.execute(ctx, datastoreSpecificQueries)
+}
+
+// translate preprocesses and processes the partially evaluated queries into one query per datastore
+func (trans *astTranslator) translate(ctx context.Context, response *rego.PartialQueries, datastores []string) (map[string]data.Node, error) {
 	preprocessedQueries, preprocessErr := newAstPreprocessor().Process(ctx, response.Queries, datastores)
 	if preprocessErr != nil {
-		return false, errors.Wrap(preprocessErr, "AstTranslator: Error during preprocessing.")
+		return nil, errors.Wrap(preprocessErr, "AstTranslator: Error during preprocessing.")
 	}
 
 	datastoreSpecificQueries := make(map[string]data.Node)
 	for _, preprocessed := range preprocessedQueries {
 		processedQuery, processErr := newAstProcessor(trans.config.SkipUnknown, trans.config.ValidateMode).Process(ctx, preprocessed.query)
 		if processErr != nil {
-			return false, processErr
+			return nil, processErr
 		}
 
 		node, ok := datastoreSpecificQueries[preprocessed.datastore]
@@ -84,38 +177,79 @@ func (trans *astTranslator) Process(ctx context.Context, response *rego.PartialQ
 
 		datastoreSpecificQueries[preprocessed.datastore] = data.Union{Clauses: append(union.Clauses, processedQuery)}
 	}
+	return datastoreSpecificQueries, nil
+}
 
+// execute translates and executes the query of each datastore until one of them allows the request
+func (trans *astTranslator) execute(ctx context.Context, datastoreSpecificQueries map[string]data.Node) (bool, error) {
 	for datastore, specificQuery := range datastoreSpecificQueries {
 		targetDB, ok := trans.config.Datastores[datastore]
 		if !ok {
 			return false, errors.Errorf("AstTranslator: Unable to find datastore: %s", datastore)
 		}
 
-		pkg := ctx.Value(constants.ContextKeyRegoPackage).(string)
-		labels := map[string]string{
-			constants.LabelRegoPackage: pkg,
-			constants.LabelDBPoolName:  datastore,
-		}
-
 		queryToExecute := specificQuery
-		function := func(ctx context.Context, _ ...any) (any, error) {
-			startTime := time.Now()
-			decision, err := (*targetDB).Execute(ctx, queryToExecute)
-			duration := time.Since(startTime)
-
-			// Update Metrics
-			trans.appConf.MetricsProvider.UpdateHistogramMetric(ctx, constants.InstrumentDecisionDuration, duration.Milliseconds(), labels)
-			return decision, err
+		allowed, err := trans.executeWithTelemetry(ctx, datastore, func(ctx context.Context) (bool, error) {
+			return (*targetDB).Execute(ctx, queryToExecute)
+		})
+		if err != nil || allowed {
+			return allowed, err
 		}
+	}
+	return false, nil
+}
 
-		res, err := trans.appConf.TraceProvider.ExecuteWithChildSpan(ctx, function, spanNameDatastoreQuery, labels)
-		if err != nil {
-			return false, err
+// executePrepared executes the already translated query of each datastore until one of them allows the request
+func (trans *astTranslator) executePrepared(ctx context.Context, queries map[string]data.DatastoreQuery) (bool, error) {
+	for datastore, query := range queries {
+		targetDB, ok := trans.config.Datastores[datastore]
+		if !ok {
+			return false, errors.Errorf("AstTranslator: Unable to find datastore: %s", datastore)
+		}
+		prepared, ok := (*targetDB).(data.PreparedDatastore)
+		if !ok {
+			return false, errors.Errorf("AstTranslator: Datastore %s does not support prepared queries", datastore)
 		}
 
-		if res.(bool) {
-			return true, nil
+		queryToExecute := query
+		allowed, err := trans.executeWithTelemetry(ctx, datastore, func(ctx context.Context) (bool, error) {
+			return prepared.ExecuteQuery(ctx, queryToExecute)
+		})
+		if err != nil || allowed {
+			return allowed, err
 		}
 	}
 	return false, nil
 }
+
+// executeWithTelemetry wraps the execution of a datastore query with a span and the decision duration metric
+func (trans *astTranslator) executeWithTelemetry(ctx context.Context, datastore string, execute func(ctx context.Context) (bool, error)) (bool, error) {
+	pkg, _ := ctx.Value(constants.ContextKeyRegoPackage).(string)
+	labels := map[string]string{
+		constants.LabelRegoPackage: pkg,
+		constants.LabelDBPoolName:  datastore,
+	}
+
+	function := func(ctx context.Context, _ ...any) (any, error) {
+		startTime := time.Now()
+		decision, err := execute(ctx)
+		duration := time.Since(startTime)
+
+		// Update Metrics
+		trans.appConf.MetricsProvider.UpdateHistogramMetric(ctx, constants.InstrumentDecisionDuration, duration.Milliseconds(), labels)
+		return decision, err
+	}
+
+	res, err := trans.appConf.TraceProvider.ExecuteWithChildSpan(ctx, function, spanNameDatastoreQuery, labels)
+	if err != nil {
+		return false, err
+	}
+	return res.(bool), nil
+}
+
+func (trans *astTranslator) recordCacheLookup(ctx context.Context, pkg, result string) {
+	trans.appConf.MetricsProvider.UpdateCounterMetric(ctx, constants.InstrumentStatementCacheRequests, int64(1), map[string]string{
+		constants.LabelRegoPackage: pkg,
+		constants.LabelCacheResult: result,
+	})
+}
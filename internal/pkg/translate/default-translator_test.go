@@ -0,0 +1,24 @@
+package translate
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/unbasical/kelon/configs"
+	"github.com/unbasical/kelon/pkg/telemetry"
+)
+
+func Test_executeWithTelemetry_WithoutPackage(t *testing.T) {
+	trans := &astTranslator{appConf: &configs.AppConfig{
+		MetricsProvider: telemetry.NewNoopMetricProvider(),
+		TraceProvider:   telemetry.NewNoopTraceProvider(),
+	}}
+
+	// The package is missing in the context, e.g. if the translator is used without the PolicyCompiler
+	allowed, err := trans.executeWithTelemetry(context.Background(), "mysql", func(_ context.Context) (bool, error) {
+		return true, nil
+	})
+	assert.NoError(t, err)
+	assert.True(t, allowed)
+}
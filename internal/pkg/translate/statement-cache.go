@@ -0,0 +1,156 @@
+package translate
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	"github.com/open-policy-agent/opa/v1/ast"
+	"github.com/unbasical/kelon/internal/pkg/util"
+	"github.com/unbasical/kelon/pkg/data"
+)
+
+// statementCache holds the translated statements. Each invalidation starts a new generation, so that statements
+// which were translated before it (i.e. with previous call operands) can't be cached afterwards.
+type statementCache struct {
+	cache      *util.LRUCache[string, cachedStatements]
+	generation atomic.Uint64
+}
+
+// cachedStatements are the statements of a residual query tagged with the generation of the cache they were translated in
+type cachedStatements struct {
+	statements []cachedStatement
+	generation uint64
+}
+
+// cachedStatement is a translated datastore query whose parameters are bound to the constants of the residual query
+type cachedStatement struct {
+	datastore string
+	statement any
+	bindings  []int
+}
+
+// newStatementCache creates a statement cache, which holds the statements of at most size residual queries
+func newStatementCache(size int) *statementCache {
+	return &statementCache{cache: util.NewLRUCache[string, cachedStatements](size, 0)}
+}
+
+// invalidate drops all cached statements and starts a new generation
+func (c *statementCache) invalidate() {
+	c.generation.Add(1)
+	c.cache.Clear()
+}
+
+// get returns the cached statements for the key, if they were translated in the current generation
+func (c *statementCache) get(key string) ([]cachedStatement, bool) {
+	entry, hit := c.cache.Get(key)
+	if !hit || entry.generation != c.generation.Load() {
+		return nil, false
+	}
+	return entry.statements, true
+}
+
+// put caches the statements, which were translated in the given generation. Statements of previous generations are
+// dropped, because the cache was invalidated while they were translated.
+func (c *statementCache) put(key string, generation uint64, statements []cachedStatement) {
+	if generation != c.generation.Load() {
+		return
+	}
+	c.cache.Put(key, cachedStatements{statements: statements, generation: generation})
+}
+
+// residualShape replaces all constants inside the partially evaluated queries with placeholders and returns the
+// resulting shape as string together with the normalized values of the replaced constants (in order of their placeholders).
+//
+// Only constants which are translated to data.Constant by the astProcessor are replaced. Constants inside
+// references and composite values stay part of the shape.
+func residualShape(queries []ast.Body) (string, []string) {
+	var (
+		values []string
+		shapes = make([]string, len(queries))
+	)
+
+	visitor := ast.NewGenericVisitor(func(x any) bool {
+		term, ok := x.(*ast.Term)
+		if !ok {
+			return false
+		}
+
+		switch v := term.Value.(type) {
+		case ast.Boolean, ast.String, ast.Number:
+			constant := makeConstant(v.String()).(*data.Constant)
+			term.Value = ast.Var(fmt.Sprintf("__const%d_%s", len(values), constantKind(constant)))
+			values = append(values, constant.Value)
+			return true
+		case ast.Call:
+			return false
+		default:
+			return true
+		}
+	})
+
+	for i, query := range queries {
+		shape := query.Copy()
+		visitor.Walk(shape)
+		shapes[i] = shape.String()
+	}
+	return strings.Join(shapes, "\n"), values
+}
+
+// constantKind returns the kind of constant, because the kind might influence the translation
+func constantKind(constant *data.Constant) string {
+	switch {
+	case constant.IsInt:
+		return "int"
+	case constant.IsFloat32:
+		return "float"
+	default:
+		return "string"
+	}
+}
+
+// bindStatements maps each parameter of the translated queries to the constant of the residual query it was created from.
+// This is only possible if every constant was passed as unique parameter, otherwise a translated statement would contain
+// values of the request and false is returned.
+func bindStatements(queries map[string]data.DatastoreQuery, values []string) ([]cachedStatement, bool) {
+	index := make(map[string]int, len(values))
+	for i, value := range values {
+		if _, duplicate := index[value]; duplicate {
+			return nil, false
+		}
+		index[value] = i
+	}
+
+	bound := make(map[int]bool, len(values))
+	statements := make([]cachedStatement, 0, len(queries))
+	for datastore, query := range queries {
+		bindings := make([]int, len(query.Parameters))
+		for i, param := range query.Parameters {
+			value, ok := param.(string)
+			if !ok {
+				return nil, false
+			}
+			position, ok := index[value]
+			if !ok || bound[position] {
+				return nil, false
+			}
+			bound[position] = true
+			bindings[i] = position
+		}
+		statements = append(statements, cachedStatement{datastore: datastore, statement: query.Statement, bindings: bindings})
+	}
+
+	if len(bound) != len(values) {
+		return nil, false
+	}
+	return statements, true
+}
+
+// bind creates the datastore query of the cached statement with the constants of the current residual query
+func (s cachedStatement) bind(values []string) data.DatastoreQuery {
+	params := make([]any, len(s.bindings))
+	for i, position := range s.bindings {
+		params[i] = values[position]
+	}
+	return data.DatastoreQuery{Statement: s.statement, Parameters: params}
+}
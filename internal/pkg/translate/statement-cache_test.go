@@ -0,0 +1,65 @@
+package translate
+
+import (
+	"testing"
+
+	"github.com/open-policy-agent/opa/v1/ast"
+	"github.com/stretchr/testify/assert"
+	"github.com/unbasical/kelon/pkg/data"
+)
+
+func Test_residualShape_IgnoresConstantValues(t *testing.T) {
+	first, firstValues := residualShape([]ast.Body{ast.MustParseBody(`data.pg.users[x].name = "alice"; data.pg.users[x].age > 18`)})
+	second, secondValues := residualShape([]ast.Body{ast.MustParseBody(`data.pg.users[x].name = "bob"; data.pg.users[x].age > 21`)})
+
+	assert.Equal(t, first, second)
+	assert.Equal(t, []string{"alice", "18"}, firstValues)
+	assert.Equal(t, []string{"bob", "21"}, secondValues)
+}
+
+func Test_residualShape_KeepsConstantKind(t *testing.T) {
+	first, _ := residualShape([]ast.Body{ast.MustParseBody(`data.pg.users[x].age > 18`)})
+	second, _ := residualShape([]ast.Body{ast.MustParseBody(`data.pg.users[x].age > 18.5`)})
+
+	assert.NotEqual(t, first, second)
+}
+
+func Test_bindStatements(t *testing.T) {
+	statements, ok := bindStatements(map[string]data.DatastoreQuery{
+		"pg": {Statement: "SELECT 1 WHERE age > $1 AND name = $2", Parameters: []any{"18", "alice"}},
+	}, []string{"alice", "18"})
+	assert.True(t, ok)
+	assert.Equal(t, []any{"21", "bob"}, statements[0].bind([]string{"bob", "21"}).Parameters)
+}
+
+func Test_bindStatements_RejectsInlinedConstants(t *testing.T) {
+	_, ok := bindStatements(map[string]data.DatastoreQuery{
+		"mongo": {Statement: map[string]string{"users": `{"name": "alice"}`}},
+	}, []string{"alice"})
+	assert.False(t, ok)
+
+	// Duplicate values can not be bound unambiguously
+	_, ok = bindStatements(map[string]data.DatastoreQuery{
+		"pg": {Statement: "SELECT 1 WHERE a = $1 AND b = $2", Parameters: []any{"1", "1"}},
+	}, []string{"1", "1"})
+	assert.False(t, ok)
+}
+
+func Test_statementCache_Invalidate(t *testing.T) {
+	cache := newStatementCache(10)
+	statements := []cachedStatement{{datastore: "pg", statement: "SELECT count(*) FROM users WHERE name = $1", bindings: []int{0}}}
+
+	cache.put("key", cache.generation.Load(), statements)
+	cached, hit := cache.get("key")
+	assert.True(t, hit)
+	assert.Equal(t, statements, cached)
+
+	// Statements which were translated before the invalidation are dropped
+	generation := cache.generation.Load()
+	cache.invalidate()
+	_, hit = cache.get("key")
+	assert.False(t, hit)
+	cache.put("key", generation, statements)
+	_, hit = cache.get("key")
+	assert.False(t, hit)
+}
@@ -0,0 +1,94 @@
+package util
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// LRUCache is a thread-safe least-recently-used cache with generics, whose entries expire after a fixed TTL.
+// A TTL of 0 disables the expiration of entries.
+type LRUCache[K comparable, V any] struct {
+	mutex   sync.Mutex
+	maxSize int
+	ttl     time.Duration
+	order   *list.List
+	entries map[K]*list.Element
+}
+
+type lruEntry[K comparable, V any] struct {
+	key     K
+	value   V
+	expires time.Time
+}
+
+// NewLRUCache creates a new LRUCache holding at most maxSize entries for the duration of ttl
+func NewLRUCache[K comparable, V any](maxSize int, ttl time.Duration) *LRUCache[K, V] {
+	return &LRUCache[K, V]{
+		maxSize: maxSize,
+		ttl:     ttl,
+		order:   list.New(),
+		entries: make(map[K]*list.Element),
+	}
+}
+
+// Get returns the value stored for the key and true, or false if there is no value or the value expired
+func (c *LRUCache[K, V]) Get(key K) (V, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	var empty V
+	elem, ok := c.entries[key]
+	if !ok {
+		return empty, false
+	}
+
+	entry := elem.Value.(*lruEntry[K, V])
+	if c.ttl > 0 && time.Now().After(entry.expires) {
+		c.order.Remove(elem)
+		delete(c.entries, key)
+		return empty, false
+	}
+
+	c.order.MoveToFront(elem)
+	return entry.value, true
+}
+
+// Put stores the value for the key and evicts the least recently used entry if the cache is full
+func (c *LRUCache[K, V]) Put(key K, value V) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	expires := time.Now().Add(c.ttl)
+	if elem, ok := c.entries[key]; ok {
+		entry := elem.Value.(*lruEntry[K, V])
+		entry.value = value
+		entry.expires = expires
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(&lruEntry[K, V]{key: key, value: value, expires: expires})
+	for c.order.Len() > c.maxSize {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*lruEntry[K, V]).key)
+	}
+}
+
+// Clear drops all entries from the cache
+func (c *LRUCache[K, V]) Clear() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.order.Init()
+	c.entries = make(map[K]*list.Element)
+}
+
+// Size returns the amount of entries in the cache (including expired ones which were not accessed yet)
+func (c *LRUCache[K, V]) Size() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.order.Len()
+}
@@ -0,0 +1,59 @@
+package util
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLRUCache_PutGet(t *testing.T) {
+	c := NewLRUCache[string, int](2, time.Minute)
+	c.Put("a", 1)
+	v, ok := c.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, 1, v)
+}
+
+func TestLRUCache_GetMissing(t *testing.T) {
+	c := NewLRUCache[string, int](2, time.Minute)
+	_, ok := c.Get("a")
+	assert.False(t, ok)
+}
+
+func TestLRUCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	c := NewLRUCache[string, int](2, time.Minute)
+	c.Put("a", 1)
+	c.Put("b", 2)
+	_, _ = c.Get("a")
+	c.Put("c", 3)
+
+	_, ok := c.Get("b")
+	assert.False(t, ok)
+	_, ok = c.Get("a")
+	assert.True(t, ok)
+	_, ok = c.Get("c")
+	assert.True(t, ok)
+	assert.Equal(t, 2, c.Size())
+}
+
+func TestLRUCache_Expires(t *testing.T) {
+	c := NewLRUCache[string, int](2, time.Millisecond)
+	c.Put("a", 1)
+	time.Sleep(5 * time.Millisecond)
+
+	_, ok := c.Get("a")
+	assert.False(t, ok)
+	assert.Equal(t, 0, c.Size())
+}
+
+func TestLRUCache_Clear(t *testing.T) {
+	c := NewLRUCache[string, int](2, time.Minute)
+	c.Put("a", 1)
+	c.Put("b", 2)
+
+	c.Clear()
+	assert.Equal(t, 0, c.Size())
+	_, ok := c.Get("a")
+	assert.False(t, ok)
+}
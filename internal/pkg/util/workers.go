@@ -0,0 +1,24 @@
+package util
+
+import "sync"
+
+// RunWorkers calls work for each item in [0, items) using at most the given amount of concurrent workers
+// and returns as soon as all items are done. At least one worker is used.
+func RunWorkers(workers, items int, work func(item int)) {
+	queue := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < min(max(workers, 1), items); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for item := range queue {
+				work(item)
+			}
+		}()
+	}
+	for item := 0; item < items; item++ {
+		queue <- item
+	}
+	close(queue)
+	wg.Wait()
+}
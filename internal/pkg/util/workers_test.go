@@ -0,0 +1,37 @@
+package util
+
+import (
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunWorkers(t *testing.T) {
+	results := make([]int, 10)
+	RunWorkers(3, len(results), func(item int) {
+		results[item] = item * 2
+	})
+	assert.Equal(t, []int{0, 2, 4, 6, 8, 10, 12, 14, 16, 18}, results)
+}
+
+func TestRunWorkers_Bounded(t *testing.T) {
+	var running, maxRunning atomic.Int32
+	RunWorkers(2, 20, func(_ int) {
+		current := running.Add(1)
+		for {
+			seen := maxRunning.Load()
+			if current <= seen || maxRunning.CompareAndSwap(seen, current) {
+				break
+			}
+		}
+		running.Add(-1)
+	})
+	assert.LessOrEqual(t, maxRunning.Load(), int32(2))
+}
+
+func TestRunWorkers_NoItems(t *testing.T) {
+	RunWorkers(0, 0, func(_ int) {
+		t.Fail()
+	})
+}
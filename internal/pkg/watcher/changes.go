@@ -0,0 +1,70 @@
+package watcher
+
+import (
+	"path/filepath"
+	"sync"
+
+	"github.com/unbasical/kelon/configs"
+	"github.com/unbasical/kelon/pkg/constants/logging"
+	"github.com/unbasical/kelon/pkg/watcher"
+)
+
+// changeSet collects changed files until the observers are notified about all of them at once
+type changeSet struct {
+	change watcher.ChangeType
+	paths  []string
+}
+
+// add records the change of the file at the path
+func (c *changeSet) add(path string) {
+	c.change |= changeTypeOf(path)
+	for _, p := range c.paths {
+		if p == path {
+			return
+		}
+	}
+	c.paths = append(c.paths, path)
+}
+
+// empty returns true if no file changed
+func (c *changeSet) empty() bool {
+	return len(c.paths) == 0
+}
+
+// observerList holds the callbacks of a watcher, which may be registered while the watcher notifies them
+type observerList struct {
+	mutex     sync.Mutex
+	observers []func(watcher.ChangeType, []string, *configs.ExternalConfig, error)
+}
+
+// add registers the callback
+func (o *observerList) add(callback func(watcher.ChangeType, []string, *configs.ExternalConfig, error)) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	o.observers = append(o.observers, callback)
+}
+
+// notify loads the configuration once and passes it to all observers together with the changes
+func (o *observerList) notify(component string, loader configs.ConfigLoader, changes *changeSet) {
+	logging.LogForComponent(component).Infof("Update observers due to %s change of files %q", changes.change, changes.paths)
+
+	loaded, err := loader.Load()
+	o.mutex.Lock()
+	observers := o.observers
+	o.mutex.Unlock()
+	for _, observer := range observers {
+		observer(changes.change, changes.paths, loaded, err)
+	}
+}
+
+// changeTypeOf maps the extension of a changed file to its change type
+func changeTypeOf(path string) watcher.ChangeType {
+	switch filepath.Ext(path) {
+	case ".rego":
+		return watcher.ChangeRego
+	case ".yml", ".yaml":
+		return watcher.ChangeConf
+	default:
+		return watcher.ChangeUnknown
+	}
+}
@@ -4,20 +4,23 @@ import (
 	"os"
 	"os/signal"
 	"path/filepath"
+	"sync/atomic"
 	"syscall"
+	"time"
 
 	"github.com/fsnotify/fsnotify"
-	log "github.com/sirupsen/logrus"
 	"github.com/unbasical/kelon/configs"
 	"github.com/unbasical/kelon/pkg/constants/logging"
 	"github.com/unbasical/kelon/pkg/watcher"
 )
 
 // NewFileWatcher instantiates a new watcher.ConfigWatcher by loading local files.
-func NewFileWatcher(loader configs.ConfigLoader, watchPath string) watcher.ConfigWatcher {
+// Observers are notified once all changes within the debounce window have been collected.
+func NewFileWatcher(loader configs.ConfigLoader, debounce time.Duration, watchPaths ...string) watcher.ConfigWatcher {
 	newWatcher := fileConfigWatcher{
-		loader:    loader,
-		watchPath: watchPath,
+		loader:     loader,
+		watchPaths: watchPaths,
+		debounce:   debounce,
 	}
 
 	newWatcher.watchForChanges()
@@ -25,9 +28,11 @@ func NewFileWatcher(loader configs.ConfigLoader, watchPath string) watcher.Confi
 }
 
 type fileConfigWatcher struct {
-	loader    configs.ConfigLoader
-	watchPath string
-	observers []func(watcher.ChangeType, *configs.ExternalConfig, error)
+	loader     configs.ConfigLoader
+	watchPaths []string
+	debounce   time.Duration
+	observers  observerList
+	alive      atomic.Bool
 }
 
 func (w *fileConfigWatcher) watchForChanges() {
@@ -36,14 +41,22 @@ func (w *fileConfigWatcher) watchForChanges() {
 		logging.LogForComponent("fileConfigWatcher").Fatal(err)
 	}
 
+	w.alive.Store(true)
 	go w.watchRoutine(fileWatcher)
 	addWatchDirsRecursive(w, fileWatcher)
 	go closeWatcherOnSIGTERM(fileWatcher)
 }
 
-// watchRoutine reacts to file change events and triggers observer
+// watchRoutine collects file change events and triggers observers after no further change happened within the debounce window
 // nolint:revive
 func (w *fileConfigWatcher) watchRoutine(fileWatcher *fsnotify.Watcher) {
+	defer w.alive.Store(false)
+
+	var changes changeSet
+	debounce := time.NewTimer(w.debounce)
+	debounce.Stop()
+	defer debounce.Stop()
+
 	for {
 		select {
 		case event, ok := <-fileWatcher.Events:
@@ -52,15 +65,16 @@ func (w *fileConfigWatcher) watchRoutine(fileWatcher *fsnotify.Watcher) {
 				return
 			}
 
-			// Notify observers if a file was created, modified or deleted
+			// Collect the change if a file was created, modified or deleted
 			if isRelevantEvent(event) {
-				change := extractChangeType(event)
-
-				// Notify observers for changes
-				loaded, err := w.loader.Load()
-				for _, observer := range w.observers {
-					observer(change, loaded, err)
-				}
+				logging.LogForComponent("fileConfigWatcher").Debugf("Received change event: %s", event)
+				changes.add(event.Name)
+				debounce.Reset(w.debounce)
+			}
+		case <-debounce.C:
+			if !changes.empty() {
+				w.observers.notify("fileConfigWatcher", w.loader, &changes)
+				changes = changeSet{}
 			}
 		case err, ok := <-fileWatcher.Errors:
 			if !ok {
@@ -71,45 +85,28 @@ func (w *fileConfigWatcher) watchRoutine(fileWatcher *fsnotify.Watcher) {
 	}
 }
 
-// isRelevantEvent returns true if a file was created, modified or deleted
+// isRelevantEvent returns true if a file was created, modified, deleted or renamed
 func isRelevantEvent(event fsnotify.Event) bool {
 	// Gather information about event
 	writeEvent := event.Op&fsnotify.Write == fsnotify.Write
 	createEvent := event.Op&fsnotify.Create == fsnotify.Create
 	removeEvent := event.Op&fsnotify.Remove == fsnotify.Remove
-	isFile := false
+	renameEvent := event.Op&fsnotify.Rename == fsnotify.Rename
 
-	// Check if current modified file is File
-	if !removeEvent {
-		if fileInfo, err := os.Stat(event.Name); err == nil {
-			isFile = !fileInfo.IsDir()
-		} else {
-			logging.LogForComponent("fileConfigWatcher").Warnf("Unable to get information about file %q", event.Name)
-		}
+	// Deleted or renamed files can not be inspected anymore, their extension decides whether they are relevant
+	if removeEvent || renameEvent {
+		return filepath.Ext(event.Name) != ""
 	}
 
-	return isFile && (createEvent || writeEvent || removeEvent)
-}
-
-// extractChangeType maps file system changes to internal change types in order to ease observer trigger filter
-func extractChangeType(event fsnotify.Event) watcher.ChangeType {
-	var change watcher.ChangeType
-	extension := filepath.Ext(event.Name)
-	switch extension {
-	case ".rego":
-		change = watcher.ChangeRego
-		log.Println("FileConfigWatcher: update observers due to REGO change: ", event)
-	case ".yml":
-		change = watcher.ChangeConf
-		log.Println("FileConfigWatcher: update observers due to CONF change: ", event)
-	case ".yaml":
-		change = watcher.ChangeConf
-		log.Println("FileConfigWatcher: update observers due to CONF change: ", event)
-	default:
-		change = watcher.ChangeUnknown
-		log.Println("FileConfigWatcher: update observers due to UNKNOWN change: ", event)
+	isFile := false
+	// Check if current modified file is File
+	if fileInfo, err := os.Stat(event.Name); err == nil {
+		isFile = !fileInfo.IsDir()
+	} else {
+		logging.LogForComponent("fileConfigWatcher").Warnf("Unable to get information about file %q", event.Name)
 	}
-	return change
+
+	return isFile && (createEvent || writeEvent)
 }
 
 // closeWatcherOnSIGTERM watches OS signals and channels and closes the watcher on termination signals
@@ -128,9 +125,16 @@ func closeWatcherOnSIGTERM(fileWatcher *fsnotify.Watcher) {
 	}
 }
 
-// addWatchDirsRecursive adds directories recursively to the watch list
+// addWatchDirsRecursive adds the directories of all watch paths recursively to the watch list
 func addWatchDirsRecursive(configWatcher *fileConfigWatcher, fileWatcher *fsnotify.Watcher) {
-	err := filepath.Walk(configWatcher.watchPath,
+	for _, watchPath := range configWatcher.watchPaths {
+		addWatchDirRecursive(watchPath, fileWatcher)
+	}
+}
+
+// addWatchDirRecursive adds directories recursively to the watch list
+func addWatchDirRecursive(watchPath string, fileWatcher *fsnotify.Watcher) {
+	err := filepath.Walk(watchPath,
 		func(path string, info os.FileInfo, err error) error {
 			if err != nil {
 				return err
@@ -151,8 +155,13 @@ func addWatchDirsRecursive(configWatcher *fileConfigWatcher, fileWatcher *fsnoti
 }
 
 // Watch - see watcher.ConfigWatcher
-func (w *fileConfigWatcher) Watch(callback func(watcher.ChangeType, *configs.ExternalConfig, error)) {
-	w.observers = append(w.observers, callback)
+func (w *fileConfigWatcher) Watch(callback func(watcher.ChangeType, []string, *configs.ExternalConfig, error)) {
+	w.observers.add(callback)
 	loaded, err := w.loader.Load()
-	callback(watcher.ChangeAll, loaded, err)
+	callback(watcher.ChangeAll, nil, loaded, err)
+}
+
+// Alive - see watcher.LivenessReporter
+func (w *fileConfigWatcher) Alive() bool {
+	return w.alive.Load()
 }
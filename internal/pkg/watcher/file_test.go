@@ -0,0 +1,54 @@
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/unbasical/kelon/configs"
+	"github.com/unbasical/kelon/pkg/watcher"
+)
+
+type countingLoader struct {
+	loads atomic.Int32
+}
+
+func (l *countingLoader) Load() (*configs.ExternalConfig, error) {
+	l.loads.Add(1)
+	return &configs.ExternalConfig{}, nil
+}
+
+func Test_FileWatcher_DebouncesChanges(t *testing.T) {
+	dir := t.TempDir()
+	loader := &countingLoader{}
+	changes := make(chan watcher.ChangeType, 10)
+	paths := make(chan []string, 10)
+
+	fileWatcher := NewFileWatcher(loader, 300*time.Millisecond, dir)
+	fileWatcher.Watch(func(change watcher.ChangeType, changed []string, _ *configs.ExternalConfig, _ error) {
+		if change != watcher.ChangeAll {
+			changes <- change
+			paths <- changed
+		}
+	})
+
+	for _, name := range []string{"a.rego", "b.rego", "kelon.yml"} {
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte("content"), 0o600))
+	}
+
+	select {
+	case change := <-changes:
+		assert.Equal(t, watcher.ChangeRego|watcher.ChangeConf, change)
+		assert.ElementsMatch(t, []string{filepath.Join(dir, "a.rego"), filepath.Join(dir, "b.rego"), filepath.Join(dir, "kelon.yml")}, <-paths)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Observers were not notified")
+	}
+
+	// All changes are applied with a single load
+	time.Sleep(500 * time.Millisecond)
+	assert.Empty(t, changes)
+	assert.Equal(t, int32(2), loader.loads.Load())
+}
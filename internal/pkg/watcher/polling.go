@@ -0,0 +1,179 @@
+package watcher
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/fs"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/unbasical/kelon/configs"
+	"github.com/unbasical/kelon/pkg/constants/logging"
+	"github.com/unbasical/kelon/pkg/watcher"
+)
+
+// NewPollingWatcher instantiates a new watcher.ConfigWatcher, which compares the content hashes of all files inside the
+// watched paths on every interval. In contrast to the file watcher it also notices changes which are applied by swapping
+// symlinks, as Kubernetes does for mounted ConfigMaps.
+//
+// If the interval is not greater than 0, an error is returned.
+func NewPollingWatcher(loader configs.ConfigLoader, interval time.Duration, watchPaths ...string) (watcher.ConfigWatcher, error) {
+	if interval <= 0 {
+		return nil, errors.Errorf("PollingConfigWatcher: Poll interval must be greater than 0, but was %s", interval)
+	}
+
+	newWatcher := pollingConfigWatcher{
+		loader:     loader,
+		watchPaths: watchPaths,
+		interval:   interval,
+		stop:       make(chan struct{}),
+	}
+
+	newWatcher.watchForChanges()
+	return &newWatcher, nil
+}
+
+type pollingConfigWatcher struct {
+	loader     configs.ConfigLoader
+	watchPaths []string
+	interval   time.Duration
+	hashes     map[string]string
+	observers  observerList
+	stop       chan struct{}
+	alive      atomic.Bool
+}
+
+func (w *pollingConfigWatcher) watchForChanges() {
+	for _, path := range w.watchPaths {
+		logging.LogForComponent("pollingConfigWatcher").Infof("Start polling path %q every %s", path, w.interval)
+	}
+	w.hashes = hashFiles(w.watchPaths)
+
+	w.alive.Store(true)
+	go w.watchRoutine()
+	go w.stopOnSIGTERM()
+}
+
+// watchRoutine polls the watched paths and triggers observers for all changed files
+func (w *pollingConfigWatcher) watchRoutine() {
+	defer w.alive.Store(false)
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			w.poll()
+		case <-w.stop:
+			return
+		}
+	}
+}
+
+// poll compares the current file hashes with the ones of the last poll and notifies the observers about all changes at once
+func (w *pollingConfigWatcher) poll() {
+	hashes := hashFiles(w.watchPaths)
+	changed := changedFiles(w.hashes, hashes)
+	w.hashes = hashes
+
+	var changes changeSet
+	for _, path := range changed {
+		changes.add(path)
+	}
+	if !changes.empty() {
+		w.observers.notify("pollingConfigWatcher", w.loader, &changes)
+	}
+}
+
+// stopOnSIGTERM watches OS signals and stops polling on termination signals
+func (w *pollingConfigWatcher) stopOnSIGTERM() {
+	interruptChan := make(chan os.Signal, 1)
+	signal.Notify(interruptChan, os.Interrupt, syscall.SIGINT, syscall.SIGTERM, syscall.SIGUSR1, syscall.SIGUSR2)
+
+	// Block until we receive our signal.
+	<-interruptChan
+
+	logging.LogForComponent("pollingConfigWatcher").Infoln("Closing...")
+	close(w.stop)
+}
+
+// Watch - see watcher.ConfigWatcher
+func (w *pollingConfigWatcher) Watch(callback func(watcher.ChangeType, []string, *configs.ExternalConfig, error)) {
+	w.observers.add(callback)
+	loaded, err := w.loader.Load()
+	callback(watcher.ChangeAll, nil, loaded, err)
+}
+
+// Alive - see watcher.LivenessReporter
+func (w *pollingConfigWatcher) Alive() bool {
+	return w.alive.Load()
+}
+
+// hashFiles returns the sha256 hashes of all files inside the paths, following symlinks to their targets.
+// Hidden '..' entries are skipped, because Kubernetes uses them as targets of the atomically swapped symlinks.
+func hashFiles(watchPaths []string) map[string]string {
+	hashes := make(map[string]string)
+	for _, watchPath := range watchPaths {
+		root, err := filepath.EvalSymlinks(watchPath)
+		if err != nil {
+			logging.LogForComponent("pollingConfigWatcher").Warnf("Unable to resolve path %q: %s", watchPath, err)
+			continue
+		}
+
+		err = filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if path != root && strings.HasPrefix(entry.Name(), "..") {
+				if entry.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+
+			// Stat follows symlinks, which may point to directories
+			info, err := os.Stat(path)
+			if err != nil || info.IsDir() {
+				return nil
+			}
+
+			content, err := os.ReadFile(path)
+			if err != nil {
+				logging.LogForComponent("pollingConfigWatcher").Warnf("Unable to read file %q", path)
+				return nil
+			}
+			sum := sha256.Sum256(content)
+			hashes[filepath.Join(watchPath, strings.TrimPrefix(path, root))] = hex.EncodeToString(sum[:])
+			return nil
+		})
+		if err != nil {
+			logging.LogForComponent("pollingConfigWatcher").WithError(err).Error("Error during filepath walk")
+		}
+	}
+	return hashes
+}
+
+// changedFiles returns the sorted paths of all files which were created, modified or deleted
+func changedFiles(previous, current map[string]string) []string {
+	var changed []string
+	for path, hash := range current {
+		if previous[path] != hash {
+			changed = append(changed, path)
+		}
+	}
+	for path := range previous {
+		if _, ok := current[path]; !ok {
+			changed = append(changed, path)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
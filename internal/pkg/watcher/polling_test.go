@@ -0,0 +1,45 @@
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/unbasical/kelon/configs"
+)
+
+// writeConfigMapRevision lays out a file like Kubernetes does when mounting a ConfigMap
+func writeConfigMapRevision(t *testing.T, dir, revision, content string) {
+	t.Helper()
+	assert.NoError(t, os.Mkdir(filepath.Join(dir, revision), 0o700))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, revision, "policy.rego"), []byte(content), 0o600))
+
+	// Swap the ..data symlink atomically
+	assert.NoError(t, os.Symlink(revision, filepath.Join(dir, "..data_tmp")))
+	assert.NoError(t, os.Rename(filepath.Join(dir, "..data_tmp"), filepath.Join(dir, "..data")))
+}
+
+func Test_hashFiles_ConfigMapSymlinkSwap(t *testing.T) {
+	dir := t.TempDir()
+	writeConfigMapRevision(t, dir, "..2024_01_01", "package a")
+	assert.NoError(t, os.Symlink(filepath.Join("..data", "policy.rego"), filepath.Join(dir, "policy.rego")))
+
+	initial := hashFiles([]string{dir})
+	assert.Len(t, initial, 1)
+	assert.Empty(t, changedFiles(initial, hashFiles([]string{dir})))
+
+	writeConfigMapRevision(t, dir, "..2024_01_02", "package b")
+	assert.Equal(t, []string{filepath.Join(dir, "policy.rego")}, changedFiles(initial, hashFiles([]string{dir})))
+
+	assert.NoError(t, os.Remove(filepath.Join(dir, "policy.rego")))
+	assert.Equal(t, []string{filepath.Join(dir, "policy.rego")}, changedFiles(initial, hashFiles([]string{dir})))
+}
+
+func Test_NewPollingWatcher_InvalidInterval(t *testing.T) {
+	for _, interval := range []time.Duration{0, -time.Second} {
+		_, err := NewPollingWatcher(configs.FileConfigLoader{}, interval, t.TempDir())
+		assert.Error(t, err)
+	}
+}
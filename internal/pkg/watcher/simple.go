@@ -18,7 +18,7 @@ type simpleConfigWatcher struct {
 }
 
 // See pkg.watcher.ConfigWatcher
-func (w *simpleConfigWatcher) Watch(callback func(watcher.ChangeType, *configs.ExternalConfig, error)) {
+func (w *simpleConfigWatcher) Watch(callback func(watcher.ChangeType, []string, *configs.ExternalConfig, error)) {
 	loaded, err := w.loader.Load()
-	callback(watcher.ChangeAll, loaded, err)
+	callback(watcher.ChangeAll, nil, loaded, err)
 }
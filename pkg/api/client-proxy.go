@@ -19,6 +19,14 @@ import (
 type ClientProxyConfig struct {
 	Compiler *opa.PolicyCompiler
 	opa.PolicyCompilerConfig
+	// OPACompatibleResponses makes the Data-API answer each decision with status 200 and OPA's response body
+	// ({"result": {"allow": ..., "verify": ...}, "decision_id": ...}), so that OPA's SDKs can be used with Kelon.
+	// Without it, the response body can still be requested per request via the Accept header (see constants.MediaTypeOPAResponse).
+	OPACompatibleResponses bool
+	// BatchWorkers is the amount of inputs of a batch, which are evaluated concurrently
+	BatchWorkers int
+	// BatchMaxSize is the maximum amount of inputs of a batch, 0 disables the limit
+	BatchMaxSize int
 }
 
 // ClientProxy is the interface that serves as the external interface of kelon.
@@ -48,3 +56,14 @@ type ClientProxy interface {
 	// If the ClientProxy was not started before, or any error occurred during shutdown, an error will be returned (otherwise nil).
 	Stop(deadline time.Duration) error
 }
+
+// ReloadableClientProxy is implemented by ClientProxies which are able to swap their configuration at runtime.
+type ReloadableClientProxy interface {
+
+	// Reload() replaces the configuration of the ClientProxy, requests which are already running keep using the previous one.
+	// Please note that the opa.PolicyCompiler is shared between ClientProxies and therefore has to be reloaded beforehand,
+	// but its opa.PendingReload is committed afterwards.
+	//
+	// If the ClientProxy was not configured before, an error will be returned (otherwise nil).
+	Reload(appConf *configs.AppConfig, serverConf *ClientProxyConfig) error
+}
@@ -0,0 +1,691 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.6
+// 	protoc        (unknown)
+// source: pkg/api/proto/kelon/v1/kelon.proto
+
+package kelonv1
+
+import (
+	status "google.golang.org/genproto/googleapis/rpc/status"
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	structpb "google.golang.org/protobuf/types/known/structpb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type DecideRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Input of the decision, the same as the input of the Data-API (i.e. method, path, token).
+	Input         *structpb.Struct `protobuf:"bytes,1,opt,name=input,proto3" json:"input,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DecideRequest) Reset() {
+	*x = DecideRequest{}
+	mi := &file_pkg_api_proto_kelon_v1_kelon_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DecideRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DecideRequest) ProtoMessage() {}
+
+func (x *DecideRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_api_proto_kelon_v1_kelon_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DecideRequest.ProtoReflect.Descriptor instead.
+func (*DecideRequest) Descriptor() ([]byte, []int) {
+	return file_pkg_api_proto_kelon_v1_kelon_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *DecideRequest) GetInput() *structpb.Struct {
+	if x != nil {
+		return x.Input
+	}
+	return nil
+}
+
+type DecideResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Decision      *Decision              `protobuf:"bytes,1,opt,name=decision,proto3" json:"decision,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DecideResponse) Reset() {
+	*x = DecideResponse{}
+	mi := &file_pkg_api_proto_kelon_v1_kelon_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DecideResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DecideResponse) ProtoMessage() {}
+
+func (x *DecideResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_api_proto_kelon_v1_kelon_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DecideResponse.ProtoReflect.Descriptor instead.
+func (*DecideResponse) Descriptor() ([]byte, []int) {
+	return file_pkg_api_proto_kelon_v1_kelon_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *DecideResponse) GetDecision() *Decision {
+	if x != nil {
+		return x.Decision
+	}
+	return nil
+}
+
+type Decision struct {
+	state      protoimpl.MessageState `protogen:"open.v1"`
+	DecisionId string                 `protobuf:"bytes,1,opt,name=decision_id,json=decisionId,proto3" json:"decision_id,omitempty"`
+	Allow      bool                   `protobuf:"varint,2,opt,name=allow,proto3" json:"allow,omitempty"`
+	Verify     bool                   `protobuf:"varint,3,opt,name=verify,proto3" json:"verify,omitempty"`
+	// Headers, reason and obligations defined by the policy.
+	Headers       map[string]string `protobuf:"bytes,4,rep,name=headers,proto3" json:"headers,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Reason        string            `protobuf:"bytes,5,opt,name=reason,proto3" json:"reason,omitempty"`
+	Obligations   *structpb.Value   `protobuf:"bytes,6,opt,name=obligations,proto3" json:"obligations,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Decision) Reset() {
+	*x = Decision{}
+	mi := &file_pkg_api_proto_kelon_v1_kelon_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Decision) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Decision) ProtoMessage() {}
+
+func (x *Decision) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_api_proto_kelon_v1_kelon_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Decision.ProtoReflect.Descriptor instead.
+func (*Decision) Descriptor() ([]byte, []int) {
+	return file_pkg_api_proto_kelon_v1_kelon_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Decision) GetDecisionId() string {
+	if x != nil {
+		return x.DecisionId
+	}
+	return ""
+}
+
+func (x *Decision) GetAllow() bool {
+	if x != nil {
+		return x.Allow
+	}
+	return false
+}
+
+func (x *Decision) GetVerify() bool {
+	if x != nil {
+		return x.Verify
+	}
+	return false
+}
+
+func (x *Decision) GetHeaders() map[string]string {
+	if x != nil {
+		return x.Headers
+	}
+	return nil
+}
+
+func (x *Decision) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+func (x *Decision) GetObligations() *structpb.Value {
+	if x != nil {
+		return x.Obligations
+	}
+	return nil
+}
+
+type BatchDecideRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Inputs        []*structpb.Struct     `protobuf:"bytes,1,rep,name=inputs,proto3" json:"inputs,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BatchDecideRequest) Reset() {
+	*x = BatchDecideRequest{}
+	mi := &file_pkg_api_proto_kelon_v1_kelon_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BatchDecideRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BatchDecideRequest) ProtoMessage() {}
+
+func (x *BatchDecideRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_api_proto_kelon_v1_kelon_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BatchDecideRequest.ProtoReflect.Descriptor instead.
+func (*BatchDecideRequest) Descriptor() ([]byte, []int) {
+	return file_pkg_api_proto_kelon_v1_kelon_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *BatchDecideRequest) GetInputs() []*structpb.Struct {
+	if x != nil {
+		return x.Inputs
+	}
+	return nil
+}
+
+type BatchDecideResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Results in the same order as the inputs of the request.
+	Results       []*BatchDecideResult `protobuf:"bytes,1,rep,name=results,proto3" json:"results,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BatchDecideResponse) Reset() {
+	*x = BatchDecideResponse{}
+	mi := &file_pkg_api_proto_kelon_v1_kelon_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BatchDecideResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BatchDecideResponse) ProtoMessage() {}
+
+func (x *BatchDecideResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_api_proto_kelon_v1_kelon_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BatchDecideResponse.ProtoReflect.Descriptor instead.
+func (*BatchDecideResponse) Descriptor() ([]byte, []int) {
+	return file_pkg_api_proto_kelon_v1_kelon_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *BatchDecideResponse) GetResults() []*BatchDecideResult {
+	if x != nil {
+		return x.Results
+	}
+	return nil
+}
+
+type BatchDecideResult struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Result:
+	//
+	//	*BatchDecideResult_Decision
+	//	*BatchDecideResult_Error
+	Result        isBatchDecideResult_Result `protobuf_oneof:"result"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BatchDecideResult) Reset() {
+	*x = BatchDecideResult{}
+	mi := &file_pkg_api_proto_kelon_v1_kelon_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BatchDecideResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BatchDecideResult) ProtoMessage() {}
+
+func (x *BatchDecideResult) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_api_proto_kelon_v1_kelon_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BatchDecideResult.ProtoReflect.Descriptor instead.
+func (*BatchDecideResult) Descriptor() ([]byte, []int) {
+	return file_pkg_api_proto_kelon_v1_kelon_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *BatchDecideResult) GetResult() isBatchDecideResult_Result {
+	if x != nil {
+		return x.Result
+	}
+	return nil
+}
+
+func (x *BatchDecideResult) GetDecision() *Decision {
+	if x != nil {
+		if x, ok := x.Result.(*BatchDecideResult_Decision); ok {
+			return x.Decision
+		}
+	}
+	return nil
+}
+
+func (x *BatchDecideResult) GetError() *status.Status {
+	if x != nil {
+		if x, ok := x.Result.(*BatchDecideResult_Error); ok {
+			return x.Error
+		}
+	}
+	return nil
+}
+
+type isBatchDecideResult_Result interface {
+	isBatchDecideResult_Result()
+}
+
+type BatchDecideResult_Decision struct {
+	Decision *Decision `protobuf:"bytes,1,opt,name=decision,proto3,oneof"`
+}
+
+type BatchDecideResult_Error struct {
+	Error *status.Status `protobuf:"bytes,2,opt,name=error,proto3,oneof"`
+}
+
+func (*BatchDecideResult_Decision) isBatchDecideResult_Result() {}
+
+func (*BatchDecideResult_Error) isBatchDecideResult_Result() {}
+
+type FilterRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Input shared by all items (i.e. method and token).
+	Input *structpb.Struct `protobuf:"bytes,1,opt,name=input,proto3" json:"input,omitempty"`
+	// Items are merged into the input one by one (i.e. the path of each resource).
+	Items         []*structpb.Struct `protobuf:"bytes,2,rep,name=items,proto3" json:"items,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FilterRequest) Reset() {
+	*x = FilterRequest{}
+	mi := &file_pkg_api_proto_kelon_v1_kelon_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FilterRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FilterRequest) ProtoMessage() {}
+
+func (x *FilterRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_api_proto_kelon_v1_kelon_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FilterRequest.ProtoReflect.Descriptor instead.
+func (*FilterRequest) Descriptor() ([]byte, []int) {
+	return file_pkg_api_proto_kelon_v1_kelon_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *FilterRequest) GetInput() *structpb.Struct {
+	if x != nil {
+		return x.Input
+	}
+	return nil
+}
+
+func (x *FilterRequest) GetItems() []*structpb.Struct {
+	if x != nil {
+		return x.Items
+	}
+	return nil
+}
+
+type FilterResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Indices of the allowed items in ascending order.
+	Allowed       []uint32 `protobuf:"varint,1,rep,packed,name=allowed,proto3" json:"allowed,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FilterResponse) Reset() {
+	*x = FilterResponse{}
+	mi := &file_pkg_api_proto_kelon_v1_kelon_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FilterResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FilterResponse) ProtoMessage() {}
+
+func (x *FilterResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_api_proto_kelon_v1_kelon_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FilterResponse.ProtoReflect.Descriptor instead.
+func (*FilterResponse) Descriptor() ([]byte, []int) {
+	return file_pkg_api_proto_kelon_v1_kelon_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *FilterResponse) GetAllowed() []uint32 {
+	if x != nil {
+		return x.Allowed
+	}
+	return nil
+}
+
+type HealthRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *HealthRequest) Reset() {
+	*x = HealthRequest{}
+	mi := &file_pkg_api_proto_kelon_v1_kelon_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *HealthRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HealthRequest) ProtoMessage() {}
+
+func (x *HealthRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_api_proto_kelon_v1_kelon_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HealthRequest.ProtoReflect.Descriptor instead.
+func (*HealthRequest) Descriptor() ([]byte, []int) {
+	return file_pkg_api_proto_kelon_v1_kelon_proto_rawDescGZIP(), []int{8}
+}
+
+type HealthResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// One of healthy, degraded or unhealthy.
+	Status string `protobuf:"bytes,1,opt,name=status,proto3" json:"status,omitempty"`
+	// Health of each datastore by alias.
+	Datastores    map[string]string `protobuf:"bytes,2,rep,name=datastores,proto3" json:"datastores,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *HealthResponse) Reset() {
+	*x = HealthResponse{}
+	mi := &file_pkg_api_proto_kelon_v1_kelon_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *HealthResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HealthResponse) ProtoMessage() {}
+
+func (x *HealthResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_api_proto_kelon_v1_kelon_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HealthResponse.ProtoReflect.Descriptor instead.
+func (*HealthResponse) Descriptor() ([]byte, []int) {
+	return file_pkg_api_proto_kelon_v1_kelon_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *HealthResponse) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *HealthResponse) GetDatastores() map[string]string {
+	if x != nil {
+		return x.Datastores
+	}
+	return nil
+}
+
+var File_pkg_api_proto_kelon_v1_kelon_proto protoreflect.FileDescriptor
+
+const file_pkg_api_proto_kelon_v1_kelon_proto_rawDesc = "" +
+	"\n" +
+	"\"pkg/api/proto/kelon/v1/kelon.proto\x12\bkelon.v1\x1a\x1cgoogle/protobuf/struct.proto\x1a\x17google/rpc/status.proto\">\n" +
+	"\rDecideRequest\x12-\n" +
+	"\x05input\x18\x01 \x01(\v2\x17.google.protobuf.StructR\x05input\"@\n" +
+	"\x0eDecideResponse\x12.\n" +
+	"\bdecision\x18\x01 \x01(\v2\x12.kelon.v1.DecisionR\bdecision\"\xa2\x02\n" +
+	"\bDecision\x12\x1f\n" +
+	"\vdecision_id\x18\x01 \x01(\tR\n" +
+	"decisionId\x12\x14\n" +
+	"\x05allow\x18\x02 \x01(\bR\x05allow\x12\x16\n" +
+	"\x06verify\x18\x03 \x01(\bR\x06verify\x129\n" +
+	"\aheaders\x18\x04 \x03(\v2\x1f.kelon.v1.Decision.HeadersEntryR\aheaders\x12\x16\n" +
+	"\x06reason\x18\x05 \x01(\tR\x06reason\x128\n" +
+	"\vobligations\x18\x06 \x01(\v2\x16.google.protobuf.ValueR\vobligations\x1a:\n" +
+	"\fHeadersEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"E\n" +
+	"\x12BatchDecideRequest\x12/\n" +
+	"\x06inputs\x18\x01 \x03(\v2\x17.google.protobuf.StructR\x06inputs\"L\n" +
+	"\x13BatchDecideResponse\x125\n" +
+	"\aresults\x18\x01 \x03(\v2\x1b.kelon.v1.BatchDecideResultR\aresults\"{\n" +
+	"\x11BatchDecideResult\x120\n" +
+	"\bdecision\x18\x01 \x01(\v2\x12.kelon.v1.DecisionH\x00R\bdecision\x12*\n" +
+	"\x05error\x18\x02 \x01(\v2\x12.google.rpc.StatusH\x00R\x05errorB\b\n" +
+	"\x06result\"m\n" +
+	"\rFilterRequest\x12-\n" +
+	"\x05input\x18\x01 \x01(\v2\x17.google.protobuf.StructR\x05input\x12-\n" +
+	"\x05items\x18\x02 \x03(\v2\x17.google.protobuf.StructR\x05items\"*\n" +
+	"\x0eFilterResponse\x12\x18\n" +
+	"\aallowed\x18\x01 \x03(\rR\aallowed\"\x0f\n" +
+	"\rHealthRequest\"\xb1\x01\n" +
+	"\x0eHealthResponse\x12\x16\n" +
+	"\x06status\x18\x01 \x01(\tR\x06status\x12H\n" +
+	"\n" +
+	"datastores\x18\x02 \x03(\v2(.kelon.v1.HealthResponse.DatastoresEntryR\n" +
+	"datastores\x1a=\n" +
+	"\x0fDatastoresEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x012\x94\x02\n" +
+	"\x0fDecisionService\x12;\n" +
+	"\x06Decide\x12\x17.kelon.v1.DecideRequest\x1a\x18.kelon.v1.DecideResponse\x12J\n" +
+	"\vBatchDecide\x12\x1c.kelon.v1.BatchDecideRequest\x1a\x1d.kelon.v1.BatchDecideResponse\x12;\n" +
+	"\x06Filter\x12\x17.kelon.v1.FilterRequest\x1a\x18.kelon.v1.FilterResponse\x12;\n" +
+	"\x06Health\x12\x17.kelon.v1.HealthRequest\x1a\x18.kelon.v1.HealthResponseB;Z9github.com/unbasical/kelon/pkg/api/proto/kelon/v1;kelonv1b\x06proto3"
+
+var (
+	file_pkg_api_proto_kelon_v1_kelon_proto_rawDescOnce sync.Once
+	file_pkg_api_proto_kelon_v1_kelon_proto_rawDescData []byte
+)
+
+func file_pkg_api_proto_kelon_v1_kelon_proto_rawDescGZIP() []byte {
+	file_pkg_api_proto_kelon_v1_kelon_proto_rawDescOnce.Do(func() {
+		file_pkg_api_proto_kelon_v1_kelon_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_pkg_api_proto_kelon_v1_kelon_proto_rawDesc), len(file_pkg_api_proto_kelon_v1_kelon_proto_rawDesc)))
+	})
+	return file_pkg_api_proto_kelon_v1_kelon_proto_rawDescData
+}
+
+var file_pkg_api_proto_kelon_v1_kelon_proto_msgTypes = make([]protoimpl.MessageInfo, 12)
+var file_pkg_api_proto_kelon_v1_kelon_proto_goTypes = []any{
+	(*DecideRequest)(nil),       // 0: kelon.v1.DecideRequest
+	(*DecideResponse)(nil),      // 1: kelon.v1.DecideResponse
+	(*Decision)(nil),            // 2: kelon.v1.Decision
+	(*BatchDecideRequest)(nil),  // 3: kelon.v1.BatchDecideRequest
+	(*BatchDecideResponse)(nil), // 4: kelon.v1.BatchDecideResponse
+	(*BatchDecideResult)(nil),   // 5: kelon.v1.BatchDecideResult
+	(*FilterRequest)(nil),       // 6: kelon.v1.FilterRequest
+	(*FilterResponse)(nil),      // 7: kelon.v1.FilterResponse
+	(*HealthRequest)(nil),       // 8: kelon.v1.HealthRequest
+	(*HealthResponse)(nil),      // 9: kelon.v1.HealthResponse
+	nil,                         // 10: kelon.v1.Decision.HeadersEntry
+	nil,                         // 11: kelon.v1.HealthResponse.DatastoresEntry
+	(*structpb.Struct)(nil),     // 12: google.protobuf.Struct
+	(*structpb.Value)(nil),      // 13: google.protobuf.Value
+	(*status.Status)(nil),       // 14: google.rpc.Status
+}
+var file_pkg_api_proto_kelon_v1_kelon_proto_depIdxs = []int32{
+	12, // 0: kelon.v1.DecideRequest.input:type_name -> google.protobuf.Struct
+	2,  // 1: kelon.v1.DecideResponse.decision:type_name -> kelon.v1.Decision
+	10, // 2: kelon.v1.Decision.headers:type_name -> kelon.v1.Decision.HeadersEntry
+	13, // 3: kelon.v1.Decision.obligations:type_name -> google.protobuf.Value
+	12, // 4: kelon.v1.BatchDecideRequest.inputs:type_name -> google.protobuf.Struct
+	5,  // 5: kelon.v1.BatchDecideResponse.results:type_name -> kelon.v1.BatchDecideResult
+	2,  // 6: kelon.v1.BatchDecideResult.decision:type_name -> kelon.v1.Decision
+	14, // 7: kelon.v1.BatchDecideResult.error:type_name -> google.rpc.Status
+	12, // 8: kelon.v1.FilterRequest.input:type_name -> google.protobuf.Struct
+	12, // 9: kelon.v1.FilterRequest.items:type_name -> google.protobuf.Struct
+	11, // 10: kelon.v1.HealthResponse.datastores:type_name -> kelon.v1.HealthResponse.DatastoresEntry
+	0,  // 11: kelon.v1.DecisionService.Decide:input_type -> kelon.v1.DecideRequest
+	3,  // 12: kelon.v1.DecisionService.BatchDecide:input_type -> kelon.v1.BatchDecideRequest
+	6,  // 13: kelon.v1.DecisionService.Filter:input_type -> kelon.v1.FilterRequest
+	8,  // 14: kelon.v1.DecisionService.Health:input_type -> kelon.v1.HealthRequest
+	1,  // 15: kelon.v1.DecisionService.Decide:output_type -> kelon.v1.DecideResponse
+	4,  // 16: kelon.v1.DecisionService.BatchDecide:output_type -> kelon.v1.BatchDecideResponse
+	7,  // 17: kelon.v1.DecisionService.Filter:output_type -> kelon.v1.FilterResponse
+	9,  // 18: kelon.v1.DecisionService.Health:output_type -> kelon.v1.HealthResponse
+	15, // [15:19] is the sub-list for method output_type
+	11, // [11:15] is the sub-list for method input_type
+	11, // [11:11] is the sub-list for extension type_name
+	11, // [11:11] is the sub-list for extension extendee
+	0,  // [0:11] is the sub-list for field type_name
+}
+
+func init() { file_pkg_api_proto_kelon_v1_kelon_proto_init() }
+func file_pkg_api_proto_kelon_v1_kelon_proto_init() {
+	if File_pkg_api_proto_kelon_v1_kelon_proto != nil {
+		return
+	}
+	file_pkg_api_proto_kelon_v1_kelon_proto_msgTypes[5].OneofWrappers = []any{
+		(*BatchDecideResult_Decision)(nil),
+		(*BatchDecideResult_Error)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_pkg_api_proto_kelon_v1_kelon_proto_rawDesc), len(file_pkg_api_proto_kelon_v1_kelon_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   12,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_pkg_api_proto_kelon_v1_kelon_proto_goTypes,
+		DependencyIndexes: file_pkg_api_proto_kelon_v1_kelon_proto_depIdxs,
+		MessageInfos:      file_pkg_api_proto_kelon_v1_kelon_proto_msgTypes,
+	}.Build()
+	File_pkg_api_proto_kelon_v1_kelon_proto = out.File
+	file_pkg_api_proto_kelon_v1_kelon_proto_goTypes = nil
+	file_pkg_api_proto_kelon_v1_kelon_proto_depIdxs = nil
+}
@@ -0,0 +1,42 @@
+// Package audit contains components that write an append-only audit trail of all access decisions.
+//
+// In contrast to the access decision logs, the audit trail is written independently of the application's log level.
+package audit
+
+import "time"
+
+// Decisions recorded in the audit trail
+const (
+	DecisionAllow = "ALLOW"
+	DecisionDeny  = "DENY"
+	DecisionError = "ERROR"
+)
+
+// Record is a single access decision in the audit trail.
+// PrevHash and Hash are only set if the Sink chains its records.
+type Record struct {
+	Timestamp  time.Time `json:"timestamp"`
+	DecisionID string    `json:"decision_id"`
+	Decision   string    `json:"decision"`
+	Reason     string    `json:"reason,omitempty"`
+	Package    string    `json:"package,omitempty"`
+	Method     string    `json:"method,omitempty"`
+	Path       string    `json:"path,omitempty"`
+	Revision   string    `json:"revision,omitempty"`
+	DurationMs float64   `json:"duration_ms"`
+	Cached     bool      `json:"cached,omitempty"`
+	Input      any       `json:"input,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	PrevHash   string    `json:"prev_hash,omitempty"`
+	Hash       string    `json:"hash,omitempty"`
+}
+
+// Sink is the interface that writes the audit trail.
+type Sink interface {
+
+	// Write appends the record to the audit trail. Configured fields are redacted before the record is written.
+	Write(record Record) error
+
+	// Close flushes and closes the audit trail. Records which are written afterwards are rejected.
+	Close() error
+}
@@ -6,20 +6,47 @@ type ContextKey string
 // ContextKeyRegoPackage is used to propagate the rego package via the context in order to enrich logs/spans
 const ContextKeyRegoPackage = ContextKey("regoPackage")
 
+// ContextKeyRegoRule is used to propagate the evaluated rego rule via the context
+const ContextKeyRegoRule = ContextKey("regoRule")
+
+// ContextKeyQueryRecorder is used to pass the recorder for executed datastore queries to the datastores
+const ContextKeyQueryRecorder = ContextKey("queryRecorder")
+
+// ContextKeyDecisionID is used to pass the ID of the decision, which was generated for the incoming request, to the PolicyCompiler
+const ContextKeyDecisionID = ContextKey("decisionId")
+
+// ContextKeySharedQueries is used to pass the queries, which are shared between the decisions of a batch, to the datastores
+const ContextKeySharedQueries = ContextKey("sharedQueries")
+
+// ContextKeyMetrics is used to pass the metrics, which are returned to the caller, to the PolicyCompiler
+const ContextKeyMetrics = ContextKey("metrics")
+
 // HTTP Request related constants
 const (
 	// Input is the attribute in a JSON request body, which contains the necessary data for policy evaluation
 	Input = "input"
 	// EndpointData is used for all data related http endpoints
 	EndpointData = "/data"
+	// EndpointBatchData is used to make multiple decisions with a single http request
+	EndpointBatchData = "/batch/data"
+	// EndpointForwardAuth is used by reverse proxies (i.e. Traefik, nginx and Caddy) to authorize forwarded requests
+	EndpointForwardAuth = "/forward-auth"
 	// EndpointPolicies is used for all policy related http endpoints
 	EndpointPolicies = "/policies"
 	// EndpointHealth is used as the http endpoint for liveliness probes
 	EndpointHealth = "/health"
+	// EndpointReady is used as the http endpoint for readiness probes
+	EndpointReady = "/ready"
+	// EndpointRevision is used as the http endpoint to query the revision of the loaded regos
+	EndpointRevision = "/revision"
 	// EndpointMetrics will be used if Kelon is configured to publish metrics using Prometheus
 	EndpointMetrics = "/metrics"
 	// URLParamID is the url parameter which will be used by http endpoints, which try to query/modify e.g. policies
 	URLParamID = "id"
+	// HeaderDecisionID is the response header which contains the ID of the decision made for the request
+	HeaderDecisionID = "X-Kelon-Decision-Id"
+	// MediaTypeOPAResponse requests OPA's response body for a single decision of the Data-API via the Accept header
+	MediaTypeOPAResponse = "application/vnd.kelon.opa+json"
 )
 
 // Datastore related constants
@@ -30,6 +57,16 @@ const (
 	MetaMaxIdleConnections string = "maxIdleConnections"
 	// MetaConnectionMaxLifetimeSeconds is the MetaKey for connectionMaxLifetimeSeconds
 	MetaConnectionMaxLifetimeSeconds string = "connectionMaxLifetimeSeconds"
+	// MetaRetryMaxAttempts is the MetaKey for the maximum amount of retries on transient datastore errors
+	MetaRetryMaxAttempts string = "retryMaxAttempts"
+	// MetaRetryBackoffMilliseconds is the MetaKey for the initial backoff between two retries, which doubles on each retry
+	MetaRetryBackoffMilliseconds string = "retryBackoffMilliseconds"
+	// MetaCircuitBreakerThreshold is the MetaKey for the amount of consecutive failures after which the circuit opens
+	MetaCircuitBreakerThreshold string = "circuitBreakerThreshold"
+	// MetaCircuitBreakerResetSeconds is the MetaKey for the time an open circuit waits before letting a trial request pass
+	MetaCircuitBreakerResetSeconds string = "circuitBreakerResetSeconds"
+	// MetaHealthCheckIntervalSeconds is the MetaKey for the interval in which all hosts of a datastore are pinged
+	MetaHealthCheckIntervalSeconds string = "healthCheckIntervalSeconds"
 )
 
 // Telemetry Configuration
@@ -64,6 +101,16 @@ const (
 	InstrumentDecisionDuration
 	// InstrumentDBQueryDuration represents the database query duration metric
 	InstrumentDBQueryDuration
+	// InstrumentDatastoreCircuitOpen represents the open circuit breaker metric of a datastore
+	InstrumentDatastoreCircuitOpen
+	// InstrumentStatementCacheRequests represents the lookup metric of the statement cache
+	InstrumentStatementCacheRequests
+	// InstrumentPolicyRevision represents the revision metric of the loaded regos
+	InstrumentPolicyRevision
+	// InstrumentShadowDenials represents the metric of denied requests, which were allowed in shadow mode
+	InstrumentShadowDenials
+	// InstrumentCandidateMismatches represents the metric of decisions, which the candidate package disagreed with
+	InstrumentCandidateMismatches
 )
 
 func (i MetricInstrument) String() string {
@@ -84,6 +131,16 @@ func (i MetricInstrument) String() string {
 		return "decision.duration"
 	case InstrumentDBQueryDuration:
 		return "db.query.duration"
+	case InstrumentDatastoreCircuitOpen:
+		return "db.circuit.open"
+	case InstrumentStatementCacheRequests:
+		return "statement.cache.requests"
+	case InstrumentPolicyRevision:
+		return "policy.revision"
+	case InstrumentShadowDenials:
+		return "decision.shadow.denials"
+	case InstrumentCandidateMismatches:
+		return "decision.candidate.mismatches"
 	default:
 		return "unknown"
 	}
@@ -108,4 +165,12 @@ const (
 	LabelPolicyDecisionReason string = "reason"
 	// LabelRegoPackage is the label for the rego package in the metrics
 	LabelRegoPackage string = "rego.package"
+	// LabelRegoCandidatePackage is the label for the candidate package, which is evaluated side by side with the rego package
+	LabelRegoCandidatePackage string = "rego.candidate_package"
+	// LabelCacheResult is the label which holds the result (hit/miss) of a cache lookup
+	LabelCacheResult string = "cache.result"
+	// LabelPolicyRevision is the label which holds the revision of the loaded regos
+	LabelPolicyRevision string = "revision"
+	// LabelDecisionID is the span attribute which holds the ID of the decision made for the request
+	LabelDecisionID string = "kelon.decision_id"
 )
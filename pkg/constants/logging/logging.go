@@ -23,10 +23,18 @@ const LabelDecision string = "decision"
 // LabelReason - Label for decision reason
 const LabelReason string = "reason"
 
+// LabelRevision - Label for the policy revision a decision was made with
+const LabelRevision string = "revision"
+
 // LabelError - Label for all errors
 const LabelError string = "error"
 
-// LabelCorrelation - Label for multiline error logs
+// LabelDecisionID - Label for the ID of a decision, which also correlates multiline error logs
+const LabelDecisionID = "decisionId"
+
+// LabelCorrelation - Label for multiline error logs, which contains the decision ID as well
+//
+// Deprecated: Use LabelDecisionID instead. The field is still written, so that existing log queries keep working.
 const LabelCorrelation = "correlationId"
 
 // LogAccessDecision formats the decision and logs it
@@ -43,10 +51,18 @@ func checkAccessDecisionLogLevel(logLevel, decision string) bool {
 	return logLevel == "ALL" || decision == logLevel
 }
 
+// LogWithDecisionID creates a new log entry which contains the decision ID (also as deprecated correlation ID)
+// Useful, if multiple lines should be logged, and it should be clear, that they belong together
+func LogWithDecisionID(decisionID string) *log.Entry {
+	return log.WithFields(log.Fields{LabelDecisionID: decisionID, LabelCorrelation: decisionID})
+}
+
 // LogWithCorrelationID creates a new log entry which contains the correlation ID
 // Useful, if multiple lines should be logged, and it should be clear, that they belong together
+//
+// Deprecated: Use LogWithDecisionID instead.
 func LogWithCorrelationID(correlation uuid.UUID) *log.Entry {
-	return log.WithField(LabelCorrelation, correlation.String())
+	return LogWithDecisionID(correlation.String())
 }
 
 // LogForComponent creates a new log entry containing the component label
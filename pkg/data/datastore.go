@@ -33,6 +33,56 @@ type Datastore interface {
 	Execute(ctx context.Context, query Node) (bool, error)
 }
 
+// PreparedDatastore is implemented by datastores whose translation and execution can be invoked separately.
+// This allows to reuse an already translated data.DatastoreQuery with different parameters.
+type PreparedDatastore interface {
+
+	// Translate translates the given Query-AST into a datastore's native query without executing it.
+	Translate(ctx context.Context, query Node) (DatastoreQuery, error)
+
+	// ExecuteQuery executes an already translated native query.
+	ExecuteQuery(ctx context.Context, query DatastoreQuery) (bool, error)
+}
+
+// ConfigValidator is implemented by datastores and executors which are able to validate their configuration
+// without connecting to their backend.
+type ConfigValidator interface {
+
+	// Validate returns an error if the configuration of the datastore with the provided alias is invalid.
+	Validate(appConf *configs.AppConfig, alias string) error
+}
+
+// ReadinessReporter is implemented by datastores which connect to their backend in the background.
+type ReadinessReporter interface {
+
+	// Ready returns true as soon as the datastore is able to execute queries.
+	// Otherwise, false is returned together with the last error encountered while connecting (if any).
+	Ready() (bool, error)
+}
+
+// Pinger is implemented by datastores and executors which are able to check the connection to their backend.
+type Pinger interface {
+
+	// Ping checks whether the backend is reachable within the deadline of the provided context.
+	Ping(ctx context.Context) error
+}
+
+// Closer is implemented by datastores and executors which hold connections to their backend.
+type Closer interface {
+
+	// Close releases all connections to the backend. Running queries are finished before, as long as the
+	// deadline of the provided context is not exceeded.
+	Close(ctx context.Context) error
+}
+
+// CallOperandsReloader is implemented by datastores and translators which are able to replace their call operands at runtime.
+type CallOperandsReloader interface {
+
+	// ReloadCallOperands replaces the call operands, which were loaded during Configure, for all following queries.
+	// Just like configs.AppConfig.CallOperands, the call operands are passed per datastore type.
+	ReloadCallOperands(callOperands map[string]map[string]func(args ...string) (string, error)) error
+}
+
 // DatastoreTranslator is the interface that maps a generic designed AST returned by translate.AstTranslator to a native query-statement which is understood by a matching data.DatastoreExecutor.
 // This should be generally done by translating the Query-AST into the datastore's native query language.
 type DatastoreTranslator interface {
@@ -74,3 +124,18 @@ type CallOpMapper interface {
 	// Map returns the corresponding datastore native function (i.e. 'ABS(<arguments>)').
 	Map(args ...string) (string, error)
 }
+
+// Circuit states reported by a data.CircuitBreaker
+const (
+	CircuitClosed   = "closed"
+	CircuitOpen     = "open"
+	CircuitHalfOpen = "half-open"
+)
+
+// CircuitBreaker is implemented by datastores which guard their backend with a circuit breaker.
+// It is used to expose the state of the breaker i.e. on the health endpoint.
+type CircuitBreaker interface {
+
+	// CircuitState returns the current state of the circuit which is one of CircuitClosed, CircuitOpen or CircuitHalfOpen.
+	CircuitState() string
+}
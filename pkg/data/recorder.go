@@ -0,0 +1,59 @@
+package data
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/unbasical/kelon/pkg/constants"
+)
+
+// RecordedQuery is a native query, which was executed against a datastore while a decision was made.
+type RecordedQuery struct {
+	Datastore  string
+	Statement  any
+	Parameters []any
+	Duration   time.Duration
+	Error      string
+}
+
+// QueryRecorder collects all queries which are executed for a single decision, i.e. to write them to the decision log.
+// The recorder is passed to the datastores via the context (see WithQueryRecorder).
+type QueryRecorder struct {
+	mutex   sync.Mutex
+	queries []RecordedQuery
+}
+
+// WithQueryRecorder returns a context, which records all executed queries into the recorder.
+func WithQueryRecorder(ctx context.Context, recorder *QueryRecorder) context.Context {
+	return context.WithValue(ctx, constants.ContextKeyQueryRecorder, recorder)
+}
+
+// RecordQuery adds the query to the recorder of the context. If the context has no recorder, the query is dropped.
+func RecordQuery(ctx context.Context, datastore string, query DatastoreQuery, duration time.Duration, err error) {
+	recorder, ok := ctx.Value(constants.ContextKeyQueryRecorder).(*QueryRecorder)
+	if !ok || recorder == nil {
+		return
+	}
+
+	recorded := RecordedQuery{
+		Datastore:  datastore,
+		Statement:  query.Statement,
+		Parameters: query.Parameters,
+		Duration:   duration,
+	}
+	if err != nil {
+		recorded.Error = err.Error()
+	}
+
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+	recorder.queries = append(recorder.queries, recorded)
+}
+
+// Queries returns all recorded queries in the order they were executed.
+func (r *QueryRecorder) Queries() []RecordedQuery {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return append([]RecordedQuery(nil), r.queries...)
+}
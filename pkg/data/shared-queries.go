@@ -0,0 +1,68 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/unbasical/kelon/pkg/constants"
+)
+
+// SharedQueries executes identical queries of multiple decisions (i.e. of a batch) only once.
+// The queries are shared with the datastores via the context (see WithSharedQueries).
+type SharedQueries struct {
+	mutex   sync.Mutex
+	results map[string]*sharedResult
+}
+
+type sharedResult struct {
+	done   chan struct{}
+	result bool
+	err    error
+}
+
+// WithSharedQueries returns a context, in which identical queries are executed only once.
+func WithSharedQueries(ctx context.Context, queries *SharedQueries) context.Context {
+	return context.WithValue(ctx, constants.ContextKeySharedQueries, queries)
+}
+
+// ExecuteShared returns the result of an identical query, which was already executed against the datastore within the
+// context. Otherwise, the query is executed with the provided function. Concurrent identical queries wait for the first one.
+// If the first one panics, the waiting queries fail instead of blocking forever.
+// Only successful results are kept, so that identical queries after a failure (e.g. retries) are executed again.
+func ExecuteShared(ctx context.Context, datastore string, query DatastoreQuery, execute func() (bool, error)) (bool, error) {
+	queries, ok := ctx.Value(constants.ContextKeySharedQueries).(*SharedQueries)
+	if !ok || queries == nil {
+		return execute()
+	}
+
+	key, err := json.Marshal([]any{datastore, query.Statement, query.Parameters})
+	if err != nil {
+		return execute()
+	}
+
+	queries.mutex.Lock()
+	if queries.results == nil {
+		queries.results = make(map[string]*sharedResult)
+	}
+	if shared, exists := queries.results[string(key)]; exists {
+		queries.mutex.Unlock()
+		<-shared.done
+		return shared.result, shared.err
+	}
+	shared := &sharedResult{done: make(chan struct{}), err: errors.New("SharedQueries: Identical query was aborted")}
+	queries.results[string(key)] = shared
+	queries.mutex.Unlock()
+
+	defer func() {
+		if shared.err != nil {
+			queries.mutex.Lock()
+			delete(queries.results, string(key))
+			queries.mutex.Unlock()
+		}
+		close(shared.done)
+	}()
+	shared.result, shared.err = execute()
+	return shared.result, shared.err
+}
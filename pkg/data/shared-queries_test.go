@@ -0,0 +1,103 @@
+package data
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func sharedQuery(parameters ...any) DatastoreQuery {
+	return DatastoreQuery{Statement: "SELECT count(*) FROM users WHERE name = ?", Parameters: parameters}
+}
+
+func Test_ExecuteShared_IdenticalQueries(t *testing.T) {
+	ctx := WithSharedQueries(context.Background(), &SharedQueries{})
+	var (
+		executions atomic.Int32
+		release    = make(chan struct{})
+		wg         sync.WaitGroup
+	)
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result, err := ExecuteShared(ctx, "mysql", sharedQuery("Arnold"), func() (bool, error) {
+				executions.Add(1)
+				<-release
+				return true, nil
+			})
+			assert.NoError(t, err)
+			assert.True(t, result)
+		}()
+	}
+
+	// All queries wait for the first execution
+	assert.Eventually(t, func() bool { return executions.Load() == 1 }, time.Second, time.Millisecond)
+	close(release)
+	wg.Wait()
+	assert.Equal(t, int32(1), executions.Load())
+}
+
+func Test_ExecuteShared_DistinctQueries(t *testing.T) {
+	ctx := WithSharedQueries(context.Background(), &SharedQueries{})
+	var executions int
+	execute := func(result bool) func() (bool, error) {
+		return func() (bool, error) {
+			executions++
+			return result, nil
+		}
+	}
+
+	result, _ := ExecuteShared(ctx, "mysql", sharedQuery("Arnold"), execute(true))
+	assert.True(t, result)
+	result, _ = ExecuteShared(ctx, "mysql", sharedQuery("Kevin"), execute(false))
+	assert.False(t, result)
+	result, _ = ExecuteShared(ctx, "pg", sharedQuery("Arnold"), execute(false))
+	assert.False(t, result)
+	assert.Equal(t, 3, executions)
+
+	// Without shared queries in the context, every query is executed
+	result, _ = ExecuteShared(context.Background(), "mysql", sharedQuery("Arnold"), execute(true))
+	assert.True(t, result)
+	assert.Equal(t, 4, executions)
+}
+
+func Test_ExecuteShared_Error(t *testing.T) {
+	ctx := WithSharedQueries(context.Background(), &SharedQueries{})
+	failure := errors.New("connection refused")
+
+	_, err := ExecuteShared(ctx, "mysql", sharedQuery("Arnold"), func() (bool, error) { return false, failure })
+	assert.Equal(t, failure, err)
+
+	// The error is not kept, so identical queries are executed again
+	result, err := ExecuteShared(ctx, "mysql", sharedQuery("Arnold"), func() (bool, error) { return true, nil })
+	assert.NoError(t, err)
+	assert.True(t, result)
+}
+
+func Test_ExecuteShared_Panic(t *testing.T) {
+	ctx := WithSharedQueries(context.Background(), &SharedQueries{})
+
+	assert.Panics(t, func() {
+		_, _ = ExecuteShared(ctx, "mysql", sharedQuery("Arnold"), func() (bool, error) { panic("driver bug") })
+	})
+
+	// Identical queries don't block, but are executed again
+	done := make(chan error)
+	go func() {
+		_, err := ExecuteShared(ctx, "mysql", sharedQuery("Arnold"), func() (bool, error) { return true, nil })
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("identical query is blocked by the panicked one")
+	}
+}
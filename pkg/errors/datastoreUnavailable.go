@@ -0,0 +1,13 @@
+package errors
+
+import "fmt"
+
+// DatastoreUnavailable thrown if a datastore can currently not be queried (i.e. because its circuit is open)
+type DatastoreUnavailable struct {
+	Alias string
+	Msg   string
+}
+
+func (err DatastoreUnavailable) Error() string {
+	return fmt.Sprintf("Datastore [%s] is unavailable: %s", err.Alias, err.Msg)
+}
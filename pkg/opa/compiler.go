@@ -7,8 +7,10 @@ package opa
 import (
 	"context"
 
+	"github.com/open-policy-agent/opa/v1/metrics"
 	"github.com/open-policy-agent/opa/v1/plugins"
 	"github.com/unbasical/kelon/configs"
+	"github.com/unbasical/kelon/pkg/constants"
 	"github.com/unbasical/kelon/pkg/request"
 	"github.com/unbasical/kelon/pkg/translate"
 	"github.com/unbasical/kelon/pkg/watcher"
@@ -20,24 +22,94 @@ import (
 // instance of a PolicyCompiler can be seen as a standalone thread with all its subcomponents attached to it.
 // As a result, two PolicyCompilers should be able to run in parallel.
 type PolicyCompilerConfig struct {
-	RegoDir       *string
-	Prefix        *string
-	OPAConfig     any
-	PathProcessor *request.PathProcessor
-	Translator    *translate.AstTranslator
-	ConfigWatcher *watcher.ConfigWatcher
+	RegoDir                 *string
+	BundlePath              *string
+	BundleVerificationKeyID string
+	BundleVerificationScope string
+	Prefix                  *string
+	OPAConfig               any
+	PathProcessor           *request.PathProcessor
+	Translator              *translate.AstTranslator
+	ConfigWatcher           *watcher.ConfigWatcher
 	translate.AstTranslatorConfig
 	request.PathProcessorConfig
 	AccessDecisionLogLevel string
+	// LogQueryParameters writes the parameters of the executed datastore queries to OPA's decision logs.
+	// They are redacted by default, because they might contain credentials or personal data of the input.
+	LogQueryParameters bool
 }
 
 // Decision represents a policy decision
 type Decision struct {
+	ID       string
+	Verify   bool
+	Allow    bool
+	Package  string
+	Path     string
+	Method   string
+	Revision string
+	// Headers which are injected into the request (if allowed) or the response (if denied), defined by the rule 'headers'
+	Headers map[string]string
+	// Reason of the decision, defined by the rule 'reason'
+	Reason string
+	// Obligations, which the caller has to fulfill, defined by the rule 'obligations'
+	Obligations any
+	// Shadow is set if the request was mapped in shadow mode. Denied requests are allowed, but the decision logs
+	// contain the evaluated decision.
+	Shadow bool
+	// Candidate is the decision of the candidate package, if one is configured for the mapping
+	Candidate *CandidateDecision
+}
+
+// CandidateDecision is the decision of a candidate package, which is evaluated side by side with the live package
+// in order to roll out new policies safely.
+type CandidateDecision struct {
+	Package string
 	Verify  bool
 	Allow   bool
-	Package string
-	Path    string
-	Method  string
+	// Mismatch is set if the candidate disagrees with the evaluated decision of the live package
+	Mismatch bool
+	// Error of the evaluation, the decision is invalid if it is set
+	Error error
+}
+
+// Results returns the values of the optional rules 'headers', 'reason' and 'obligations', which the policy defined
+// in addition to the decision. Rules which were not defined are omitted.
+func (d *Decision) Results() map[string]any {
+	results := make(map[string]any)
+	if len(d.Headers) > 0 {
+		results["headers"] = d.Headers
+	}
+	if d.Reason != "" {
+		results["reason"] = d.Reason
+	}
+	if d.Obligations != nil {
+		results["obligations"] = d.Obligations
+	}
+	return results
+}
+
+// WithDecisionID returns a context, which makes the PolicyCompiler use the provided ID for its decision.
+// This way, the ID can be returned to the caller even if the decision failed.
+func WithDecisionID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, constants.ContextKeyDecisionID, id)
+}
+
+// DecisionIDFromContext returns the decision ID of the context or an empty string, if there is none.
+func DecisionIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(constants.ContextKeyDecisionID).(string)
+	return id
+}
+
+// WithMetrics returns a context, which makes the PolicyCompiler record the timers of its decision into the provided metrics.
+func WithMetrics(ctx context.Context, m metrics.Metrics) context.Context {
+	return context.WithValue(ctx, constants.ContextKeyMetrics, m)
+}
+
+// MetricsFromContext returns the metrics of the context or nil, if there are none.
+func MetricsFromContext(ctx context.Context) metrics.Metrics {
+	m, _ := ctx.Value(constants.ContextKeyMetrics).(metrics.Metrics)
+	return m
 }
 
 // PolicyCompiler is the interface that makes final decisions on incoming requests.
@@ -62,3 +134,32 @@ type PolicyCompiler interface {
 	// Execute partially evaluates the Rego query and transpiles the unknowns to database queries and executes them.
 	Execute(ctx context.Context, request map[string]any) (*Decision, error)
 }
+
+// ReloadablePolicyCompiler is implemented by PolicyCompilers which are able to swap their configuration at runtime.
+type ReloadablePolicyCompiler interface {
+
+	// Reload configures all sub-components with the new configuration. The previous configuration stays active until
+	// the returned PendingReload is committed, so that components depending on the PolicyCompiler can be reloaded beforehand.
+	//
+	// If any sub-component fails to configure, the encountered error is returned and the previous configuration stays active.
+	Reload(appConfig *configs.AppConfig, compConfig *PolicyCompilerConfig) (PendingReload, error)
+}
+
+// PendingReload is a configuration prepared by ReloadablePolicyCompiler.Reload. Exactly one of its methods has to be called.
+type PendingReload interface {
+
+	// Commit swaps in the new configuration atomically.
+	// Requests which are already running finish with the previous configuration, whose datastores are closed afterwards.
+	Commit()
+
+	// Abort drops the new configuration and closes its datastores, the previous configuration stays active.
+	Abort()
+}
+
+// RevisionReporter is implemented by PolicyCompilers which are able to report the revision of their loaded regos or bundle.
+type RevisionReporter interface {
+
+	// PolicyRevision returns the revision of the active bundle's manifest or, if it has none, a hash of the currently active
+	// regos. It is empty if no policies were loaded from disk.
+	PolicyRevision() string
+}
@@ -43,6 +43,21 @@ type MapperOutput struct {
 	Package        string
 	Authorization  bool
 	Authentication bool
+	Rules          PolicyRules
+	// Shadow allows all requests, even if the policy denies them
+	Shadow bool
+	// CandidatePackage is evaluated side by side with the Package, if it is set
+	CandidatePackage string
+}
+
+// PolicyRules contains the names of the rules inside the package, which are evaluated to make a decision.
+type PolicyRules struct {
+	// Verify is the rule used for authentication
+	Verify string
+	// Allow is the rule used for authorization, if it is empty only the Deny rules are evaluated
+	Allow string
+	// Deny rules are evaluated in order after Allow, any of them being true rejects the request
+	Deny []string
 }
 
 // Textual representation of a PathAmbiguousError.
@@ -19,12 +19,15 @@ type PathProcessorConfig struct {
 // Extracted Query-Parameters mapped to their values can i.e. be attached to the input-field of the OPA-query.
 // A slice containing all separated path parts is also returned.
 type PathProcessorOutput struct {
-	Datastores     []string
-	Package        string
-	Authorization  bool
-	Authentication bool
-	Path           []string
-	Queries        map[string]any
+	Datastores       []string
+	Package          string
+	Authorization    bool
+	Authentication   bool
+	Rules            PolicyRules
+	Shadow           bool
+	CandidatePackage string
+	Path             []string
+	Queries          map[string]any
 }
 
 // PathProcessor is the interface that processes an incoming path by parsing and afterward mapping it to a Datastore and a Package.
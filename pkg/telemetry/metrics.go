@@ -288,6 +288,56 @@ func (m *metrics) initCustomMetrics() error {
 	}
 	m.instruments[constants.InstrumentDBQueryDuration] = dbQueryDuration
 
+	datastoreCircuitOpen, err := meter.Int64UpDownCounter(
+		constants.InstrumentDatastoreCircuitOpen.String(),
+		metric.WithUnit("{circuits}"),
+		metric.WithDescription("A gauge which is 1 while the circuit breaker of a datastore is open and 0 otherwise"),
+	)
+	if err != nil {
+		return err
+	}
+	m.instruments[constants.InstrumentDatastoreCircuitOpen] = datastoreCircuitOpen
+
+	statementCacheRequests, err := meter.Int64Counter(
+		constants.InstrumentStatementCacheRequests.String(),
+		metric.WithUnit("{requests}"),
+		metric.WithDescription("A counter of statement cache lookups by result (hit/miss)"),
+	)
+	if err != nil {
+		return err
+	}
+	m.instruments[constants.InstrumentStatementCacheRequests] = statementCacheRequests
+
+	policyRevision, err := meter.Int64UpDownCounter(
+		constants.InstrumentPolicyRevision.String(),
+		metric.WithUnit("{revision}"),
+		metric.WithDescription("A gauge which is 1 for the revision of the currently loaded regos"),
+	)
+	if err != nil {
+		return err
+	}
+	m.instruments[constants.InstrumentPolicyRevision] = policyRevision
+
+	shadowDenials, err := meter.Int64Counter(
+		constants.InstrumentShadowDenials.String(),
+		metric.WithUnit("{decisions}"),
+		metric.WithDescription("A counter of denied requests, which were allowed because their mapping is in shadow mode"),
+	)
+	if err != nil {
+		return err
+	}
+	m.instruments[constants.InstrumentShadowDenials] = shadowDenials
+
+	candidateMismatches, err := meter.Int64Counter(
+		constants.InstrumentCandidateMismatches.String(),
+		metric.WithUnit("{decisions}"),
+		metric.WithDescription("A counter of decisions, which the candidate package disagreed with"),
+	)
+	if err != nil {
+		return err
+	}
+	m.instruments[constants.InstrumentCandidateMismatches] = candidateMismatches
+
 	return nil
 }
 
@@ -1,7 +1,11 @@
 // Package watcher contains components that are used for configuration reloading of kelon.
 package watcher
 
-import "github.com/unbasical/kelon/configs"
+import (
+	"strings"
+
+	"github.com/unbasical/kelon/configs"
+)
 
 // ConfigWatcher is the interface that manages configuration reloading.
 //
@@ -9,20 +13,51 @@ import "github.com/unbasical/kelon/configs"
 type ConfigWatcher interface {
 
 	// Watch will monitor for configuration changes and calls the passed callback procedure every
-	// time the config changes.
-	Watch(callback func(ChangeType, *configs.ExternalConfig, error))
+	// time the config changes. Changes which happen shortly after each other are passed to the callback at once,
+	// together with the paths of all changed files.
+	Watch(callback func(ChangeType, []string, *configs.ExternalConfig, error))
+}
+
+// LivenessReporter is implemented by config watchers which watch for changes in the background.
+type LivenessReporter interface {
+
+	// Alive returns true as long as the watcher is able to report changes.
+	Alive() bool
 }
 
-// ChangeType represent the type of changes that can occur during Watch()
+// ChangeType represent the type of changes that can occur during Watch().
+// Except for ChangeAll, the types are flags which are combined if different kinds of files changed at once.
 type ChangeType int
 
 const (
 	// ChangeAll Passed to Watch() on initial load
 	ChangeAll ChangeType = 0
 	// ChangeRego Passed to Watch() if any file with ending '.rego' changed
-	ChangeRego ChangeType = 1
+	ChangeRego ChangeType = 1 << (iota - 1)
 	// ChangeConf Passed to Watch() if any file with ending .yml or .yaml changed
-	ChangeConf ChangeType = 2
+	ChangeConf
 	// ChangeUnknown Passed to Watch() if any file with unknown file ending changed
-	ChangeUnknown ChangeType = 3
+	ChangeUnknown
 )
+
+// Has returns true if the change contains the passed change type.
+func (c ChangeType) Has(change ChangeType) bool {
+	return c&change != 0
+}
+
+func (c ChangeType) String() string {
+	if c == ChangeAll {
+		return "ALL"
+	}
+
+	var names []string
+	for _, flag := range []struct {
+		change ChangeType
+		name   string
+	}{{ChangeRego, "REGO"}, {ChangeConf, "CONF"}, {ChangeUnknown, "UNKNOWN"}} {
+		if c.Has(flag.change) {
+			names = append(names, flag.name)
+		}
+	}
+	return strings.Join(names, "+")
+}
@@ -137,6 +137,7 @@ func (env *E2ETestEnvironment) startKelon() {
 
 	var defaultAccessLogLevel = "ALL"
 	var astSkipUnknown = false
+	var statementCacheSize = 1000
 
 	config := core.KelonConfiguration{
 		ConfigPath:             &env.configPath,
@@ -146,6 +147,7 @@ func (env *E2ETestEnvironment) startKelon() {
 		PathPrefix:             &env.pathPrefix,
 		AccessDecisionLogLevel: &defaultAccessLogLevel,
 		AstSkipUnknown:         &astSkipUnknown,
+		StatementCacheSize:     &statementCacheSize,
 	}
 
 	kelon := core.Kelon{}
@@ -159,7 +161,7 @@ func (env *E2ETestEnvironment) startKelon() {
 func (env *E2ETestEnvironment) waitForKelon() {
 	healthy := false
 	for !healthy {
-		resp, httpErr := http.Get(fmt.Sprintf("http://localhost:%d/health", env.kelonPort))
+		resp, httpErr := http.Get(fmt.Sprintf("http://localhost:%d/ready", env.kelonPort))
 		if httpErr == nil {
 			if resp.StatusCode == http.StatusOK {
 				healthy = true
@@ -0,0 +1,109 @@
+package integration
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/open-policy-agent/opa/v1/bundle"
+	"github.com/stretchr/testify/assert"
+	"github.com/unbasical/kelon/pkg/opa"
+)
+
+const bundlePolicy = `package applications.pure
+
+default allow := false
+
+allow if {
+	input.user in data.users.pure
+}
+`
+
+// bundleServer serves a single bundle, which can be replaced while the server is running
+type bundleServer struct {
+	mutex  sync.Mutex
+	bundle []byte
+}
+
+func (s *bundleServer) publish(t *testing.T, revision string, users ...string) {
+	b := bundle.Bundle{
+		Manifest: bundle.Manifest{
+			Revision: revision,
+			Roots:    &[]string{"applications/pure", "users"},
+		},
+		Data: map[string]any{
+			"users": map[string]any{"pure": users},
+		},
+		Modules: []bundle.ModuleFile{
+			{URL: "/pure.rego", Path: "/pure.rego", Raw: []byte(bundlePolicy)},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := bundle.NewWriter(&buf).Write(b); err != nil {
+		t.Fatal(err)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.bundle = buf.Bytes()
+}
+
+func (s *bundleServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/bundles/kelon.tar.gz" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	w.Header().Set("Content-Type", "application/gzip")
+	_, _ = w.Write(s.bundle)
+}
+
+func Test_integration_remoteBundle(t *testing.T) {
+	bundles := &bundleServer{}
+	bundles.publish(t, "v1", "Torben")
+	server := httptest.NewServer(bundles)
+	defer server.Close()
+
+	compiler := configurePolicyCompiler(t, "", map[string]any{
+		"services": map[string]any{
+			"bundles": map[string]any{"url": server.URL},
+		},
+		"bundles": map[string]any{
+			"kelon": map[string]any{
+				"service":  "bundles",
+				"resource": "bundles/kelon.tar.gz",
+				"polling":  map[string]any{"min_delay_seconds": 1, "max_delay_seconds": 1},
+			},
+		},
+	})
+
+	reporter, ok := compiler.(opa.RevisionReporter)
+	if !ok {
+		t.Fatal("policy compiler does not report revisions")
+	}
+	execute := func(user string) bool {
+		decision, err := compiler.Execute(context.Background(), map[string]any{
+			"input": map[string]any{"method": "GET", "path": "/api/pure/apps/2", "user": user},
+		})
+		assert.NoError(t, err)
+		return decision != nil && decision.Allow
+	}
+
+	// The first bundle is activated after the initial download
+	assert.Eventually(t, func() bool { return reporter.PolicyRevision() == "v1" }, 10*time.Second, 50*time.Millisecond)
+	assert.True(t, execute("Torben"))
+	assert.False(t, execute("Arnold"))
+
+	// Updated policies and data are pulled from the server
+	bundles.publish(t, "v2", "Arnold")
+	assert.Eventually(t, func() bool { return reporter.PolicyRevision() == "v2" }, 10*time.Second, 50*time.Millisecond)
+	assert.False(t, execute("Torben"))
+	assert.True(t, execute("Arnold"))
+}
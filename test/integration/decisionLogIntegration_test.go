@@ -0,0 +1,100 @@
+package integration
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/unbasical/kelon/pkg/opa"
+)
+
+// decisionLogCollector receives the gzipped decision log batches uploaded by OPA's decision log plugin
+type decisionLogCollector struct {
+	mutex  sync.Mutex
+	events []map[string]any
+}
+
+func (c *decisionLogCollector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	reader, err := gzip.NewReader(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	var events []map[string]any
+	if err := json.NewDecoder(reader).Decode(&events); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.events = append(c.events, events...)
+	w.WriteHeader(http.StatusOK)
+}
+
+func (c *decisionLogCollector) received() []map[string]any {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return append([]map[string]any(nil), c.events...)
+}
+
+func Test_integration_decisionLogs(t *testing.T) {
+	collector := &decisionLogCollector{}
+	server := httptest.NewServer(collector)
+	defer server.Close()
+
+	compiler := configurePolicyCompiler(t, "./examples/local/policies", map[string]any{
+		"labels": map[string]any{"app": "kelon"},
+		"services": map[string]any{
+			"collector": map[string]any{"url": server.URL},
+		},
+		"decision_logs": map[string]any{
+			"service":   "collector",
+			"reporting": map[string]any{"min_delay_seconds": 1, "max_delay_seconds": 1},
+		},
+	})
+
+	// The decision ID is provided by the caller, i.e. the REST-Proxy which returns it in the response headers
+	ctx := opa.WithDecisionID(context.Background(), "b7a0d7c4-8a4e-4e0b-9d2a-6f3c1e2d4a5b")
+	decision, err := compiler.Execute(ctx, map[string]any{
+		"input": map[string]any{"method": "GET", "path": "/api/mysql/apps/2", "user": "Arnold", "password": "pw_arnold"},
+	})
+	assert.NoError(t, err)
+	assert.True(t, decision.Allow)
+	assert.Equal(t, "b7a0d7c4-8a4e-4e0b-9d2a-6f3c1e2d4a5b", decision.ID)
+
+	assert.Eventually(t, func() bool { return len(collector.received()) == 1 }, 10*time.Second, 100*time.Millisecond)
+	event := collector.received()[0]
+
+	assert.Equal(t, decision.ID, event["decision_id"])
+	assert.Equal(t, "applications/mysql", event["path"])
+	assert.Equal(t, "kelon", event["labels"].(map[string]any)["app"])
+	assert.Equal(t, "Arnold", event["input"].(map[string]any)["user"])
+
+	result := event["result"].(map[string]any)
+	assert.Equal(t, true, result["allow"])
+	assert.Equal(t, true, result["verify"])
+	assert.Equal(t, "applications.mysql", result["package"])
+	assert.Equal(t, false, result["cached"])
+
+	queries := result["queries"].([]any)
+	if assert.Len(t, queries, 2) {
+		verify := queries[0].(map[string]any)
+		assert.Equal(t, "mysql", verify["datastore"])
+		assert.Contains(t, verify["statement"], "SELECT count(*) FROM appstore.users")
+		// The parameters might contain credentials, so they are redacted by default
+		assert.Equal(t, []any{"[REDACTED]", "[REDACTED]"}, verify["parameters"])
+	}
+
+	metrics := event["metrics"].(map[string]any)
+	for _, timer := range []string{"timer_kelon_decision_ns", "timer_rego_partial_eval_ns", "timer_kelon_datastore_query_ns"} {
+		assert.Contains(t, metrics, timer)
+	}
+}
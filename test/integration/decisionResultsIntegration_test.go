@@ -0,0 +1,73 @@
+package integration
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const resultsPolicy = `package applications.pure
+
+default allow := false
+
+allow if {
+	input.user == "Torben"
+}
+
+headers["X-User"] := input.user
+
+headers["X-Team"] := input.team
+
+reason := "Only Torben may access the apps" if not allow
+
+reason := "Conflicting reason" if input.conflict
+
+obligations contains "log-access" if allow
+`
+
+func Test_integration_decisionResults(t *testing.T) {
+	regoDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(regoDir, "pure.rego"), []byte(resultsPolicy), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	compiler := configurePolicyCompiler(t, regoDir, nil)
+
+	execute := func(user string, fields ...any) map[string]any {
+		input := map[string]any{"method": "GET", "path": "/api/pure/apps/2", "user": user}
+		for i := 0; i+1 < len(fields); i += 2 {
+			input[fields[i].(string)] = fields[i+1]
+		}
+		decision, err := compiler.Execute(context.Background(), map[string]any{"input": input})
+		assert.NoError(t, err)
+		results := decision.Results()
+		results["allow"] = decision.Allow
+		return results
+	}
+
+	assert.Equal(t, map[string]any{
+		"allow":       true,
+		"headers":     map[string]string{"X-User": "Torben"},
+		"obligations": []any{"log-access"},
+	}, execute("Torben"))
+
+	assert.Equal(t, map[string]any{
+		"allow":       false,
+		"headers":     map[string]string{"X-User": "Arnold"},
+		"reason":      "Only Torben may access the apps",
+		"obligations": []any{},
+	}, execute("Arnold"))
+
+	// Results which can't be evaluated or are invalid are dropped without changing the decision
+	assert.Equal(t, map[string]any{
+		"allow":       false,
+		"obligations": []any{},
+	}, execute("Arnold", "team", 7, "conflict", true))
+
+	assert.Equal(t, map[string]any{
+		"allow":       true,
+		"obligations": []any{"log-access"},
+	}, execute("Torben", "team", 7))
+}
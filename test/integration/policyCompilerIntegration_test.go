@@ -97,7 +97,7 @@ func runPolicyCompilerTest(t *testing.T, name string, config *testConfiguration)
 		name:                 name,
 		t:                    t,
 		policyCompiler:       opa2.NewPolicyCompiler(),
-		configWatcher:        watcherInt.NewFileWatcher(configLoader, config.policiesPath),
+		configWatcher:        watcherInt.NewFileWatcher(configLoader, 0, config.policiesPath),
 		pathPrefix:           config.pathPrefix,
 		policiesPath:         config.policiesPath,
 		callOpsPath:          config.callOpsPath,
@@ -105,7 +105,7 @@ func runPolicyCompilerTest(t *testing.T, name string, config *testConfiguration)
 	}
 
 	testEnvironment.configWatcher.Watch(
-		func(changeType watcher.ChangeType, config *configs.ExternalConfig, err error) {
+		func(changeType watcher.ChangeType, _ []string, config *configs.ExternalConfig, err error) {
 			testEnvironment.onConfigLoaded(changeType, config, err)
 		})
 
@@ -236,3 +236,77 @@ func (p *PolicyCompilerTestEnvironment) mockMakeDatastores(config *configs.Exter
 	}
 	return result
 }
+
+// configurePolicyCompiler configures a policy compiler with the example configuration, mocked datastores and the
+// provided rego dir (which may be empty) and OPA configuration.
+func configurePolicyCompiler(t *testing.T, regoDir string, opaConfig any) opa.PolicyCompiler {
+	return configurePolicyCompilerWithConfig(t, "./examples/local/config/kelon.yml", regoDir, opaConfig, nil)
+}
+
+// configurePolicyCompilerWithConfig configures a policy compiler like configurePolicyCompiler, but with the
+// configuration at configPath (relative to the repository's root) and the metrics provider (which may be nil).
+func configurePolicyCompilerWithConfig(t *testing.T, configPath, regoDir string, opaConfig any, metricsProvider telemetry.MetricsProvider) opa.PolicyCompiler {
+	// change root path for files
+	_, filename, _, ok := runtime.Caller(0)
+	if !ok {
+		t.FailNow()
+	}
+	if err := os.Chdir(path.Join(path.Dir(filename), "../..")); err != nil {
+		t.Fatal(err)
+	}
+
+	configLoader := configs.FileConfigLoader{FilePath: configPath}
+	loadedConf, err := configLoader.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		compiler      = opa2.NewPolicyCompiler()
+		configWatcher = watcherInt.NewSimple(configLoader)
+		parser        = requestInt.NewURLProcessor()
+		mapper        = requestInt.NewPathMapper()
+		translator    = translateInt.NewAstTranslator()
+		pathPrefix    = "/v1"
+		appConf       = &configs.AppConfig{
+			MetricsProvider: telemetry.NewNoopMetricProvider(),
+			TraceProvider:   telemetry.NewNoopTraceProvider(),
+		}
+		callOpsPath = "./examples/local/call-operands"
+		environment = PolicyCompilerTestEnvironment{t: t, name: t.Name(), evaluatedQueriesPath: "./test/integration/config/dbQueries.yml"}
+	)
+	if metricsProvider != nil {
+		appConf.MetricsProvider = metricsProvider
+	}
+	appConf.APIMappings = loadedConf.APIMappings
+	appConf.Datastores = loadedConf.Datastores
+	appConf.DatastoreSchemas = loadedConf.DatastoreSchemas
+	appConf.CallOperands, err = dataInt.LoadAllCallOperands(appConf.Datastores, &callOpsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	compConf := opa.PolicyCompilerConfig{
+		Prefix:        &pathPrefix,
+		RegoDir:       &regoDir,
+		ConfigWatcher: &configWatcher,
+		PathProcessor: &parser,
+		PathProcessorConfig: request.PathProcessorConfig{
+			PathMapper: &mapper,
+		},
+		Translator: &translator,
+		AstTranslatorConfig: translate.AstTranslatorConfig{
+			Datastores: environment.mockMakeDatastores(loadedConf),
+		},
+		OPAConfig:              opaConfig,
+		AccessDecisionLogLevel: "ALL",
+	}
+	configWatcher.Watch(func(change watcher.ChangeType, _ []string, _ *configs.ExternalConfig, _ error) {
+		if change == watcher.ChangeAll {
+			if err := compiler.Configure(appConf, &compConf); err != nil {
+				t.Fatal(err)
+			}
+		}
+	})
+	return compiler
+}
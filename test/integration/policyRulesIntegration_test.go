@@ -0,0 +1,108 @@
+package integration
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/unbasical/kelon/pkg/opa"
+)
+
+// rulesConfig contains the API mappings with configured rules
+const rulesConfig = "./test/integration/config/kelon.yml"
+
+const legacyPolicy = `package applications.legacy
+
+default authz := false
+
+authz if {
+	input.user != ""
+}
+
+deny contains "Kevin is blocked" if {
+	input.user == "Kevin"
+}
+`
+
+const blocklistPolicy = `package applications.blocklist
+
+blocked if {
+	input.user == "Kevin"
+}
+`
+
+const sessionPolicy = `package applications.session
+
+default authenticated := false
+
+authenticated if {
+	input.session == "valid"
+}
+
+default allow := false
+
+allow if {
+	input.user == "Torben"
+}
+`
+
+func writePolicies(t *testing.T, policies map[string]string) string {
+	t.Helper()
+	regoDir := t.TempDir()
+	for name, policy := range policies {
+		if err := os.WriteFile(filepath.Join(regoDir, name), []byte(policy), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return regoDir
+}
+
+func Test_integration_policyRules(t *testing.T) {
+	regoDir := writePolicies(t, map[string]string{
+		"legacy.rego":    legacyPolicy,
+		"blocklist.rego": blocklistPolicy,
+		"session.rego":   sessionPolicy,
+	})
+	compiler := configurePolicyCompilerWithConfig(t, rulesConfig, regoDir, nil, nil)
+
+	execute := func(path string, input map[string]any) *opa.Decision {
+		input["method"] = "GET"
+		input["path"] = path
+		decision, err := compiler.Execute(context.Background(), map[string]any{"input": input})
+		assert.NoError(t, err)
+		return decision
+	}
+
+	t.Run("allow and deny rules", func(t *testing.T) {
+		assert.True(t, execute("/api/rules/legacy/1", map[string]any{"user": "Torben"}).Allow)
+		// Rejected by the allow rule 'authz'
+		assert.False(t, execute("/api/rules/legacy/1", map[string]any{"user": ""}).Allow)
+		// Rejected by the deny rule
+		assert.False(t, execute("/api/rules/legacy/1", map[string]any{"user": "Kevin"}).Allow)
+	})
+
+	t.Run("only deny rules", func(t *testing.T) {
+		// The package has no allow rule, so everything which isn't denied is allowed
+		assert.True(t, execute("/api/rules/blocklist/1", map[string]any{"user": "Torben"}).Allow)
+		assert.True(t, execute("/api/rules/blocklist/1", map[string]any{}).Allow)
+		assert.False(t, execute("/api/rules/blocklist/1", map[string]any{"user": "Kevin"}).Allow)
+	})
+
+	t.Run("verify rule", func(t *testing.T) {
+		decision := execute("/api/session/apps/1", map[string]any{"user": "Torben", "session": "valid"})
+		assert.True(t, decision.Verify)
+		assert.True(t, decision.Allow)
+
+		// Authenticated, but not authorized
+		decision = execute("/api/session/apps/1", map[string]any{"user": "Arnold", "session": "valid"})
+		assert.True(t, decision.Verify)
+		assert.False(t, decision.Allow)
+
+		// Not authenticated by the rule 'authenticated', so authorization is skipped
+		decision = execute("/api/session/apps/1", map[string]any{"user": "Torben", "session": "expired"})
+		assert.False(t, decision.Verify)
+		assert.False(t, decision.Allow)
+	})
+}
@@ -0,0 +1,127 @@
+package integration
+
+import (
+	"context"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/unbasical/kelon/pkg/constants"
+	"github.com/unbasical/kelon/pkg/opa"
+	"github.com/unbasical/kelon/pkg/telemetry"
+)
+
+const rolloutPolicy = `package applications.rollout
+
+default allow := false
+
+allow if {
+	input.user == "Torben"
+}
+`
+
+const rolloutCandidatePolicy = `package applications.rollout_next
+
+default allow := false
+
+allow if {
+	input.user in {"Torben", "Arnold"}
+}
+`
+
+// countingMetricsProvider counts the increments of each counter and ignores all other metrics
+type countingMetricsProvider struct {
+	telemetry.MetricsProvider
+	mutex    sync.Mutex
+	counters map[constants.MetricInstrument]int64
+}
+
+func (p *countingMetricsProvider) UpdateCounterMetric(_ context.Context, metric constants.MetricInstrument, value any, _ map[string]string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.counters[metric] += value.(int64)
+}
+
+func (p *countingMetricsProvider) counter(metric constants.MetricInstrument) int64 {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.counters[metric]
+}
+
+func Test_integration_shadowMode(t *testing.T) {
+	collector := &decisionLogCollector{}
+	server := httptest.NewServer(collector)
+	defer server.Close()
+
+	metricsProvider := &countingMetricsProvider{
+		MetricsProvider: telemetry.NewNoopMetricProvider(),
+		counters:        make(map[constants.MetricInstrument]int64),
+	}
+	regoDir := writePolicies(t, map[string]string{"rollout.rego": rolloutPolicy, "rollout_next.rego": rolloutCandidatePolicy})
+	compiler := configurePolicyCompilerWithConfig(t, rulesConfig, regoDir, map[string]any{
+		"services": map[string]any{
+			"collector": map[string]any{"url": server.URL},
+		},
+		"decision_logs": map[string]any{
+			"service":   "collector",
+			"reporting": map[string]any{"min_delay_seconds": 1, "max_delay_seconds": 1},
+		},
+	}, metricsProvider)
+
+	execute := func(user string) (allow, shadow, mismatch bool) {
+		decision, err := compiler.Execute(opa.WithDecisionID(context.Background(), user), map[string]any{
+			"input": map[string]any{"method": "GET", "path": "/api/rules/rollout/1", "user": user},
+		})
+		assert.NoError(t, err)
+		assert.NotNil(t, decision.Candidate)
+		assert.Equal(t, "applications.rollout_next", decision.Candidate.Package)
+		return decision.Allow, decision.Shadow, decision.Candidate.Mismatch
+	}
+
+	// Allowed by both packages
+	allow, shadow, mismatch := execute("Torben")
+	assert.True(t, allow)
+	assert.True(t, shadow)
+	assert.False(t, mismatch)
+
+	// Denied by the live package, but allowed in shadow mode. The candidate disagrees.
+	allow, _, mismatch = execute("Arnold")
+	assert.True(t, allow)
+	assert.True(t, mismatch)
+
+	// Denied by both packages, but allowed in shadow mode
+	allow, _, mismatch = execute("Kevin")
+	assert.True(t, allow)
+	assert.False(t, mismatch)
+
+	assert.Equal(t, int64(2), metricsProvider.counter(constants.InstrumentShadowDenials))
+	assert.Equal(t, int64(1), metricsProvider.counter(constants.InstrumentCandidateMismatches))
+
+	// The decision logs contain the evaluated decisions of both packages
+	assert.Eventually(t, func() bool { return len(collector.received()) == 3 }, 10*time.Second, 100*time.Millisecond)
+	results := make(map[string]map[string]any)
+	for _, event := range collector.received() {
+		results[event["decision_id"].(string)] = event["result"].(map[string]any)
+	}
+	for user, expected := range map[string]struct{ allow, candidateAllow, mismatch bool }{
+		"Torben": {allow: true, candidateAllow: true, mismatch: false},
+		"Arnold": {allow: false, candidateAllow: true, mismatch: true},
+		"Kevin":  {allow: false, candidateAllow: false, mismatch: false},
+	} {
+		result := results[user]
+		if !assert.NotNil(t, result, user) {
+			continue
+		}
+		assert.Equal(t, expected.allow, result["allow"], user)
+		assert.Equal(t, true, result["shadow"], user)
+		assert.Equal(t, map[string]any{
+			"package":  "applications.rollout_next",
+			"verify":   true,
+			"allow":    expected.candidateAllow,
+			"mismatch": expected.mismatch,
+			"queries":  []any{},
+		}, result["candidate"], user)
+	}
+}